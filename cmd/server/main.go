@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,10 +18,28 @@ import (
 	"github.com/joho/godotenv"
 
 	"ospab-panel/internal/api"
+	"ospab-panel/internal/core/acl"
+	"ospab-panel/internal/core/apikey"
+	"ospab-panel/internal/core/audit"
+	"ospab-panel/internal/core/authn"
+	"ospab-panel/internal/core/challenge"
+	"ospab-panel/internal/core/confirm"
+	"ospab-panel/internal/core/inventory"
+	"ospab-panel/internal/core/lockout"
+	"ospab-panel/internal/core/org"
+	"ospab-panel/internal/core/passkey"
+	"ospab-panel/internal/core/password"
+	"ospab-panel/internal/core/rbac"
 	"ospab-panel/internal/core/server"
+	"ospab-panel/internal/core/session"
+	"ospab-panel/internal/core/settings"
+	"ospab-panel/internal/core/sso"
 	"ospab-panel/internal/core/user"
 	"ospab-panel/internal/hypervisor"
+	"ospab-panel/internal/infra/crypto"
 	"ospab-panel/internal/infra/db"
+	"ospab-panel/internal/infra/mail"
+	"ospab-panel/internal/infra/secrets"
 	"ospab-panel/pkg/auth"
 )
 
@@ -35,15 +56,170 @@ func main() {
 	}
 	defer repository.Close()
 
+	// Журнал аудита: цепочка хэшей, контрольные точки подписываются ключом AUDIT_CHECKPOINT_KEY
+	auditSigner, err := audit.ParseSigner(os.Getenv("AUDIT_CHECKPOINT_KEY"))
+	if err != nil {
+		log.Fatalf("Invalid AUDIT_CHECKPOINT_KEY: %v", err)
+	}
+	auditService := audit.NewService(repository.GetDB(), auditSigner)
+	if len(os.Args) > 1 && os.Args[1] == "audit-verify" {
+		os.Exit(verifyAudit(auditService))
+	}
+
+	// Политика паролей: регистрация, сброс и смена пароля
+	passwordConfig := password.DefaultConfig
+	passwordConfig.MinLength = getEnvInt("PASSWORD_MIN_LENGTH", passwordConfig.MinLength)
+	passwordConfig.MaxLength = getEnvInt("PASSWORD_MAX_LENGTH", passwordConfig.MaxLength)
+	passwordConfig.MinClasses = getEnvInt("PASSWORD_MIN_CLASSES", passwordConfig.MinClasses)
+	passwordConfig.ForbidUserInfo = getEnvBool("PASSWORD_FORBID_USER_INFO", passwordConfig.ForbidUserInfo)
+	passwordConfig.BreachedCheck = getEnvBool("PASSWORD_BREACHED_CHECK", passwordConfig.BreachedCheck)
+	passwordConfig.BreachedPath = os.Getenv("PASSWORD_BREACHED_PATH")
+	passwordPolicy, err := password.NewPolicy(passwordConfig)
+	if err != nil {
+		log.Fatalf("Failed to load breached passwords list: %v", err)
+	}
+
+	// Ключи шифрования учётных данных серверов и секретов TOTP
+	keyring, err := crypto.KeyringFromEnv(getEnvBool("DEV_MODE", false))
+	if err != nil {
+		log.Fatalf("Invalid encryption keys: %v", err)
+	}
+	// После "server rotate-keys" все значения привязаны к записям, и непривязанные можно запретить
+	requireBound := getEnvBool("SECRET_REQUIRE_BOUND", false)
+	keyring.RequireBound(requireBound)
+
 	// Инициализация сервисов
-	userService := user.NewService(repository.GetDB())
-	serverService := server.NewService(repository.GetDB())
-	jwtManager := auth.NewJWTManager(os.Getenv("JWT_SECRET"))
+	userService := user.NewService(repository.GetDB(), passwordPolicy, keyring)
+	// Хранилище учётных данных серверов: local (БД, ключ панели), vault (KV v2) или envelope (ключ в Vault Transit)
+	secretStore, err := secrets.New(secrets.Config{
+		Driver:            getEnvOrDefault("SECRET_STORE", secrets.DriverLocal),
+		VaultAddr:         os.Getenv("VAULT_ADDR"),
+		VaultToken:        os.Getenv("VAULT_TOKEN"),
+		VaultNamespace:    os.Getenv("VAULT_NAMESPACE"),
+		VaultKVMount:      os.Getenv("VAULT_KV_MOUNT"),
+		VaultKVPrefix:     os.Getenv("VAULT_KV_PREFIX"),
+		VaultTransitMount: os.Getenv("VAULT_TRANSIT_MOUNT"),
+		VaultTransitKey:   os.Getenv("VAULT_TRANSIT_KEY"),
+		CacheTTL:          getEnvDuration("SECRET_CACHE_TTL", 5*time.Minute),
+		RequireBound:      requireBound,
+		Timeout:           getEnvDuration("VAULT_TIMEOUT", 10*time.Second),
+	}, keyring)
+	if err != nil {
+		log.Fatalf("Invalid secret store configuration: %v", err)
+	}
+	serverService := server.NewService(repository.GetDB(), secretStore)
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		os.Exit(rotateKeys(keyring, serverService, userService))
+	}
+	jwtManager := auth.NewJWTManager(os.Getenv("JWT_SECRET"), getEnvDuration("ACCESS_TOKEN_TTL", auth.DefaultAccessTTL))
 	// Гипервизоры
 	hvFactory := hypervisor.NewHypervisorFactory()
 
+	// Инвентарь инстансов и фоновая синхронизация
+	inventoryService := inventory.NewService(repository.GetDB())
+	syncer := inventory.NewSyncer(inventoryService, serverService, hvFactory, getEnvDuration("INVENTORY_SYNC_INTERVAL", time.Minute))
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go syncer.Run(bgCtx)
+
+	confirmService := confirm.NewService(repository.GetDB())
+	rbacService := rbac.NewService(repository.GetDB())
+	orgService := org.NewService(repository.GetDB())
+	aclService := acl.NewService(repository.GetDB())
+	sessionService := session.NewService(repository.GetDB(), getEnvDuration("REFRESH_TOKEN_TTL", session.DefaultRefreshTTL))
+	challengeService := challenge.NewService(repository.GetDB())
+	settingsService := settings.NewService(repository.GetDB())
+	go runPeriodically(bgCtx, time.Hour, "Session cleanup", sessionService.PurgeExpired)
+	go runPeriodically(bgCtx, 10*time.Minute, "Challenge cleanup", challengeService.PurgeExpired)
+	go runPeriodically(bgCtx, 10*time.Minute, "Confirmation cleanup", confirmService.PurgeExpired)
+
+	// Защита от перебора паролей (состояние в БД — общее для реплик)
+	lockoutConfig := lockout.DefaultConfig
+	lockoutConfig.UserLockAfter = getEnvInt("LOGIN_LOCK_AFTER", lockoutConfig.UserLockAfter)
+	lockoutConfig.IPLockAfter = getEnvInt("LOGIN_IP_LOCK_AFTER", lockoutConfig.IPLockAfter)
+	lockoutConfig.LockoutDuration = getEnvDuration("LOGIN_LOCKOUT", lockoutConfig.LockoutDuration)
+	lockoutService := lockout.NewService(repository.GetDB(), lockoutConfig)
+	go runPeriodically(bgCtx, 10*time.Minute, "Login failures cleanup", lockoutService.PurgeExpired)
+	if interval := getEnvDuration("AUDIT_CHECKPOINT_INTERVAL", time.Hour); auditSigner != nil && interval > 0 {
+		go runPeriodically(bgCtx, interval, "Audit checkpoint", func() error {
+			c, err := auditService.Checkpoint()
+			if c != nil {
+				// Копия точки вне БД: по журналу сервера видно, если хвост аудита удалят вместе с точками
+				log.Printf("Audit checkpoint %d: entry %d hash %s", c.ID, c.EntryID, c.EntryHash)
+			}
+			return err
+		})
+	}
+
+	// Адрес фронтенда — для ссылок в письмах и возврата после SSO
+	frontendURL := getEnvOrDefault("FRONTEND_URL", "http://localhost:"+getEnvOrDefault("WEB_PORT", "3000"))
+
+	// Почта: подтверждение email и сброс пароля (по умолчанию письма пишутся в журнал)
+	mailer, err := mail.New(mail.Config{
+		Driver:       getEnvOrDefault("MAIL_DRIVER", "log"),
+		From:         os.Getenv("MAIL_FROM"),
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     getEnvInt("SMTP_PORT", 587),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		SMTPSecurity: getEnvOrDefault("SMTP_SECURITY", "starttls"),
+		Dir:          getEnvOrDefault("MAIL_DIR", "mail"),
+	})
+	if err != nil {
+		log.Fatalf("Invalid mail configuration: %v", err)
+	}
+	go runPeriodically(bgCtx, time.Hour, "Email token cleanup", userService.PurgeExpiredTokens)
+
+	passkeyService, err := passkey.NewService(repository.GetDB(), challengeService, passkey.Config{
+		RPID:    getEnvOrDefault("WEBAUTHN_RP_ID", "localhost"),
+		RPName:  getEnvOrDefault("WEBAUTHN_RP_NAME", "OSPAB Panel"),
+		Origins: strings.Split(getEnvOrDefault("WEBAUTHN_RP_ORIGINS", "http://localhost:"+getEnvOrDefault("WEB_PORT", "3000")), ","),
+	})
+	if err != nil {
+		log.Fatalf("Invalid WebAuthn configuration: %v", err)
+	}
+
+	ssoService := sso.NewService(challengeService, userService, rbacService, sso.Config{
+		Issuer:       os.Getenv("OIDC_ISSUER"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  getEnvOrDefault("OIDC_REDIRECT_URL", "http://localhost:"+getEnvOrDefault("API_PORT", "5000")+"/api/auth/oidc/callback"),
+		Scopes:       strings.Fields(getEnvOrDefault("OIDC_SCOPES", "openid profile email")),
+		GroupsClaim:  getEnvOrDefault("OIDC_GROUPS_CLAIM", "groups"),
+		RoleMapping:  rbac.ParseGroupMapping(os.Getenv("OIDC_ROLE_MAP")),
+		DefaultRole:  getEnvOrDefault("OIDC_DEFAULT_ROLE", rbac.DefaultRole),
+		FrontendURL:  getEnvOrDefault("OIDC_FRONTEND_URL", frontendURL),
+	})
+
+	// Проверка пароля: локальные пользователи, затем каталог LDAP (если задан LDAP_URL)
+	authProviders := authn.Chain{authn.NewLocal(userService)}
+	if url := os.Getenv("LDAP_URL"); url != "" {
+		ldapProvider, err := authn.NewLDAP(authn.LDAPConfig{
+			URL:                url,
+			StartTLS:           os.Getenv("LDAP_STARTTLS") == "true",
+			InsecureSkipVerify: os.Getenv("LDAP_INSECURE_SKIP_VERIFY") == "true",
+			BindDN:             os.Getenv("LDAP_BIND_DN"),
+			BindPassword:       os.Getenv("LDAP_BIND_PASSWORD"),
+			BaseDN:             os.Getenv("LDAP_BASE_DN"),
+			UserFilter:         getEnvOrDefault("LDAP_USER_FILTER", "(uid=%s)"),
+			UsernameAttr:       getEnvOrDefault("LDAP_USERNAME_ATTR", "uid"),
+			EmailAttr:          getEnvOrDefault("LDAP_EMAIL_ATTR", "mail"),
+			IDAttr:             os.Getenv("LDAP_ID_ATTR"),
+			GroupAttr:          getEnvOrDefault("LDAP_GROUP_ATTR", "memberOf"),
+			GroupFilter:        os.Getenv("LDAP_GROUP_FILTER"),
+			GroupBaseDN:        os.Getenv("LDAP_GROUP_BASE_DN"),
+			RoleMapping:        rbac.ParseGroupMapping(os.Getenv("LDAP_ROLE_MAP")),
+			DefaultRole:        getEnvOrDefault("LDAP_DEFAULT_ROLE", rbac.DefaultRole),
+			Timeout:            getEnvDuration("LDAP_TIMEOUT", 10*time.Second),
+		}, userService, rbacService)
+		if err != nil {
+			log.Fatalf("Invalid LDAP configuration: %v", err)
+		}
+		authProviders = append(authProviders, ldapProvider)
+	}
+
 	// Инициализация API обработчиков
-	apiHandler := api.NewHandler(userService, serverService, hvFactory, jwtManager)
+	apiHandler := api.NewHandler(userService, serverService, hvFactory, jwtManager, inventoryService, syncer, confirmService, rbacService, orgService, aclService, sessionService, challengeService, settingsService, passkeyService, ssoService, authProviders, apikey.NewService(repository.GetDB()), lockoutService, auditService, mailer, frontendURL)
 
 	// Создание роутеров
 	apiRouter := apiHandler.SetupRoutes()
@@ -59,15 +235,17 @@ func main() {
 
 	// Graceful shutdown
 	waitForShutdown()
+	stopBackground()
 }
 
 func startAPIServer(router *mux.Router) {
 	port := getEnvOrDefault("API_PORT", "5000")
+	// WriteTimeout увеличен: массовые операции над инстансами длиннее обычного запроса
 	server := &http.Server{
 		Addr:         ":" + port,
 		Handler:      router,
 		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
+		WriteTimeout: 2 * time.Minute,
 		IdleTimeout:  60 * time.Second,
 	}
 
@@ -145,3 +323,101 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// rotateKeys — команда "server rotate-keys": переносит учётные данные серверов в текущее
+// хранилище секретов, перешифровывает их и секреты TOTP активным ключом и привязывает
+// к записям. Код выхода 1 — часть значений не удалось перешифровать.
+func rotateKeys(keyring *crypto.Keyring, servers *server.Service, users *user.Service) int {
+	code := 0
+	n, err := servers.RotateCredentials()
+	log.Printf("Server credentials rewritten: %d", n)
+	if err != nil {
+		log.Printf("Server credentials rotation: %v", err)
+		code = 1
+	}
+	n, err = users.RotateTOTPSecrets()
+	log.Printf("TOTP secrets re-encrypted with key %q: %d", keyring.ActiveID(), n)
+	if err != nil {
+		log.Printf("TOTP secrets rotation: %v", err)
+		code = 1
+	}
+	return code
+}
+
+// verifyAudit — команда "server audit-verify": проверяет журнал аудита, печатает отчёт
+// в JSON и возвращает код выхода (0 — цепочка цела, 1 — нарушена, 2 — ошибка)
+func verifyAudit(svc *audit.Service) int {
+	report, err := svc.Verify()
+	if err != nil {
+		log.Printf("Audit verification failed: %v", err)
+		return 2
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(report)
+	if !report.OK {
+		return 1
+	}
+	return 0
+}
+
+// runPeriodically вызывает fn каждые interval до отмены ctx (фоновая очистка)
+func runPeriodically(ctx context.Context, interval time.Duration, name string, fn func() error) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := fn(); err != nil {
+				log.Printf("%s failed: %v", name, err)
+			}
+		}
+	}
+}
+
+// getEnvDuration читает длительность вида "30s"/"5m"; "0" или "off" отключают
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	if value == "off" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+// getEnvInt читает целое число; при ошибке — значение по умолчанию
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// getEnvBool читает логическое значение (true/false, 1/0); при ошибке — значение по умолчанию
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using %t", key, value, defaultValue)
+		return defaultValue
+	}
+	return b
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"ospab-panel/internal/core/password"
+	"ospab-panel/internal/core/user"
+	"ospab-panel/internal/infra/mail"
+)
+
+// GET /api/me — текущий пользователь
+func (h *Handler) GetMe(w http.ResponseWriter, r *http.Request) {
+	p, err := h.userService.Profile(atoi(r.Header.Get("X-User-ID")))
+	if err != nil {
+		h.sendError(w, accountErrStatus(err), err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, p)
+}
+
+// PATCH /api/me — {"username"}; в токенах новый логин появится после refresh
+func (h *Handler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	var req user.UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	p, err := h.userService.UpdateProfile(atoi(r.Header.Get("X-User-ID")), &req)
+	if err != nil {
+		h.sendError(w, accountErrStatus(err), err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, p)
+}
+
+// POST /api/me/email — {"email", "password"}; адрес меняется после перехода по ссылке из письма
+func (h *Handler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	var req user.ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	u, email, token, err := h.userService.RequestEmailChange(atoi(r.Header.Get("X-User-ID")), req.Email, req.Password)
+	if err != nil {
+		h.sendError(w, accountErrStatus(err), err.Error())
+		return
+	}
+	h.deliver(&mail.Message{
+		To:      email,
+		Subject: "Подтверждение нового email — OSPAB Panel",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы привязать этот адрес к учётной записи, откройте ссылку:\n%s\n\n"+
+			"Ссылка действует %d часов. Если вы не меняли адрес, проигнорируйте письмо.\n",
+			u.Username, h.frontendLink("/verify", token), int(user.VerifyEmailTTL/time.Hour)),
+	})
+	// Прежний адрес узнаёт о запросе: если его сделал не владелец, это повод сменить пароль
+	h.deliver(&mail.Message{
+		To:      u.Email,
+		Subject: "Запрошена смена email — OSPAB Panel",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nДля вашей учётной записи запрошена смена адреса на %s (IP %s).\n"+
+			"Адрес изменится только после подтверждения. Если это были не вы, смените пароль.\n",
+			u.Username, email, clientIP(r)),
+	})
+	h.sendJSON(w, http.StatusAccepted, map[string]string{"message": "На новый адрес отправлено письмо для подтверждения"})
+}
+
+// DELETE /api/me — {"password" | "confirm", "delete_servers"}; удаляет учётную запись
+func (h *Handler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	var req user.DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	u, secretRefs, err := h.userService.DeleteAccount(atoi(r.Header.Get("X-User-ID")), &req)
+	if err != nil {
+		h.sendError(w, accountErrStatus(err), err.Error())
+		return
+	}
+	// Учётные данные удалённых серверов во внешнем хранилище каскад не затрагивает
+	h.serverService.DeleteSecrets(secretRefs...)
+	// Сессии удалены каскадно, поэтому выданные access-токены больше не принимаются
+	if err := h.sessions.RevokeAccessToken(r.Header.Get("X-Token-ID"), time.Now().Add(h.jwtManager.AccessTTL())); err != nil {
+		log.Printf("Revoke token of deleted user %d: %v", u.ID, err)
+	}
+	h.loginSucceeded(u.Username)
+	log.Printf("User %d (%s) deleted own account from %s", u.ID, u.Username, clientIP(r))
+	h.deliver(&mail.Message{
+		To:      u.Email,
+		Subject: "Учётная запись удалена — OSPAB Panel",
+		Body:    fmt.Sprintf("Здравствуйте, %s!\n\nВаша учётная запись в панели удалена.\n", u.Username),
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/me/sessions — действующие сессии; текущая отмечена "current"
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	list, err := h.sessions.ListActive(atoi(r.Header.Get("X-User-ID")))
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for _, s := range list {
+		s.Current = s.ID == r.Header.Get("X-Session-ID")
+	}
+	h.sendJSON(w, http.StatusOK, list)
+}
+
+// DELETE /api/me/sessions/{id} — завершить сессию на другом устройстве
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if err := h.sessions.Revoke(atoi(r.Header.Get("X-User-ID")), mux.Vars(r)["id"]); err != nil {
+		h.sendError(w, accountErrStatus(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isPasswordViolation — пароль отклонён политикой; err.Error() — сообщение для пользователя
+func isPasswordViolation(err error) bool {
+	var v *password.Violation
+	return errors.As(err, &v)
+}
+
+func accountErrStatus(err error) int {
+	switch {
+	case errors.Is(err, user.ErrInvalidUsername), errors.Is(err, user.ErrInvalidEmail), errors.Is(err, user.ErrEmailUnchanged),
+		errors.Is(err, user.ErrConfirmation), isPasswordViolation(err):
+		return http.StatusBadRequest
+	case errors.Is(err, user.ErrInvalidPassword):
+		return http.StatusForbidden
+	case errors.Is(err, user.ErrUsernameTaken), errors.Is(err, user.ErrEmailTaken), errors.Is(err, user.ErrExternalAccount),
+		errors.Is(err, user.ErrLastAdmin), errors.Is(err, user.ErrSoleOwner), errors.Is(err, user.ErrHasServers):
+		return http.StatusConflict
+	case errors.Is(err, user.ErrTokenThrottled):
+		return http.StatusTooManyRequests
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ospab-panel/internal/core/confirm"
+	"ospab-panel/internal/hypervisor"
+)
+
+// Запросы состояния — доступны для любого бэкенда
+const (
+	actionStatus = "status"
+	actionConfig = "config"
+)
+
+// Действия, меняющие состояние инстанса (допустимы в массовом режиме)
+var mutatingActions = map[string]bool{
+	hypervisor.ActionStart:     true,
+	hypervisor.ActionStop:      true,
+	hypervisor.ActionShutdown:  true,
+	hypervisor.ActionRestart:   true,
+	hypervisor.ActionReset:     true,
+	hypervisor.ActionSuspend:   true,
+	hypervisor.ActionResume:    true,
+	hypervisor.ActionHibernate: true,
+	hypervisor.ActionSnapshot:  true,
+	hypervisor.ActionRollback:  true,
+	hypervisor.ActionReinstall: true,
+	hypervisor.ActionDelete:    true,
+}
+
+// Разрушительные действия: запрещены для защищённых инстансов и требуют
+// токена подтверждения, полученного через .../confirm
+var destructiveActions = map[string]bool{
+	hypervisor.ActionDelete:    true,
+	hypervisor.ActionRollback:  true,
+	hypervisor.ActionReinstall: true,
+}
+
+var (
+	errUnsupportedAction = errors.New("unsupported action")
+	errInstanceProtected = errors.New("instance is protected")
+)
+
+type actionParams struct {
+	SnapshotName string
+	Shutdown     hypervisor.ShutdownOptions
+}
+
+func knownAction(action string) bool {
+	return mutatingActions[action] || action == actionStatus || action == actionConfig
+}
+
+// checkAction проверяет, что бэкенд поддерживает действие для типа инстанса
+func checkAction(client hypervisor.HypervisorClient, action, instType string) error {
+	if action == actionStatus || action == actionConfig {
+		return nil
+	}
+	return hypervisor.CheckAction(client, instType, action)
+}
+
+// actionErrStatus подбирает HTTP-статус для ошибки действия
+func actionErrStatus(err error) int {
+	switch {
+	case errors.Is(err, errInstanceProtected):
+		return http.StatusConflict
+	case errors.Is(err, confirm.ErrInvalidToken):
+		return http.StatusPreconditionRequired
+	case errors.Is(err, hypervisor.ErrActionNotSupported), errors.Is(err, errUnsupportedAction), errors.Is(err, hypervisor.ErrUnsupportedHypervisor):
+		return http.StatusBadRequest
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// checkDestructive проверяет защиту инстанса (флаг панели и protection гипервизора)
+// и погашает токен подтверждения. Токен не тратится, если инстанс защищён.
+func (h *ServerHandlers) checkDestructive(ctx context.Context, client hypervisor.HypervisorClient, uid int, t confirm.Target, action, token string) error {
+	if err := h.checkNotProtected(ctx, client, t); err != nil {
+		return err
+	}
+	return h.confirm.Consume(uid, t, action, token)
+}
+
+func (h *ServerHandlers) checkNotProtected(ctx context.Context, client hypervisor.HypervisorClient, t confirm.Target) error {
+	protected, err := h.inventory.IsProtected(t.ServerID, t.InstanceType, t.InstanceID)
+	if err != nil {
+		return err
+	}
+	if !protected {
+		if protected, err = client.GetProtection(ctx, t.InstanceType, t.InstanceID); err != nil {
+			return err
+		}
+	}
+	if protected {
+		return errInstanceProtected
+	}
+	return nil
+}
+
+// runInstanceAction выполняет действие на подключённом клиенте.
+// Для status/config возвращает данные, для остальных — nil.
+func runInstanceAction(ctx context.Context, client hypervisor.HypervisorClient, action, instType, instID string, p actionParams) (interface{}, error) {
+	if err := checkAction(client, action, instType); err != nil {
+		return nil, err
+	}
+	switch action {
+	case hypervisor.ActionStart:
+		return nil, client.StartInstance(ctx, instType, instID)
+	case hypervisor.ActionStop:
+		return nil, client.StopInstance(ctx, instType, instID)
+	case hypervisor.ActionShutdown:
+		return nil, client.ShutdownInstance(ctx, instType, instID, p.Shutdown)
+	case hypervisor.ActionRestart:
+		return nil, client.RestartInstance(ctx, instType, instID)
+	case hypervisor.ActionReset:
+		return nil, client.ResetInstance(ctx, instType, instID)
+	case hypervisor.ActionSuspend:
+		return nil, client.SuspendInstance(ctx, instType, instID)
+	case hypervisor.ActionResume:
+		return nil, client.ResumeInstance(ctx, instType, instID)
+	case hypervisor.ActionHibernate:
+		return nil, client.HibernateInstance(ctx, instType, instID)
+	case hypervisor.ActionSnapshot:
+		name := p.SnapshotName
+		if name == "" {
+			name = fmt.Sprintf("panel-%s", time.Now().UTC().Format("20060102-150405"))
+		}
+		return nil, client.CreateSnapshot(ctx, instType, instID, name)
+	case hypervisor.ActionRollback:
+		return nil, client.RollbackSnapshot(ctx, instType, instID, p.SnapshotName)
+	case hypervisor.ActionReinstall:
+		return nil, client.ReinstallInstance(ctx, instType, instID)
+	case hypervisor.ActionDelete:
+		return nil, client.DeleteInstance(ctx, instType, instID)
+	case actionStatus:
+		st, err := client.GetInstanceStatus(ctx, instType, instID)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"status": st}, nil
+	case actionConfig:
+		return client.GetInstanceConfig(ctx, instType, instID)
+	default:
+		return nil, errUnsupportedAction
+	}
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"ospab-panel/internal/core/rbac"
+	"ospab-panel/internal/core/user"
+	"ospab-panel/internal/infra/mail"
+)
+
+// sendPasswordSetupEmail отправляет ссылку установки пароля, выданную администратором
+func (h *Handler) sendPasswordSetupEmail(u *user.User, token, intro string) {
+	h.deliver(&mail.Message{
+		To:      u.Email,
+		Subject: "Установка пароля — OSPAB Panel",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\n%s Задайте пароль по ссылке:\n%s\n\nСсылка действует %d часов и срабатывает один раз.\n",
+			u.Username, intro, h.frontendLink("/reset", token), int(user.PasswordSetupTTL/time.Hour)),
+	})
+}
+
+// GET /api/admin/users?q=&role=&disabled=&page=&per_page=
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := user.ListFilter{Query: q.Get("q"), Role: q.Get("role"), Page: atoi(q.Get("page")), PerPage: atoi(q.Get("per_page"))}
+	if v := q.Get("disabled"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			h.sendError(w, http.StatusBadRequest, "disabled: true или false")
+			return
+		}
+		f.Disabled = &b
+	}
+	page, err := h.userService.ListUsers(f)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, page)
+}
+
+// GET /api/admin/users/{id}
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	p, err := h.userService.Profile(atoi(mux.Vars(r)["id"]))
+	if err != nil {
+		h.sendError(w, accountErrStatus(err), err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, p)
+}
+
+// POST /api/admin/users — {"username", "email", "role", "password"?}; без пароля
+// пользователю уходит письмо со ссылкой для его установки
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var req user.AdminCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if req.Role != "" && !h.rbac.Exists(req.Role) {
+		h.sendError(w, http.StatusBadRequest, "Неизвестная роль")
+		return
+	}
+	u, token, err := h.userService.AdminCreateUser(&req)
+	if err != nil {
+		h.sendError(w, accountErrStatus(err), err.Error())
+		return
+	}
+	if token != "" {
+		h.sendPasswordSetupEmail(u, token, "Для вас создана учётная запись в панели.")
+	}
+	log.Printf("User %d (%s) created by admin %s", u.ID, u.Username, r.Header.Get("X-User-ID"))
+	h.sendJSON(w, http.StatusCreated, u)
+}
+
+// POST /api/admin/users/{id}/disable и /enable. Отключение завершает все сессии,
+// API-ключи перестают приниматься.
+func (h *Handler) SetUserDisabled(disabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := atoi(mux.Vars(r)["id"])
+		if disabled && id == atoi(r.Header.Get("X-User-ID")) {
+			h.sendError(w, http.StatusBadRequest, "Нельзя отключить собственную учётную запись")
+			return
+		}
+		u, err := h.userService.SetDisabled(id, disabled)
+		if err != nil {
+			h.sendError(w, accountErrStatus(err), err.Error())
+			return
+		}
+		if disabled {
+			if err := h.sessions.RevokeAll(id); err != nil {
+				h.sendError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		log.Printf("User %d disabled=%t by admin %s", id, disabled, r.Header.Get("X-User-ID"))
+		h.sendJSON(w, http.StatusOK, u)
+	}
+}
+
+// POST /api/admin/users/{id}/reset-password — прежний пароль перестаёт действовать,
+// сессии завершаются, пользователь получает ссылку для установки нового
+func (h *Handler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	u, token, err := h.userService.ForcePasswordReset(atoi(mux.Vars(r)["id"]))
+	if err != nil {
+		h.sendError(w, accountErrStatus(err), err.Error())
+		return
+	}
+	if err := h.sessions.RevokeAll(u.ID); err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendPasswordSetupEmail(u, token, "Администратор сбросил пароль вашей учётной записи.")
+	log.Printf("Password of user %d reset by admin %s", u.ID, r.Header.Get("X-User-ID"))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// POST /api/admin/users/{id}/impersonate — токены короткой сессии от имени пользователя.
+// Сессия помнит администратора (impersonator_id), в JWT он передаётся в claim "act".
+func (h *Handler) Impersonate(w http.ResponseWriter, r *http.Request) {
+	adminID := atoi(r.Header.Get("X-User-ID"))
+	target, err := h.userService.GetUserByID(atoi(mux.Vars(r)["id"]))
+	if err != nil {
+		h.sendError(w, accountErrStatus(err), err.Error())
+		return
+	}
+	switch {
+	case target.ID == adminID:
+		h.sendError(w, http.StatusBadRequest, "Нельзя войти от имени самого себя")
+		return
+	case target.Disabled:
+		h.sendError(w, http.StatusConflict, "Учётная запись отключена")
+		return
+	case h.rbac.Can(target.Role, rbac.PermUsersManage):
+		// Иначе действия одного администратора записывались бы на другого
+		h.sendError(w, http.StatusForbidden, "Нельзя войти от имени администратора")
+		return
+	}
+	sess, refresh, err := h.sessions.CreateImpersonation(target.ID, adminID, r.UserAgent(), clientIP(r))
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	token, err := h.jwtManager.GenerateToken(target.ID, target.Username, target.Role, sess.ID, false, adminID)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	auditDetail(r, "session_id", sess.ID)
+	h.sendJSON(w, http.StatusOK, user.LoginResponse{
+		Token:        token,
+		RefreshToken: refresh,
+		ExpiresIn:    int(h.jwtManager.AccessTTL() / time.Second),
+		User:         *target,
+	})
+}
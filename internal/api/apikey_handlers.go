@@ -0,0 +1,145 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"ospab-panel/internal/core/apikey"
+	"ospab-panel/internal/core/rbac"
+)
+
+// apiKeyFromRequest — ключ из X-API-Key или Authorization: Bearer ospab_...
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && strings.HasPrefix(token, apikey.Prefix) {
+		return token
+	}
+	return ""
+}
+
+// serveWithAPIKey — вариант AuthMiddleware для API-ключа. Сессии у ключа нет,
+// 2FA не подтверждена; область ключа проверяется вместе с ролью (permitted).
+func (h *Handler) serveWithAPIKey(w http.ResponseWriter, r *http.Request, key string, next http.HandlerFunc) {
+	p, err := h.apiKeys.Authenticate(key)
+	if err != nil {
+		if errors.Is(err, apikey.ErrInvalidKey) {
+			h.sendError(w, http.StatusUnauthorized, "Invalid API key")
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, "Failed to check API key")
+		return
+	}
+	r.Header.Set("X-User-ID", strconv.Itoa(p.UserID))
+	r.Header.Set("X-Username", p.Username)
+	r.Header.Set("X-User-Role", p.Role)
+	r.Header.Set("X-MFA", "false")
+	r.Header.Set("X-API-Key-ID", strconv.Itoa(p.KeyID))
+	r.Header.Set("X-API-Key-Scope", string(p.Scope))
+	r.Header.Del("X-Session-ID")
+	r.Header.Del("X-Token-ID")
+	r.Header.Del("X-Impersonator-ID")
+	next(w, r)
+}
+
+// keyScopeAllows — право входит в область API-ключа (для входа по JWT — всегда)
+func keyScopeAllows(r *http.Request, perm rbac.Permission) bool {
+	if r.Header.Get("X-API-Key-ID") == "" {
+		return true
+	}
+	return apikey.Scope(r.Header.Get("X-API-Key-Scope")).Allows(perm)
+}
+
+// Interactive закрывает маршрут для API-ключей: пароль, 2FA, ключи безопасности
+// и сами API-ключи меняются только после входа пользователем
+func (h *Handler) Interactive(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key-ID") != "" {
+			h.sendError(w, http.StatusForbidden, "Недоступно для API-ключа")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Personal — как Interactive, но закрывает маршрут и для администратора, вошедшего
+// от имени пользователя: учётные данные меняет только сам владелец
+func (h *Handler) Personal(next http.HandlerFunc) http.HandlerFunc {
+	return h.Interactive(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Impersonator-ID") != "" {
+			h.sendError(w, http.StatusForbidden, "Недоступно в режиме входа от имени пользователя")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// GET /api/me/api-keys
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	list, err := h.apiKeys.List(atoi(r.Header.Get("X-User-ID")))
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, list)
+}
+
+// POST /api/me/api-keys — {"name", "scope": "read|power|admin", "expires_at"?}; ключ показывается один раз
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req apikey.CreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	resp, err := h.apiKeys.Create(atoi(r.Header.Get("X-User-ID")), &req)
+	if err != nil {
+		h.sendError(w, apiKeyErrStatus(err), err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusCreated, resp)
+}
+
+// PATCH /api/me/api-keys/{id} — {"name"}
+func (h *Handler) RenameAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req apikey.RenameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if err := h.apiKeys.Rename(atoi(r.Header.Get("X-User-ID")), id, req.Name); err != nil {
+		h.sendError(w, apiKeyErrStatus(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/me/api-keys/{id} — отзыв ключа
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.apiKeys.Revoke(atoi(r.Header.Get("X-User-ID")), id); err != nil {
+		h.sendError(w, apiKeyErrStatus(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func apiKeyErrStatus(err error) int {
+	switch {
+	case errors.Is(err, apikey.ErrInvalidName), errors.Is(err, apikey.ErrInvalidScope), errors.Is(err, apikey.ErrExpiryPast):
+		return http.StatusBadRequest
+	case errors.Is(err, apikey.ErrTooManyKeys):
+		return http.StatusConflict
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
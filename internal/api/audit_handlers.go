@@ -0,0 +1,450 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"ospab-panel/internal/core/audit"
+	"ospab-panel/internal/core/user"
+)
+
+const (
+	maxAuditBody   = 64 << 10 // тело большего размера в журнал не попадает
+	maxAuditParams = 16 << 10
+)
+
+// Заголовки, которые выставляет AuthMiddleware. От клиента они не принимаются.
+var identityHeaders = []string{"X-User-ID", "X-Username", "X-User-Role", "X-Session-ID", "X-Token-ID", "X-MFA",
+	"X-Impersonator-ID", "X-API-Key-ID", "X-API-Key-Scope"}
+
+// Изменяющие запросы, которые не пишутся в журнал: обновление токенов и выдача
+// одноразовых challenge для WebAuthn
+var auditSkip = map[string]bool{
+	"auth.refresh":               true,
+	"auth.webauthn.login.begin":  true,
+	"me.webauthn.register.begin": true,
+}
+
+// Поля тела и query, значения которых заменяются на "***"
+var secretParamParts = []string{"password", "secret", "token", "code", "otp", "recovery"}
+
+type auditKey struct{}
+
+// auditState — данные, которые обработчик добавляет к записи своего запроса
+type auditState struct {
+	mu        sync.Mutex
+	actorID   int
+	actorName string
+	details   map[string]interface{}
+	extra     []audit.Entry
+}
+
+func auditStateFrom(r *http.Request) *auditState {
+	st, _ := r.Context().Value(auditKey{}).(*auditState)
+	return st
+}
+
+// auditActor — пользователь, который выполнил вход в этом запросе (до выдачи токенов
+// заголовки X-User-ID ещё не выставлены)
+func auditActor(r *http.Request, u *user.User) {
+	if st := auditStateFrom(r); st != nil {
+		st.mu.Lock()
+		st.actorID, st.actorName = u.ID, u.Username
+		st.mu.Unlock()
+	}
+}
+
+// auditDetail добавляет значение в params записи под ключом details
+func auditDetail(r *http.Request, key string, value interface{}) {
+	if st := auditStateFrom(r); st != nil {
+		st.mu.Lock()
+		if st.details == nil {
+			st.details = map[string]interface{}{}
+		}
+		st.details[key] = value
+		st.mu.Unlock()
+	}
+}
+
+// auditAlso добавляет отдельную запись (например, по каждому инстансу массового
+// действия). Пользователь, адрес и время берутся из основной записи запроса.
+func auditAlso(r *http.Request, e audit.Entry) {
+	if st := auditStateFrom(r); st != nil {
+		st.mu.Lock()
+		st.extra = append(st.extra, e)
+		st.mu.Unlock()
+	}
+}
+
+// auditWriter запоминает код ответа и начало тела ошибки
+type auditWriter struct {
+	http.ResponseWriter
+	status  int
+	errBody bytes.Buffer
+}
+
+func (w *auditWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.status >= 400 && w.errBody.Len() < 1024 {
+		w.errBody.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *auditWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// Audit пишет в журнал изменяющие запросы (POST/PUT/PATCH/DELETE) и GET-маршруты с
+// именем (вход через SSO, выгрузка журнала). Действие — имя маршрута, где {action}
+// и другие переменные подставлены из пути.
+func (h *Handler) Audit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, k := range identityHeaders {
+			r.Header.Del(k)
+		}
+		route := mux.CurrentRoute(r)
+		if route == nil || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		name := route.GetName()
+		mutating := r.Method != http.MethodGet && r.Method != http.MethodHead
+		if auditSkip[name] || (!mutating && name == "") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		started := time.Now()
+		var body []byte
+		if r.Body != nil {
+			body, _ = io.ReadAll(io.LimitReader(r.Body, maxAuditBody+1))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		}
+		st := &auditState{}
+		r = r.WithContext(context.WithValue(r.Context(), auditKey{}, st))
+		aw := &auditWriter{ResponseWriter: w}
+		next.ServeHTTP(aw, r)
+
+		e := auditEntry(r, name, started)
+		if aw.status == 0 {
+			aw.status = http.StatusOK
+		}
+		e.Status = aw.status
+		e.Result = resultForStatus(aw.status)
+		if aw.status >= 400 {
+			e.Error = errorMessage(aw.errBody.Bytes())
+		}
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		if e.ActorID == 0 {
+			e.ActorID, e.ActorName = st.actorID, st.actorName
+		}
+		var loginName string
+		e.Params, loginName = auditParams(r, body, st.details)
+		if e.ActorID == 0 && e.ActorName == "" {
+			// Неудачный вход: пользователь известен только по введённому логину
+			e.ActorName = loginName
+		}
+		if err := h.audit.Record(e); err != nil {
+			log.Printf("Audit record %s failed: %v", e.Action, err)
+		}
+		for _, x := range st.extra {
+			x.CreatedAt, x.DurationMS = e.CreatedAt, e.DurationMS
+			x.ActorID, x.ActorName, x.ImpersonatorID, x.APIKeyID = e.ActorID, e.ActorName, e.ImpersonatorID, e.APIKeyID
+			x.IP, x.UserAgent, x.Method, x.Path = e.IP, e.UserAgent, e.Method, e.Path
+			if x.Result == "" {
+				x.Result = e.Result
+			}
+			if err := h.audit.Record(&x); err != nil {
+				log.Printf("Audit record %s failed: %v", x.Action, err)
+			}
+		}
+	})
+}
+
+// auditEntry — запись без результата: пользователь из заголовков AuthMiddleware,
+// объект действия из переменных пути
+func auditEntry(r *http.Request, name string, started time.Time) *audit.Entry {
+	route := mux.CurrentRoute(r)
+	tmpl, _ := route.GetPathTemplate()
+	vars := mux.Vars(r)
+	e := &audit.Entry{
+		CreatedAt:      started,
+		DurationMS:     time.Since(started).Milliseconds(),
+		ActorID:        atoi(r.Header.Get("X-User-ID")),
+		ActorName:      r.Header.Get("X-Username"),
+		ImpersonatorID: atoi(r.Header.Get("X-Impersonator-ID")),
+		APIKeyID:       atoi(r.Header.Get("X-API-Key-ID")),
+		IP:             clientIP(r),
+		UserAgent:      r.UserAgent(),
+		Method:         r.Method,
+		Path:           r.URL.Path,
+	}
+	if name == "" {
+		e.Action = r.Method + " " + tmpl
+	} else {
+		pairs := make([]string, 0, 2*len(vars))
+		for k, v := range vars {
+			pairs = append(pairs, "{"+k+"}", v)
+		}
+		e.Action = strings.NewReplacer(pairs...).Replace(name)
+	}
+	var target []string
+	segs := strings.Split(tmpl, "/")
+	for i, seg := range segs {
+		v, ok := strings.CutPrefix(seg, "{")
+		if !ok || i == 0 {
+			continue
+		}
+		v = vars[strings.TrimSuffix(v, "}")]
+		switch {
+		case segs[i-1] == "servers":
+			e.ServerID = atoi(v)
+		case seg == "{instanceId}":
+			e.InstanceID = v
+		case seg == "{action}":
+			// уже в названии действия
+		default:
+			target = append(target, segs[i-1], v)
+		}
+	}
+	e.Target = strings.Join(target, "/")
+	return e
+}
+
+func resultForStatus(status int) string {
+	switch {
+	case status < 400:
+		return audit.ResultSuccess
+	case status == http.StatusUnauthorized, status == http.StatusForbidden, status == http.StatusTooManyRequests:
+		return audit.ResultDenied
+	default:
+		return audit.ResultFailure
+	}
+}
+
+// errorMessage — текст ошибки из ответа sendError ({"message"}) или sendErr ({"error"})
+func errorMessage(body []byte) string {
+	var resp ErrorResponse
+	if json.Unmarshal(body, &resp) == nil {
+		if resp.Message != "" {
+			return resp.Message
+		}
+		return resp.Error
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// auditParams — query и JSON-тело запроса без секретов; второе значение — логин из
+// тела (для записей о неудачном входе)
+func auditParams(r *http.Request, body []byte, details map[string]interface{}) (json.RawMessage, string) {
+	params := map[string]interface{}{}
+	if q := r.URL.Query(); len(q) > 0 {
+		query := map[string]interface{}{}
+		for k, v := range q {
+			query[k] = redactParam(k, strings.Join(v, ","))
+		}
+		params["query"] = query
+	}
+	var username string
+	if len(body) > maxAuditBody {
+		params["body_truncated"] = true
+	} else if len(bytes.TrimSpace(body)) > 0 {
+		var v interface{}
+		if json.Unmarshal(body, &v) == nil {
+			if m, ok := v.(map[string]interface{}); ok {
+				username, _ = m["username"].(string)
+			}
+			params["body"] = redactParam("", v)
+		}
+	}
+	if len(details) > 0 {
+		params["details"] = details
+	}
+	if len(params) == 0 {
+		return nil, username
+	}
+	raw, err := json.Marshal(params)
+	if err != nil || len(raw) > maxAuditParams {
+		raw = []byte(`{"truncated":true}`)
+	}
+	return raw, username
+}
+
+func redactParam(key string, v interface{}) interface{} {
+	lk := strings.ToLower(key)
+	for _, part := range secretParamParts {
+		if strings.Contains(lk, part) {
+			return "***"
+		}
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, x := range t {
+			t[k] = redactParam(k, x)
+		}
+	case []interface{}:
+		for i, x := range t {
+			t[i] = redactParam("", x)
+		}
+	}
+	return v
+}
+
+func auditFilter(r *http.Request) (audit.Filter, error) {
+	q := r.URL.Query()
+	f := audit.Filter{
+		UserID:   atoi(q.Get("user_id")),
+		ServerID: atoi(q.Get("server_id")),
+		Action:   q.Get("action"),
+		Result:   q.Get("result"),
+		Page:     atoi(q.Get("page")),
+		PerPage:  atoi(q.Get("per_page")),
+	}
+	var err error
+	if f.From, err = parseAuditTime(q.Get("from")); err != nil {
+		return f, fmt.Errorf("from: %w", err)
+	}
+	if f.To, err = parseAuditTime(q.Get("to")); err != nil {
+		return f, fmt.Errorf("to: %w", err)
+	}
+	return f, nil
+}
+
+// parseAuditTime принимает RFC 3339 или дату YYYY-MM-DD (начало дня UTC)
+func parseAuditTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+// GET /api/admin/audit?user_id=&server_id=&action=&result=&from=&to=&page=&per_page=
+func (h *Handler) ListAudit(w http.ResponseWriter, r *http.Request) {
+	f, err := auditFilter(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := h.audit.List(f)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, page)
+}
+
+// GET /api/admin/audit/verify — проверка цепочки хэшей и подписей контрольных точек.
+// Нарушение — не ошибка запроса: ответ 200 с ok=false и первой найденной проблемой.
+func (h *Handler) VerifyAudit(w http.ResponseWriter, r *http.Request) {
+	report, err := h.audit.Verify()
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, report)
+}
+
+var auditCSVHeader = []string{"id", "created_at", "actor_id", "actor_name", "impersonator_id", "api_key_id", "ip", "user_agent",
+	"action", "method", "path", "server_id", "instance_id", "target", "result", "status", "error", "duration_ms", "params", "prev_hash", "hash"}
+
+// GET /api/admin/audit/export?format=csv|json и фильтры как у /api/admin/audit —
+// записи по возрастанию времени, не больше audit.MaxExport
+func (h *Handler) ExportAudit(w http.ResponseWriter, r *http.Request) {
+	f, err := auditFilter(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		h.sendError(w, http.StatusBadRequest, "format: csv или json")
+		return
+	}
+	name := "audit-" + time.Now().UTC().Format("20060102-150405") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		sep := "["
+		err = h.audit.Export(f, func(e *audit.Entry) error {
+			if _, err := io.WriteString(w, sep); err != nil {
+				return err
+			}
+			sep = ","
+			return enc.Encode(e)
+		})
+		if sep == "[" {
+			io.WriteString(w, "[")
+		}
+		io.WriteString(w, "]\n")
+	} else {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := csv.NewWriter(w)
+		cw.Write(auditCSVHeader)
+		err = h.audit.Export(f, func(e *audit.Entry) error {
+			return cw.Write([]string{
+				strconv.FormatInt(e.ID, 10), e.CreatedAt.UTC().Format(time.RFC3339Nano), optInt(e.ActorID), csvSafe(e.ActorName),
+				optInt(e.ImpersonatorID), optInt(e.APIKeyID), e.IP, csvSafe(e.UserAgent), e.Action, e.Method, csvSafe(e.Path),
+				optInt(e.ServerID), csvSafe(e.InstanceID), csvSafe(e.Target), e.Result, strconv.Itoa(e.Status), csvSafe(e.Error),
+				strconv.FormatInt(e.DurationMS, 10), csvSafe(string(e.Params)), e.PrevHash, e.Hash,
+			})
+		})
+		cw.Flush()
+	}
+	if err != nil {
+		// Заголовки уже отправлены: выгрузка обрывается, клиент получит неполный файл
+		log.Printf("Audit export failed: %v", err)
+	}
+}
+
+func optInt(v int) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.Itoa(v)
+}
+
+// csvSafe не даёт табличным редакторам выполнить значение как формулу
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"ospab-panel/internal/core/challenge"
+	"ospab-panel/internal/core/session"
+	"ospab-panel/internal/core/user"
+)
+
+// issueTokens открывает сессию и выдаёт пару access/refresh — общий путь для всех способов входа.
+// mfa — вход подтверждён вторым фактором.
+func (h *Handler) issueTokens(r *http.Request, u *user.User, mfa bool) (*user.LoginResponse, error) {
+	auditActor(r, u)
+	sess, refresh, err := h.sessions.Create(u.ID, r.UserAgent(), clientIP(r), mfa)
+	if err != nil {
+		return nil, err
+	}
+	token, err := h.jwtManager.GenerateToken(u.ID, u.Username, u.Role, sess.ID, mfa, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &user.LoginResponse{
+		Token:            token,
+		RefreshToken:     refresh,
+		ExpiresIn:        int(h.jwtManager.AccessTTL() / time.Second),
+		User:             *u,
+		MFASetupRequired: !u.TOTPEnabled && h.mfaRequiredFor(u.Role),
+	}, nil
+}
+
+// completeLogin завершает вход после первого фактора: при включённой 2FA, если второй
+// фактор ещё не подтверждён, выдаёт challenge для /api/auth/2fa/verify, иначе — токены
+func (h *Handler) completeLogin(w http.ResponseWriter, r *http.Request, u *user.User, mfa bool) {
+	auditActor(r, u)
+	if h.accountDisabled(w, u) {
+		return
+	}
+	if u.TOTPEnabled && !mfa {
+		token, err := h.challenges.Issue(challenge.KindMFA, u.ID, "", mfaChallengeTTL)
+		if err != nil {
+			h.sendError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		h.sendJSON(w, http.StatusOK, user.MFAChallengeResponse{MFARequired: true, ChallengeToken: token, ExpiresIn: int(mfaChallengeTTL / time.Second)})
+		return
+	}
+	resp, err := h.issueTokens(r, u, mfa)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	h.sendJSON(w, http.StatusOK, resp)
+}
+
+// accountDisabled отвечает 403, если администратор отключил учётную запись
+func (h *Handler) accountDisabled(w http.ResponseWriter, u *user.User) bool {
+	if !u.Disabled {
+		return false
+	}
+	h.sendError(w, http.StatusForbidden, "Учётная запись отключена администратором")
+	return true
+}
+
+// POST /api/auth/refresh — обмен refresh-токена на новую пару (старый refresh погашается)
+func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req session.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	sess, refresh, err := h.sessions.Rotate(req.RefreshToken, clientIP(r))
+	if err != nil {
+		if errors.Is(err, session.ErrInvalidRefresh) || errors.Is(err, session.ErrRefreshReused) {
+			h.sendError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// Роль перечитывается из БД: изменения вступают в силу при следующем refresh
+	u, err := h.userService.GetUserByID(sess.UserID)
+	if err != nil || u.Disabled {
+		h.sendError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+	token, err := h.jwtManager.GenerateToken(u.ID, u.Username, u.Role, sess.ID, sess.MFA, sess.ImpersonatorID)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	h.sendJSON(w, http.StatusOK, user.LoginResponse{
+		Token:            token,
+		RefreshToken:     refresh,
+		ExpiresIn:        int(h.jwtManager.AccessTTL() / time.Second),
+		User:             *u,
+		MFASetupRequired: !u.TOTPEnabled && h.mfaRequiredFor(u.Role),
+	})
+}
+
+// POST /api/auth/logout — завершает текущую сессию и отзывает access-токен
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	uid := atoi(r.Header.Get("X-User-ID"))
+	if err := h.sessions.RevokeAccessToken(r.Header.Get("X-Token-ID"), time.Now().Add(h.jwtManager.AccessTTL())); err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := h.sessions.Revoke(uid, r.Header.Get("X-Session-ID")); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /api/me/password — смена пароля; все сессии завершаются, текущему клиенту выдаётся новая
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	uid := atoi(r.Header.Get("X-User-ID"))
+	var req user.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if err := h.userService.ChangePassword(uid, req.CurrentPassword, req.NewPassword); err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidPassword):
+			h.sendError(w, http.StatusForbidden, "Неверный текущий пароль")
+		case isPasswordViolation(err):
+			h.sendError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, user.ErrExternalAccount):
+			h.sendError(w, http.StatusConflict, "Пароль учётной записи хранится у внешнего провайдера")
+		default:
+			h.sendError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	if err := h.sessions.RevokeAll(uid); err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	u, err := h.userService.GetUserByID(uid)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// Отметка 2FA переносится из текущей сессии
+	resp, err := h.issueTokens(r, u, r.Header.Get("X-MFA") == "true")
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	h.sendJSON(w, http.StatusOK, resp)
+}
+
+// clientIP — адрес клиента. X-Forwarded-For учитывается только при TRUST_PROXY=true
+// (панель за обратным прокси), иначе заголовок легко подделать.
+func clientIP(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY") == "true" {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			return strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+		if ip := r.Header.Get("X-Real-IP"); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ospab-panel/internal/core/user"
+	"ospab-panel/internal/infra/mail"
+)
+
+type emailTokenRequest struct {
+	Token string `json:"token"`
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// deliver отправляет письмо в фоне: ответ API не ждёт SMTP и по времени ответа
+// нельзя понять, существует ли адрес
+func (h *Handler) deliver(msg *mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := h.mailer.Send(ctx, msg); err != nil {
+			log.Printf("Mail to %s failed: %v", msg.To, err)
+		}
+	}()
+}
+
+// frontendLink — ссылка на страницу фронтенда с токеном из письма
+func (h *Handler) frontendLink(page, token string) string {
+	return strings.TrimRight(h.frontendURL, "/") + page + "?" + url.Values{"token": {token}}.Encode()
+}
+
+// sendVerificationEmail выпускает токен и отправляет письмо подтверждения адреса
+func (h *Handler) sendVerificationEmail(u *user.User) error {
+	token, err := h.userService.IssueEmailVerification(u)
+	if err != nil {
+		return err
+	}
+	h.deliver(&mail.Message{
+		To:      u.Email,
+		Subject: "Подтверждение email — OSPAB Panel",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы подтвердить адрес, откройте ссылку:\n%s\n\n"+
+			"Ссылка действует %d часов. Если вы не регистрировались в панели, просто проигнорируйте письмо.\n",
+			u.Username, h.frontendLink("/verify", token), int(user.VerifyEmailTTL/time.Hour)),
+	})
+	return nil
+}
+
+// POST /api/auth/verify — {"token"} из письма подтверждения или смены email
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req emailTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	u, err := h.userService.VerifyEmail(req.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidToken):
+			h.sendError(w, http.StatusBadRequest, "Ссылка недействительна или устарела")
+		case errors.Is(err, user.ErrEmailTaken):
+			h.sendError(w, http.StatusConflict, "Адрес уже занят другой учётной записью")
+		default:
+			h.sendError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.sendJSON(w, http.StatusOK, u)
+}
+
+// POST /api/auth/verify/resend — повторить письмо подтверждения текущему пользователю
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	u, err := h.userService.GetUserByID(atoi(r.Header.Get("X-User-ID")))
+	if err != nil {
+		h.sendError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+	if u.EmailVerified {
+		h.sendError(w, http.StatusConflict, "Email уже подтверждён")
+		return
+	}
+	if err := h.sendVerificationEmail(u); err != nil {
+		if errors.Is(err, user.ErrTokenThrottled) {
+			h.sendError(w, http.StatusTooManyRequests, "Письмо уже отправлено, повторите через минуту")
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// POST /api/auth/forgot — {"email"}. Ответ одинаковый для любого адреса.
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req forgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Email) == "" {
+		h.sendError(w, http.StatusBadRequest, "Требуется email")
+		return
+	}
+	u, token, err := h.userService.IssuePasswordReset(req.Email)
+	switch {
+	case err == nil:
+		h.deliver(&mail.Message{
+			To:      u.Email,
+			Subject: "Сброс пароля — OSPAB Panel",
+			Body: fmt.Sprintf("Здравствуйте, %s!\n\nДля вашей учётной записи запрошен сброс пароля. Задать новый пароль:\n%s\n\n"+
+				"Ссылка действует %d минут и срабатывает один раз. Если вы не запрашивали сброс, проигнорируйте письмо — пароль не изменится.\n",
+				u.Username, h.frontendLink("/reset", token), int(user.ResetPasswordTTL/time.Minute)),
+		})
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, user.ErrExternalAccount), errors.Is(err, user.ErrTokenThrottled),
+		errors.Is(err, user.ErrAccountDisabled):
+		log.Printf("Password reset for %q from %s not sent: %v", req.Email, clientIP(r), err)
+	default:
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusAccepted, map[string]string{"message": "Если адрес зарегистрирован, на него отправлено письмо со ссылкой для сброса пароля"})
+}
+
+// POST /api/auth/reset — {"token", "new_password"}; все сессии пользователя завершаются
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	u, err := h.userService.ResetPassword(req.Token, req.NewPassword)
+	if err != nil {
+		switch {
+		case isPasswordViolation(err):
+			h.sendError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, user.ErrInvalidToken):
+			h.sendError(w, http.StatusBadRequest, "Ссылка недействительна или устарела, запросите сброс заново")
+		default:
+			h.sendError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	if err := h.sessions.RevokeAll(u.ID); err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// Владелец адреса подтвердил себя — блокировка входа по логину снимается
+	h.loginSucceeded(u.Username)
+	log.Printf("Password of user %d reset via email from %s", u.ID, clientIP(r))
+	w.WriteHeader(http.StatusNoContent)
+}
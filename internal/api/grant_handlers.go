@@ -0,0 +1,133 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"ospab-panel/internal/core/acl"
+	"ospab-panel/internal/core/rbac"
+	"ospab-panel/internal/core/server"
+)
+
+// serverForUser возвращает сервер, доступный пользователю целиком (grants == nil)
+// или только через гранты на отдельные инстансы (grants != nil — вызывающий
+// обязан фильтровать инстансы и проверять действия по грантам).
+func (h *ServerHandlers) serverForUser(uid, sid int) (*server.Server, acl.Access, error) {
+	srv, err := h.serverService.GetServerByID(sid, uid)
+	if err == nil || !errors.Is(err, sql.ErrNoRows) {
+		return srv, nil, err
+	}
+	access, err := h.acl.UserAccess(uid)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !access.HasServer(sid) {
+		return nil, nil, sql.ErrNoRows
+	}
+	srv, err = h.serverService.GetActiveServer(sid)
+	return srv, access, err
+}
+
+// instanceActionAllowed проверяет действие над инстансом: при полном доступе к серверу —
+// по глобальной роли и роли в организации, при доступе по грантам — по грантам.
+// Вторым значением возвращается видимость инстанса (для ответа 404 вместо 403).
+func (h *ServerHandlers) instanceActionAllowed(r *http.Request, srv *server.Server, grants acl.Access, instType, instID, action string) (allowed, visible bool) {
+	if !mfaSatisfied(h.settings, r, actionPermission(action)) {
+		return false, grants == nil || grants.Visible(srv.ID, instType, instID)
+	}
+	if grants == nil {
+		perm := actionPermission(action)
+		return permitted(h.rbac, r, perm) && serverAllows(h.rbac, r, srv, perm), true
+	}
+	if !grants.Visible(srv.ID, instType, instID) {
+		return false, false
+	}
+	if action == actionStatus || action == actionConfig {
+		return true, true
+	}
+	return grants.Allows(srv.ID, instType, instID, action) && keyScopeAllows(r, actionPermission(action)), true
+}
+
+// actionDenied — текст отказа: отдельно сообщаем, что не хватает входа с 2FA
+func (h *ServerHandlers) actionDenied(r *http.Request, action string) string {
+	if !mfaSatisfied(h.settings, r, actionPermission(action)) {
+		return msgMFARequired
+	}
+	return "permission denied: " + action
+}
+
+// GET /api/servers/{id}/grants
+func (h *ServerHandlers) ListGrants(w http.ResponseWriter, r *http.Request) {
+	srv, ok := h.grantServer(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.acl.ListByServer(srv.ID)
+	if err != nil {
+		sendErr(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSON(w, http.StatusOK, list)
+}
+
+// POST /api/servers/{id}/grants — выдать пользователю или организации доступ к инстансу
+func (h *ServerHandlers) CreateGrant(w http.ResponseWriter, r *http.Request) {
+	srv, ok := h.grantServer(w, r)
+	if !ok {
+		return
+	}
+	var req acl.CreateGrantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	g, err := h.acl.Create(srv.ID, userIDFromHeader(r), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, acl.ErrInvalidPrincipal), errors.Is(err, acl.ErrInvalidGrant), errors.Is(err, acl.ErrUnknownAction):
+			sendErr(w, http.StatusBadRequest, err.Error())
+		default:
+			sendErr(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	sendJSON(w, http.StatusCreated, g)
+}
+
+// DELETE /api/servers/{id}/grants/{grantId}
+func (h *ServerHandlers) DeleteGrant(w http.ResponseWriter, r *http.Request) {
+	srv, ok := h.grantServer(w, r)
+	if !ok {
+		return
+	}
+	grantID, _ := strconv.Atoi(mux.Vars(r)["grantId"])
+	if err := h.acl.Delete(srv.ID, grantID); err != nil {
+		if errors.Is(err, acl.ErrGrantNotFound) {
+			sendErr(w, http.StatusNotFound, err.Error())
+			return
+		}
+		sendErr(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// grantServer — сервер, грантами которого пользователь может управлять (нужно servers:write)
+func (h *ServerHandlers) grantServer(w http.ResponseWriter, r *http.Request) (*server.Server, bool) {
+	sid, _ := strconv.Atoi(mux.Vars(r)["id"])
+	srv, err := h.serverService.GetServerByID(sid, userIDFromHeader(r))
+	if err != nil {
+		sendErr(w, http.StatusNotFound, "server not found")
+		return nil, false
+	}
+	if !serverAllows(h.rbac, r, srv, rbac.PermServersWrite) {
+		sendErr(w, http.StatusForbidden, "permission denied: "+string(rbac.PermServersWrite))
+		return nil, false
+	}
+	return srv, true
+}
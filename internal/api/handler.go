@@ -3,15 +3,31 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
 
+	"ospab-panel/internal/core/acl"
+	"ospab-panel/internal/core/apikey"
+	"ospab-panel/internal/core/audit"
+	"ospab-panel/internal/core/authn"
+	"ospab-panel/internal/core/challenge"
+	"ospab-panel/internal/core/confirm"
+	"ospab-panel/internal/core/inventory"
+	"ospab-panel/internal/core/lockout"
+	"ospab-panel/internal/core/org"
+	"ospab-panel/internal/core/passkey"
+	"ospab-panel/internal/core/rbac"
 	coreServer "ospab-panel/internal/core/server"
+	"ospab-panel/internal/core/session"
+	"ospab-panel/internal/core/settings"
+	"ospab-panel/internal/core/sso"
 	"ospab-panel/internal/core/user"
 	"ospab-panel/internal/hypervisor"
+	"ospab-panel/internal/infra/mail"
 	"ospab-panel/pkg/auth"
 )
 
@@ -20,14 +36,48 @@ type Handler struct {
 	serverService *coreServer.Service
 	hvFactory     *hypervisor.HypervisorFactory
 	jwtManager    *auth.JWTManager
+	inventory     *inventory.Service
+	syncer        *inventory.Syncer
+	confirm       *confirm.Service
+	rbac          *rbac.Service
+	orgs          *org.Service
+	acl           *acl.Service
+	sessions      *session.Service
+	challenges    *challenge.Service
+	settings      *settings.Service
+	passkeys      *passkey.Service
+	sso           *sso.Service
+	authn         authn.Provider
+	apiKeys       *apikey.Service
+	lockout       *lockout.Service
+	audit         *audit.Service
+	mailer        mail.Sender
+	frontendURL   string // адрес фронтенда для ссылок в письмах
 }
 
-func NewHandler(userService *user.Service, serverService *coreServer.Service, hvFactory *hypervisor.HypervisorFactory, jwtManager *auth.JWTManager) *Handler {
+func NewHandler(userService *user.Service, serverService *coreServer.Service, hvFactory *hypervisor.HypervisorFactory, jwtManager *auth.JWTManager, inv *inventory.Service, syncer *inventory.Syncer, confirmService *confirm.Service, rbacService *rbac.Service, orgService *org.Service, aclService *acl.Service, sessionService *session.Service, challengeService *challenge.Service, settingsService *settings.Service, passkeyService *passkey.Service, ssoService *sso.Service, authProvider authn.Provider, apiKeyService *apikey.Service, lockoutService *lockout.Service, auditService *audit.Service, mailer mail.Sender, frontendURL string) *Handler {
 	return &Handler{
 		userService:   userService,
 		serverService: serverService,
 		hvFactory:     hvFactory,
 		jwtManager:    jwtManager,
+		inventory:     inv,
+		syncer:        syncer,
+		confirm:       confirmService,
+		rbac:          rbacService,
+		orgs:          orgService,
+		acl:           aclService,
+		sessions:      sessionService,
+		challenges:    challengeService,
+		settings:      settingsService,
+		passkeys:      passkeyService,
+		sso:           ssoService,
+		authn:         authProvider,
+		apiKeys:       apiKeyService,
+		lockout:       lockoutService,
+		audit:         auditService,
+		mailer:        mailer,
+		frontendURL:   frontendURL,
 	}
 }
 
@@ -48,9 +98,13 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 }
 
-// Middleware для JWT авторизации
+// Middleware для авторизации по JWT или API-ключу
 func (h *Handler) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if key := apiKeyFromRequest(r); key != "" {
+			h.serveWithAPIKey(w, r, key, next)
+			return
+		}
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
 			h.sendError(w, http.StatusUnauthorized, "Authorization header required")
@@ -64,19 +118,55 @@ func (h *Handler) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		}
 
 		claims, err := h.jwtManager.ValidateToken(bearerToken[1])
-		if err != nil {
+		if err != nil || claims.ID == "" || claims.SessionID == "" {
 			h.sendError(w, http.StatusUnauthorized, "Invalid token")
 			return
 		}
+		revoked, err := h.sessions.IsRevoked(claims.ID, claims.SessionID)
+		if err != nil {
+			h.sendError(w, http.StatusInternalServerError, "Failed to check token")
+			return
+		}
+		if revoked {
+			h.sendError(w, http.StatusUnauthorized, "Token revoked")
+			return
+		}
 
 		// Добавляем информацию о пользователе в заголовки
 		r.Header.Set("X-User-ID", strconv.Itoa(claims.UserID))
 		r.Header.Set("X-Username", claims.Username)
+		r.Header.Set("X-User-Role", claims.Role)
+		r.Header.Set("X-Session-ID", claims.SessionID)
+		r.Header.Set("X-Token-ID", claims.ID)
+		r.Header.Set("X-MFA", strconv.FormatBool(claims.MFA))
+		if claims.ImpersonatorID != 0 {
+			r.Header.Set("X-Impersonator-ID", strconv.Itoa(claims.ImpersonatorID))
+		} else {
+			r.Header.Del("X-Impersonator-ID")
+		}
+		r.Header.Del("X-API-Key-ID")
+		r.Header.Del("X-API-Key-Scope")
 
 		next(w, r)
 	}
 }
 
+// Permit пропускает запрос, только если роль пользователя (из JWT) имеет право perm.
+// Используется вместе с AuthMiddleware: h.AuthMiddleware(h.Permit(perm, handler)).
+func (h *Handler) Permit(perm rbac.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !permitted(h.rbac, r, perm) {
+			h.sendError(w, http.StatusForbidden, "Недостаточно прав: "+string(perm))
+			return
+		}
+		if !mfaSatisfied(h.settings, r, perm) {
+			h.sendError(w, http.StatusForbidden, msgMFARequired)
+			return
+		}
+		next(w, r)
+	}
+}
+
 // POST /api/auth/login
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	var loginReq user.LoginRequest
@@ -88,17 +178,29 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		h.sendError(w, http.StatusBadRequest, "Требуются логин и пароль")
 		return
 	}
-	u, err := h.userService.GetUserByUsername(loginReq.Username)
-	if err != nil || !h.userService.ValidatePassword(u, loginReq.Password) {
-		h.sendError(w, http.StatusUnauthorized, "Неверный логин или пароль")
+	if h.loginBlocked(w, r, loginReq.Username) {
 		return
 	}
-	token, err := h.jwtManager.GenerateToken(u.ID, u.Username)
+	u, err := h.authn.Authenticate(r.Context(), loginReq.Username, loginReq.Password)
 	if err != nil {
-		h.sendError(w, http.StatusInternalServerError, "Failed to generate token")
+		switch {
+		case errors.Is(err, authn.ErrInvalidCredentials):
+			h.loginFailed(r, loginReq.Username, "invalid credentials")
+			h.sendError(w, http.StatusUnauthorized, "Неверный логин или пароль")
+		case errors.Is(err, authn.ErrNoRole):
+			h.sendError(w, http.StatusForbidden, "Доступ к панели для вашей группы не предусмотрен")
+		case errors.Is(err, user.ErrEmailTaken):
+			h.sendError(w, http.StatusConflict, "Email уже используется другим пользователем")
+		default:
+			h.sendError(w, http.StatusServiceUnavailable, "Сервис аутентификации недоступен")
+		}
 		return
 	}
-	h.sendJSON(w, http.StatusOK, user.LoginResponse{Token: token, User: *u})
+	// При включённой 2FA счётчик сбрасывается только после второго фактора
+	if !u.TOTPEnabled {
+		h.loginSucceeded(loginReq.Username)
+	}
+	h.completeLogin(w, r, u, false)
 }
 
 // POST /api/auth/register
@@ -109,6 +211,10 @@ type registerRequest struct {
 }
 
 func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
+	if !h.settings.Bool(settings.RegistrationEnabled) {
+		h.sendError(w, http.StatusForbidden, "Регистрация отключена администратором")
+		return
+	}
 	var req registerRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
@@ -122,8 +228,8 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := "Ошибка регистрации"
 		switch {
-		case errors.Is(err, user.ErrPasswordTooShort):
-			msg = "Пароль слишком короткий (минимум 8 символов)"
+		case isPasswordViolation(err):
+			msg = err.Error()
 		case errors.Is(err, user.ErrUsernameTaken):
 			msg = "Логин уже используется"
 		case errors.Is(err, user.ErrEmailTaken):
@@ -136,12 +242,15 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		h.sendError(w, http.StatusBadRequest, msg)
 		return
 	}
-	token, err := h.jwtManager.GenerateToken(u.ID, u.Username)
+	if err := h.sendVerificationEmail(u); err != nil {
+		log.Printf("Verification email for user %d not sent: %v", u.ID, err)
+	}
+	resp, err := h.issueTokens(r, u, false)
 	if err != nil {
 		h.sendError(w, http.StatusInternalServerError, "Failed token")
 		return
 	}
-	h.sendJSON(w, http.StatusCreated, user.LoginResponse{Token: token, User: *u})
+	h.sendJSON(w, http.StatusCreated, resp)
 }
 
 // GET /api/status - требует авторизации
@@ -266,6 +375,15 @@ func (h *Handler) UpdateServerConnection(w http.ResponseWriter, r *http.Request)
 		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
+	srv, err := h.serverService.GetServerByID(sid, uid)
+	if err != nil {
+		h.sendError(w, http.StatusNotFound, "Server not found")
+		return
+	}
+	if !serverAllows(h.rbac, r, srv, rbac.PermServersWrite) {
+		h.sendError(w, http.StatusForbidden, "Недостаточно прав: "+string(rbac.PermServersWrite))
+		return
+	}
 	up := coreServer.UpdateServerRequest{}
 	if req.Host != nil {
 		up.Host = *req.Host
@@ -279,7 +397,7 @@ func (h *Handler) UpdateServerConnection(w http.ResponseWriter, r *http.Request)
 	if req.Password != nil {
 		up.Password = *req.Password
 	}
-	_, err := h.serverService.UpdateServer(sid, uid, &up)
+	_, err = h.serverService.UpdateServer(sid, uid, &up)
 	if err != nil {
 		h.sendError(w, http.StatusInternalServerError, err.Error())
 		return
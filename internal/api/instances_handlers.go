@@ -0,0 +1,276 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ospab-panel/internal/core/audit"
+	"ospab-panel/internal/core/confirm"
+	"ospab-panel/internal/core/inventory"
+	"ospab-panel/internal/hypervisor"
+)
+
+const (
+	defaultServerTimeout = 10 * time.Second
+	maxServerTimeout     = 30 * time.Second
+
+	// Ограничения массовых операций
+	bulkMaxItems       = 500
+	bulkPerHypervisor  = 4 // одновременных действий на один гипервизор
+	bulkMaxHypervisors = 8 // одновременно обслуживаемых гипервизоров
+	bulkItemTimeout    = 60 * time.Second
+)
+
+// GET /api/instances — инстансы со всех серверов пользователя.
+// Серверы опрашиваются параллельно; не ответившие перечисляются в "failed".
+func (h *ServerHandlers) SearchInstances(w http.ResponseWriter, r *http.Request) {
+	uid := userIDFromHeader(r)
+	servers, err := h.serverService.GetServersByUserID(uid)
+	if err != nil {
+		sendErr(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// Серверы, доступные только через гранты: из них показываются лишь выданные инстансы
+	grants, err := h.acl.UserAccess(uid)
+	if err != nil {
+		sendErr(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	full := map[int]bool{}
+	for _, srv := range servers {
+		full[srv.ID] = true
+	}
+	for _, sid := range grants.ServerIDs() {
+		if full[sid] {
+			continue
+		}
+		if srv, err := h.serverService.GetActiveServer(sid); err == nil {
+			servers = append(servers, srv)
+		}
+	}
+	qs := r.URL.Query()
+	if sid := qs.Get("server_id"); sid != "" {
+		id, _ := strconv.Atoi(sid)
+		filtered := servers[:0]
+		for _, srv := range servers {
+			if srv.ID == id {
+				filtered = append(filtered, srv)
+			}
+		}
+		servers = filtered
+	}
+	timeout := defaultServerTimeout
+	if v := qs.Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			sendErr(w, http.StatusBadRequest, "invalid timeout")
+			return
+		}
+		if d > maxServerTimeout {
+			d = maxServerTimeout
+		}
+		timeout = d
+	}
+	page, _ := strconv.Atoi(qs.Get("page"))
+	perPage, _ := strconv.Atoi(qs.Get("per_page"))
+
+	items, failed := h.syncer.Collect(r.Context(), servers, timeout)
+	visible := items[:0]
+	for _, it := range items {
+		if full[it.ServerID] || grants.Visible(it.ServerID, it.Type, it.ID) {
+			visible = append(visible, it)
+		}
+	}
+	items = visible
+	res := inventory.Search(items, inventory.Query{
+		Name:    qs.Get("name"),
+		Status:  qs.Get("status"),
+		Type:    qs.Get("type"),
+		Node:    qs.Get("node"),
+		Tag:     qs.Get("tag"),
+		Sort:    qs.Get("sort"),
+		Page:    page,
+		PerPage: perPage,
+	})
+	res.Failed = failed
+	sendJSON(w, http.StatusOK, res)
+}
+
+type BulkItem struct {
+	ServerID     int    `json:"server_id"`
+	InstanceID   string `json:"instance_id"`
+	Type         string `json:"type"`
+	ConfirmToken string `json:"confirm_token,omitempty"` // для delete/rollback/reinstall
+}
+
+type BulkActionRequest struct {
+	Action          string     `json:"action"`
+	SnapshotName    string     `json:"snapshot_name,omitempty"`
+	ShutdownTimeout int        `json:"shutdown_timeout,omitempty"` // секунды
+	Force           bool       `json:"force,omitempty"`
+	Items           []BulkItem `json:"items"`
+}
+
+type BulkItemResult struct {
+	BulkItem
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type BulkActionResponse struct {
+	Action    string           `json:"action"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkItemResult `json:"results"`
+}
+
+// POST /api/instances/bulk — одно действие над списком инстансов.
+// Элементы группируются по серверу: одно подключение на гипервизор и не более
+// bulkPerHypervisor одновременных действий на нём. Ответ — результат по каждому элементу
+// в порядке запроса.
+func (h *ServerHandlers) BulkInstanceAction(w http.ResponseWriter, r *http.Request) {
+	uid := userIDFromHeader(r)
+	var req BulkActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if !mutatingActions[req.Action] {
+		sendErr(w, http.StatusBadRequest, "unsupported action")
+		return
+	}
+	if len(req.Items) == 0 {
+		sendErr(w, http.StatusBadRequest, "no items")
+		return
+	}
+	if len(req.Items) > bulkMaxItems {
+		sendErr(w, http.StatusBadRequest, "too many items")
+		return
+	}
+
+	results := make([]BulkItemResult, len(req.Items))
+	tokens := make([]string, len(req.Items))
+	byServer := map[int][]int{}
+	for i, it := range req.Items {
+		it.Type = strings.ToLower(it.Type)
+		if it.Type == "" {
+			it.Type = "vm"
+		}
+		tokens[i] = it.ConfirmToken
+		it.ConfirmToken = "" // токен не возвращаем в отчёте
+		results[i] = BulkItemResult{BulkItem: it}
+		if it.InstanceID == "" || (it.Type != "vm" && it.Type != "lxc") {
+			results[i].Error = "invalid item"
+			continue
+		}
+		byServer[it.ServerID] = append(byServer[it.ServerID], i)
+	}
+
+	if req.ShutdownTimeout < 0 || req.ShutdownTimeout > 3600 {
+		sendErr(w, http.StatusBadRequest, "invalid shutdown_timeout")
+		return
+	}
+	params := actionParams{
+		SnapshotName: req.SnapshotName,
+		Shutdown:     hypervisor.ShutdownOptions{Timeout: time.Duration(req.ShutdownTimeout) * time.Second, Force: req.Force},
+	}
+	hvSem := make(chan struct{}, bulkMaxHypervisors)
+	var wg sync.WaitGroup
+	for sid, idx := range byServer {
+		wg.Add(1)
+		go func(sid int, idx []int) {
+			defer wg.Done()
+			hvSem <- struct{}{}
+			defer func() { <-hvSem }()
+			h.runBulkOnServer(r, uid, sid, req.Action, params, idx, tokens, results)
+		}(sid, idx)
+	}
+	wg.Wait()
+
+	resp := BulkActionResponse{Action: req.Action, Results: results}
+	for _, res := range results {
+		// Отдельная запись на инстанс: действие находится и при отборе по серверу
+		e := audit.Entry{Action: "instances." + req.Action, ServerID: res.ServerID, InstanceID: res.InstanceID,
+			Params: json.RawMessage(`{"via":"instances.bulk"}`), Result: audit.ResultSuccess}
+		if res.OK {
+			resp.Succeeded++
+		} else {
+			resp.Failed++
+			e.Result, e.Error = audit.ResultFailure, res.Error
+		}
+		auditAlso(r, e)
+	}
+	sendJSON(w, http.StatusOK, resp)
+}
+
+// runBulkOnServer выполняет действия для элементов idx одного сервера.
+// Каждая горутина пишет только в свой элемент results.
+func (h *ServerHandlers) runBulkOnServer(r *http.Request, uid, sid int, action string, params actionParams, idx []int, tokens []string, results []BulkItemResult) {
+	ctx := r.Context()
+	fail := func(msg string) {
+		for _, i := range idx {
+			results[i].Error = msg
+		}
+	}
+	srv, grants, err := h.serverForUser(uid, sid)
+	if err != nil {
+		fail("server not found")
+		return
+	}
+	// Элементы, на которые нет прав, отклоняются до подключения
+	allowedIdx := idx[:0:0]
+	for _, i := range idx {
+		it := results[i].BulkItem
+		switch allowed, visible := h.instanceActionAllowed(r, srv, grants, it.Type, it.InstanceID, action); {
+		case !visible:
+			results[i].Error = "instance not found"
+		case !allowed:
+			results[i].Error = h.actionDenied(r, action)
+		default:
+			allowedIdx = append(allowedIdx, i)
+		}
+	}
+	if idx = allowedIdx; len(idx) == 0 {
+		return
+	}
+	cctx, cancel := context.WithTimeout(ctx, defaultServerTimeout)
+	client, err := h.hvFactory.Connect(cctx, hvServer(srv))
+	cancel()
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	defer client.Disconnect()
+
+	sem := make(chan struct{}, bulkPerHypervisor)
+	var wg sync.WaitGroup
+	for _, i := range idx {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			ictx, cancel := context.WithTimeout(ctx, bulkItemTimeout)
+			defer cancel()
+			it := results[i].BulkItem
+			if destructiveActions[action] {
+				target := confirm.Target{ServerID: sid, InstanceType: it.Type, InstanceID: it.InstanceID}
+				if err := h.checkDestructive(ictx, client, uid, target, action, tokens[i]); err != nil {
+					results[i].Error = err.Error()
+					return
+				}
+			}
+			if _, err := runInstanceAction(ictx, client, action, it.Type, it.InstanceID, params); err != nil {
+				results[i].Error = err.Error()
+				return
+			}
+			results[i].OK = true
+		}(i)
+	}
+	wg.Wait()
+}
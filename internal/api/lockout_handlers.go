@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"ospab-panel/internal/core/lockout"
+)
+
+// loginBlocked отвечает 429, если с этого IP или под этим логином вход временно запрещён
+func (h *Handler) loginBlocked(w http.ResponseWriter, r *http.Request, username string) bool {
+	block, err := h.lockout.Check(clientIP(r), username)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, "Failed to check login attempts")
+		return true
+	}
+	if block == nil {
+		return false
+	}
+	secs := int(math.Ceil(block.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	if block.Locked {
+		h.sendError(w, http.StatusTooManyRequests, fmt.Sprintf("Вход временно заблокирован из-за неудачных попыток. Повторите через %d мин. или обратитесь к администратору", int(math.Ceil(block.RetryAfter.Minutes()))))
+		return true
+	}
+	h.sendError(w, http.StatusTooManyRequests, fmt.Sprintf("Слишком много попыток входа, повторите через %d с", secs))
+	return true
+}
+
+// loginFailed фиксирует неудачную попытку: журнал сервера и счётчики задержек
+func (h *Handler) loginFailed(r *http.Request, username, reason string) {
+	ip := clientIP(r)
+	log.Printf("Login failed for %q from %s (%s): %s", username, ip, r.UserAgent(), reason)
+	if err := h.lockout.Fail(ip, username); err != nil {
+		log.Printf("Failed to record login failure: %v", err)
+	}
+}
+
+// loginSucceeded сбрасывает счётчик неудач логина после полного входа (включая 2FA)
+func (h *Handler) loginSucceeded(username string) {
+	if err := h.lockout.Succeed(username); err != nil {
+		log.Printf("Failed to reset login failures for %q: %v", username, err)
+	}
+}
+
+// GET /api/admin/lockouts — действующие паузы и блокировки входа
+func (h *Handler) ListLockouts(w http.ResponseWriter, r *http.Request) {
+	list, err := h.lockout.Active()
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, list)
+}
+
+// POST /api/admin/lockouts/unlock — {"username"} и/или {"ip"}
+func (h *Handler) UnlockLogin(w http.ResponseWriter, r *http.Request) {
+	var req lockout.UnlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Username == "" && req.IP == "") {
+		h.sendError(w, http.StatusBadRequest, "Требуется username или ip")
+		return
+	}
+	n, err := h.lockout.Unlock(req.Username, req.IP)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("Login lockout removed by %s: username=%q ip=%q", r.Header.Get("X-Username"), req.Username, req.IP)
+	h.sendJSON(w, http.StatusOK, map[string]int64{"unlocked": n})
+}
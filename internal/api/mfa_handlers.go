@@ -0,0 +1,200 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"ospab-panel/internal/core/challenge"
+	"ospab-panel/internal/core/rbac"
+	"ospab-panel/internal/core/settings"
+	"ospab-panel/internal/core/user"
+)
+
+// mfaChallengeTTL — время на ввод кода после успешного пароля
+const mfaChallengeTTL = 5 * time.Minute
+
+const msgMFARequired = "Действие требует входа с двухфакторной аутентификацией"
+
+// mfaSatisfied: при включённой настройке mfa_required_for_delete право instances:delete
+// действует только в сессии, подтверждённой вторым фактором
+func mfaSatisfied(st *settings.Service, r *http.Request, perm rbac.Permission) bool {
+	if perm != rbac.PermInstancesDelete || r.Header.Get("X-MFA") == "true" {
+		return true
+	}
+	return !st.Bool(settings.MFARequiredForDelete)
+}
+
+// mfaRequiredFor — обязательна ли 2FA для роли (роль может удалять инстансы)
+func (h *Handler) mfaRequiredFor(role string) bool {
+	return h.settings.Bool(settings.MFARequiredForDelete) && h.rbac.Can(role, rbac.PermInstancesDelete)
+}
+
+// POST /api/auth/2fa/verify — второй шаг входа: challenge-токен + код TOTP или код восстановления
+func (h *Handler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var req user.MFAVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ChallengeToken == "" || req.Code == "" {
+		h.sendError(w, http.StatusBadRequest, "Требуются challenge_token и code")
+		return
+	}
+	c, err := h.challenges.Get(challenge.KindMFA, req.ChallengeToken)
+	if err != nil {
+		if errors.Is(err, challenge.ErrInvalidChallenge) {
+			h.sendError(w, http.StatusUnauthorized, "Сессия входа истекла, войдите заново")
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	u, err := h.userService.GetUserByID(c.UserID)
+	if err != nil {
+		h.sendError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+	// Пауза после неудач действует и на второй фактор: новый challenge не даёт новых попыток
+	if h.loginBlocked(w, r, u.Username) {
+		return
+	}
+	if err := h.userService.VerifySecondFactor(c.UserID, req.Code); err != nil {
+		if errors.Is(err, user.ErrInvalidCode) {
+			_ = h.challenges.Fail(challenge.KindMFA, req.ChallengeToken)
+			h.loginFailed(r, u.Username, "invalid 2FA code")
+			h.sendError(w, http.StatusUnauthorized, "Неверный код")
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// Consume атомарен: параллельный запрос с тем же токеном получит ошибку
+	if _, err := h.challenges.Consume(challenge.KindMFA, req.ChallengeToken); err != nil {
+		h.sendError(w, http.StatusUnauthorized, "Сессия входа истекла, войдите заново")
+		return
+	}
+	h.loginSucceeded(u.Username)
+	if h.accountDisabled(w, u) {
+		return
+	}
+	resp, err := h.issueTokens(r, u, true)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	h.sendJSON(w, http.StatusOK, resp)
+}
+
+// GET /api/me/2fa
+func (h *Handler) GetTOTPStatus(w http.ResponseWriter, r *http.Request) {
+	u, err := h.userService.GetUserByID(atoi(r.Header.Get("X-User-ID")))
+	if err != nil {
+		h.sendError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	left, err := h.userService.RecoveryCodesLeft(u.ID)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled":             u.TOTPEnabled,
+		"required":            h.mfaRequiredFor(u.Role),
+		"recovery_codes_left": left,
+	})
+}
+
+// POST /api/me/2fa/enroll — новый секрет и otpauth-URI для QR-кода
+func (h *Handler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	e, err := h.userService.EnrollTOTP(atoi(r.Header.Get("X-User-ID")))
+	if err != nil {
+		h.sendError(w, totpErrStatus(err), err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, e)
+}
+
+// POST /api/me/2fa/enable — подтверждение первым кодом; возвращает коды восстановления.
+// Текущая сессия считается подтверждённой: отметка попадёт в токен при следующем refresh.
+func (h *Handler) EnableTOTP(w http.ResponseWriter, r *http.Request) {
+	uid := atoi(r.Header.Get("X-User-ID"))
+	var req user.TOTPCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	codes, err := h.userService.EnableTOTP(uid, req.Code)
+	if err != nil {
+		h.sendError(w, totpErrStatus(err), err.Error())
+		return
+	}
+	if err := h.sessions.MarkMFA(r.Header.Get("X-Session-ID")); err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, map[string][]string{"recovery_codes": codes})
+}
+
+// POST /api/me/2fa/disable — требует пароль и действующий код
+func (h *Handler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	uid := atoi(r.Header.Get("X-User-ID"))
+	var req user.DisableTOTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	u, err := h.userService.GetUserByID(uid)
+	if err != nil {
+		h.sendError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if h.mfaRequiredFor(u.Role) {
+		h.sendError(w, http.StatusForbidden, "Для вашей роли двухфакторная аутентификация обязательна")
+		return
+	}
+	if !h.userService.ValidatePassword(u, req.Password) {
+		h.sendError(w, http.StatusForbidden, "Неверный пароль")
+		return
+	}
+	if err := h.userService.VerifySecondFactor(uid, req.Code); err != nil {
+		h.sendError(w, totpErrStatus(err), err.Error())
+		return
+	}
+	if err := h.userService.DisableTOTP(uid); err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/me/2fa/recovery-codes — выпустить новые коды (старые перестают действовать)
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	uid := atoi(r.Header.Get("X-User-ID"))
+	var req user.TOTPCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if err := h.userService.VerifySecondFactor(uid, req.Code); err != nil {
+		h.sendError(w, totpErrStatus(err), err.Error())
+		return
+	}
+	codes, err := h.userService.RegenerateRecoveryCodes(uid)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, map[string][]string{"recovery_codes": codes})
+}
+
+func totpErrStatus(err error) int {
+	switch {
+	case errors.Is(err, user.ErrInvalidCode):
+		return http.StatusUnauthorized
+	case errors.Is(err, user.ErrTOTPAlreadyEnabled), errors.Is(err, user.ErrTOTPNotEnrolled):
+		return http.StatusConflict
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
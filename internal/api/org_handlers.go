@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"ospab-panel/internal/core/org"
+	"ospab-panel/internal/core/rbac"
+	"ospab-panel/internal/core/server"
+)
+
+// serverAllows — право perm на конкретный сервер с учётом роли пользователя
+// в организации-владельце. Роль с servers:all организацией не ограничивается.
+func serverAllows(rs *rbac.Service, r *http.Request, srv *server.Server, perm rbac.Permission) bool {
+	if srv.MemberRole == "" || permitted(rs, r, rbac.PermServersAll) {
+		return true
+	}
+	return org.Allows(srv.MemberRole, perm)
+}
+
+// orgAllows — может ли пользователь выполнять perm над серверами организации orgID
+func orgAllows(orgs *org.Service, rs *rbac.Service, r *http.Request, orgID int, perm rbac.Permission) bool {
+	if permitted(rs, r, rbac.PermServersAll) {
+		return true
+	}
+	role, err := orgs.MemberRole(orgID, atoi(r.Header.Get("X-User-ID")))
+	return err == nil && org.Allows(role, perm)
+}
+
+// GET /api/orgs
+func (h *Handler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	list, err := h.orgs.ListForUser(atoi(r.Header.Get("X-User-ID")))
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, list)
+}
+
+// POST /api/orgs
+func (h *Handler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var req org.CreateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	o, err := h.orgs.Create(req.Name, atoi(r.Header.Get("X-User-ID")))
+	if err != nil {
+		h.sendError(w, orgErrStatus(err), err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusCreated, o)
+}
+
+// GET /api/orgs/{id} — организация со списком участников
+func (h *Handler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	o, err := h.orgs.Get(id, atoi(r.Header.Get("X-User-ID")))
+	if err != nil {
+		h.sendError(w, orgErrStatus(err), err.Error())
+		return
+	}
+	members, err := h.orgs.Members(id)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, map[string]interface{}{"organization": o, "members": members})
+}
+
+// DELETE /api/orgs/{id}
+func (h *Handler) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.orgs.Delete(id, atoi(r.Header.Get("X-User-ID"))); err != nil {
+		h.sendError(w, orgErrStatus(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /api/orgs/{id}/members/{userId}
+func (h *Handler) SetMemberRole(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	memberID, _ := strconv.Atoi(vars["userId"])
+	var req org.SetMemberRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if err := h.orgs.SetMemberRole(id, atoi(r.Header.Get("X-User-ID")), memberID, req.Role); err != nil {
+		h.sendError(w, orgErrStatus(err), err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, map[string]string{"result": "ok"})
+}
+
+// DELETE /api/orgs/{id}/members/{userId} — исключить участника или выйти самому
+func (h *Handler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	memberID, _ := strconv.Atoi(vars["userId"])
+	if err := h.orgs.RemoveMember(id, atoi(r.Header.Get("X-User-ID")), memberID); err != nil {
+		h.sendError(w, orgErrStatus(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/orgs/{id}/invites
+func (h *Handler) ListInvites(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	o, err := h.orgs.Get(id, atoi(r.Header.Get("X-User-ID")))
+	if err != nil {
+		h.sendError(w, orgErrStatus(err), err.Error())
+		return
+	}
+	if !org.CanManage(o.MyRole) {
+		h.sendError(w, http.StatusForbidden, org.ErrForbidden.Error())
+		return
+	}
+	list, err := h.orgs.Invites(id)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, list)
+}
+
+// POST /api/orgs/{id}/invites — токен приглашения возвращается один раз
+func (h *Handler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req org.InviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	inv, err := h.orgs.Invite(id, atoi(r.Header.Get("X-User-ID")), &req)
+	if err != nil {
+		h.sendError(w, orgErrStatus(err), err.Error())
+		return
+	}
+	log.Printf("Organization %d: invite %d issued for %s (%s)", id, inv.ID, inv.Email, inv.Role)
+	h.sendJSON(w, http.StatusCreated, inv)
+}
+
+// DELETE /api/orgs/{id}/invites/{inviteId}
+func (h *Handler) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	inviteID, _ := strconv.Atoi(vars["inviteId"])
+	if err := h.orgs.RevokeInvite(id, atoi(r.Header.Get("X-User-ID")), inviteID); err != nil {
+		h.sendError(w, orgErrStatus(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/invites/accept
+func (h *Handler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	var req org.AcceptInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	o, err := h.orgs.AcceptInvite(req.Token, atoi(r.Header.Get("X-User-ID")))
+	if err != nil {
+		h.sendError(w, orgErrStatus(err), err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, o)
+}
+
+func orgErrStatus(err error) int {
+	switch {
+	case errors.Is(err, org.ErrNotFound), errors.Is(err, org.ErrNotMember):
+		return http.StatusNotFound
+	case errors.Is(err, org.ErrForbidden), errors.Is(err, org.ErrEmailNotVerified):
+		return http.StatusForbidden
+	case errors.Is(err, org.ErrLastOwner), errors.Is(err, org.ErrHasServers), errors.Is(err, org.ErrAlreadyMember):
+		return http.StatusConflict
+	case errors.Is(err, org.ErrInvalidRole), errors.Is(err, org.ErrInvalidName), errors.Is(err, org.ErrInvalidInvite):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"ospab-panel/internal/core/confirm"
+	"ospab-panel/internal/core/rbac"
+	"ospab-panel/internal/hypervisor"
+)
+
+type ConfirmRequest struct {
+	Action string `json:"action"`
+	Name   string `json:"name"` // имя инстанса, введённое пользователем
+}
+
+type ProtectionRequest struct {
+	Protected bool `json:"protected"`
+}
+
+// POST /api/servers/{id}/instances/{instanceId}/confirm
+// Выдаёт одноразовый токен для delete/rollback/reinstall, если переданное имя совпадает с именем инстанса.
+func (h *ServerHandlers) ConfirmAction(w http.ResponseWriter, r *http.Request) {
+	uid := userIDFromHeader(r)
+	vars := mux.Vars(r)
+	sid, _ := strconv.Atoi(vars["id"])
+	instID := vars["instanceId"]
+	var req ConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if !destructiveActions[req.Action] {
+		sendErr(w, http.StatusBadRequest, "action does not require confirmation")
+		return
+	}
+	srv, grants, err := h.serverForUser(uid, sid)
+	if err != nil {
+		sendErr(w, http.StatusNotFound, "server not found")
+		return
+	}
+	instType := instanceTypeFromQuery(r)
+	if allowed, visible := h.instanceActionAllowed(r, srv, grants, instType, instID, req.Action); !visible {
+		sendErr(w, http.StatusNotFound, "instance not found")
+		return
+	} else if !allowed {
+		sendErr(w, http.StatusForbidden, h.actionDenied(r, req.Action))
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
+	defer cancel()
+	client, err := h.connectForAction(ctx, srv.Type, hvServer(srv), req.Action, instType)
+	if err != nil {
+		sendErr(w, actionErrStatus(err), err.Error())
+		return
+	}
+	cfg, err := client.GetInstanceConfig(ctx, instType, instID)
+	if err != nil {
+		sendErr(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if name := instanceName(cfg); name == "" || strings.TrimSpace(req.Name) != name {
+		sendErr(w, http.StatusBadRequest, "instance name does not match")
+		return
+	}
+	target := confirm.Target{ServerID: srv.ID, InstanceType: instType, InstanceID: instID}
+	if err := h.checkNotProtected(ctx, client, target); err != nil {
+		sendErr(w, actionErrStatus(err), err.Error())
+		return
+	}
+	c, err := h.confirm.Issue(uid, target, req.Action)
+	if err != nil {
+		sendErr(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSON(w, http.StatusOK, c)
+}
+
+// GET /api/servers/{id}/instances/{instanceId}/protection
+func (h *ServerHandlers) GetProtection(w http.ResponseWriter, r *http.Request) {
+	uid := userIDFromHeader(r)
+	vars := mux.Vars(r)
+	sid, _ := strconv.Atoi(vars["id"])
+	instID := vars["instanceId"]
+	srv, err := h.serverService.GetServerByID(sid, uid)
+	if err != nil {
+		sendErr(w, http.StatusNotFound, "server not found")
+		return
+	}
+	instType := instanceTypeFromQuery(r)
+	panel, err := h.inventory.IsProtected(srv.ID, instType, instID)
+	if err != nil {
+		sendErr(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
+	defer cancel()
+	client, err := h.hvFactory.Connect(ctx, hvServer(srv))
+	if err != nil {
+		sendErr(w, hypervisorErrStatus(err), err.Error())
+		return
+	}
+	hv, err := client.GetProtection(ctx, instType, instID)
+	if err != nil {
+		sendErr(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	sendJSON(w, http.StatusOK, map[string]bool{"protected": panel || hv, "panel": panel, "hypervisor": hv})
+}
+
+// PUT /api/servers/{id}/instances/{instanceId}/protection
+// Выставляет флаг панели и опцию protection на гипервизоре.
+func (h *ServerHandlers) SetProtection(w http.ResponseWriter, r *http.Request) {
+	uid := userIDFromHeader(r)
+	vars := mux.Vars(r)
+	sid, _ := strconv.Atoi(vars["id"])
+	instID := vars["instanceId"]
+	var req ProtectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	srv, err := h.serverService.GetServerByID(sid, uid)
+	if err != nil {
+		sendErr(w, http.StatusNotFound, "server not found")
+		return
+	}
+	if !serverAllows(h.rbac, r, srv, rbac.PermInstancesDelete) {
+		sendErr(w, http.StatusForbidden, "permission denied: "+string(rbac.PermInstancesDelete))
+		return
+	}
+	instType := instanceTypeFromQuery(r)
+	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
+	defer cancel()
+	client, err := h.hvFactory.Connect(ctx, hvServer(srv))
+	if err != nil {
+		sendErr(w, hypervisorErrStatus(err), err.Error())
+		return
+	}
+	if err := client.SetProtection(ctx, instType, instID, req.Protected); err != nil {
+		sendErr(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	err = h.inventory.SetProtected(srv.ID, instType, instID, req.Protected)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Инстанс ещё не попал в инвентарь — синхронизируем сервер и повторяем
+		if _, err = h.syncer.SyncServer(r.Context(), srv); err == nil {
+			err = h.inventory.SetProtected(srv.ID, instType, instID, req.Protected)
+		}
+	}
+	if err != nil {
+		sendErr(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSON(w, http.StatusOK, map[string]bool{"protected": req.Protected})
+}
+
+// connectForAction создаёт клиента, проверяет поддержку действия и подключается
+func (h *ServerHandlers) connectForAction(ctx context.Context, hvType string, srv *hypervisor.Server, action, instType string) (hypervisor.HypervisorClient, error) {
+	client, err := h.hvFactory.CreateClient(hvType)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAction(client, action, instType); err != nil {
+		return nil, err
+	}
+	if err := client.Connect(ctx, srv); err != nil {
+		return nil, fmt.Errorf("%w: %v", hypervisor.ErrConnectionFailed, err)
+	}
+	return client, nil
+}
+
+// instanceName берёт имя из конфигурации: name у VM, hostname у LXC
+func instanceName(cfg map[string]interface{}) string {
+	for _, k := range []string{"name", "hostname"} {
+		if v, ok := cfg[k].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"ospab-panel/internal/core/rbac"
+	"ospab-panel/internal/hypervisor"
+)
+
+// permitted проверяет право по роли, выставленной AuthMiddleware
+func permitted(rs *rbac.Service, r *http.Request, perm rbac.Permission) bool {
+	return rs.Can(r.Header.Get("X-User-Role"), perm) && keyScopeAllows(r, perm)
+}
+
+// actionPermission — право, необходимое для действия над инстансом
+func actionPermission(action string) rbac.Permission {
+	switch action {
+	case actionStatus, actionConfig:
+		return rbac.PermInstancesRead
+	case hypervisor.ActionSnapshot:
+		return rbac.PermInstancesSnapshot
+	case hypervisor.ActionDelete, hypervisor.ActionRollback, hypervisor.ActionReinstall:
+		return rbac.PermInstancesDelete
+	default:
+		return rbac.PermInstancesPower
+	}
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// GET /api/roles
+func (h *Handler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.rbac.ListRoles()
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, map[string]interface{}{"roles": roles, "permissions": rbac.AllPermissions})
+}
+
+// POST /api/roles
+func (h *Handler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	var req rbac.CreateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	role, err := h.rbac.CreateRole(&req)
+	if err != nil {
+		h.sendError(w, roleErrStatus(err), err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusCreated, role)
+}
+
+// PUT /api/roles/{name}
+func (h *Handler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	var req rbac.CreateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	role, err := h.rbac.UpdateRole(mux.Vars(r)["name"], &req)
+	if err != nil {
+		h.sendError(w, roleErrStatus(err), err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, role)
+}
+
+// DELETE /api/roles/{name}
+func (h *Handler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	if err := h.rbac.DeleteRole(mux.Vars(r)["name"]); err != nil {
+		h.sendError(w, roleErrStatus(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /api/admin/users/{id}/role — роль вступает в силу со следующим выданным токеном
+func (h *Handler) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req SetRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if !h.rbac.Exists(req.Role) {
+		h.sendError(w, http.StatusBadRequest, "Неизвестная роль")
+		return
+	}
+	// Себе можно назначить только роль, которая оставляет право управлять пользователями
+	if id == atoi(r.Header.Get("X-User-ID")) && !h.rbac.Can(req.Role, rbac.PermUsersManage) {
+		h.sendError(w, http.StatusBadRequest, "Нельзя назначить себе роль без права управления пользователями")
+		return
+	}
+	u, err := h.userService.SetRole(id, req.Role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.sendError(w, http.StatusNotFound, "Пользователь не найден")
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, u)
+}
+
+func roleErrStatus(err error) int {
+	switch {
+	case errors.Is(err, rbac.ErrRoleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, rbac.ErrRoleExists), errors.Is(err, rbac.ErrRoleInUse), errors.Is(err, rbac.ErrRoleBuiltIn):
+		return http.StatusConflict
+	case errors.Is(err, rbac.ErrInvalidRoleName), errors.Is(err, rbac.ErrUnknownPermission):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
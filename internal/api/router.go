@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+
+	"ospab-panel/internal/core/rbac"
 )
 
 func (h *Handler) SetupRoutes() *mux.Router {
@@ -12,37 +14,124 @@ func (h *Handler) SetupRoutes() *mux.Router {
 	api := r.PathPrefix("/api").Subrouter()
 
 	// Публичные
-	api.HandleFunc("/auth/login", h.Login).Methods(http.MethodPost)
-	api.HandleFunc("/auth/register", h.Register).Methods(http.MethodPost)
+	api.HandleFunc("/auth/login", h.Login).Methods(http.MethodPost).Name("auth.login")
+	api.HandleFunc("/auth/register", h.Register).Methods(http.MethodPost).Name("auth.register")
+	api.HandleFunc("/auth/registration", h.RegistrationStatus).Methods(http.MethodGet)
+	api.HandleFunc("/auth/password-policy", h.PasswordPolicy).Methods(http.MethodGet)
+	api.HandleFunc("/auth/refresh", h.Refresh).Methods(http.MethodPost).Name("auth.refresh")
+	api.HandleFunc("/auth/2fa/verify", h.VerifyMFA).Methods(http.MethodPost).Name("auth.2fa.verify")
+	api.HandleFunc("/auth/webauthn/login/begin", h.WebAuthnLoginBegin).Methods(http.MethodPost).Name("auth.webauthn.login.begin")
+	api.HandleFunc("/auth/webauthn/login/finish", h.WebAuthnLoginFinish).Methods(http.MethodPost).Name("auth.webauthn.login.finish")
+	api.HandleFunc("/auth/oidc", h.OIDCStatus).Methods(http.MethodGet)
+	api.HandleFunc("/auth/oidc/login", h.OIDCLogin).Methods(http.MethodGet)
+	api.HandleFunc("/auth/oidc/callback", h.OIDCCallback).Methods(http.MethodGet).Name("auth.oidc.callback")
+	api.HandleFunc("/auth/oidc/exchange", h.OIDCExchange).Methods(http.MethodPost).Name("auth.oidc.exchange")
+	api.HandleFunc("/auth/verify", h.VerifyEmail).Methods(http.MethodPost).Name("auth.verify_email")
+	api.HandleFunc("/auth/forgot", h.ForgotPassword).Methods(http.MethodPost).Name("auth.forgot_password")
+	api.HandleFunc("/auth/reset", h.ResetPassword).Methods(http.MethodPost).Name("auth.reset_password")
 
 	// Защищённые
 	api.HandleFunc("/status", h.AuthMiddleware(h.Status)).Methods(http.MethodGet)
 	api.HandleFunc("/version", h.AuthMiddleware(h.Version)).Methods(http.MethodGet)
+	api.HandleFunc("/auth/verify/resend", h.AuthMiddleware(h.Personal(h.ResendVerification))).Methods(http.MethodPost).Name("auth.verify_email.resend")
+	api.HandleFunc("/auth/logout", h.AuthMiddleware(h.Interactive(h.Logout))).Methods(http.MethodPost).Name("auth.logout")
+	api.HandleFunc("/me", h.AuthMiddleware(h.GetMe)).Methods(http.MethodGet)
+	api.HandleFunc("/me", h.AuthMiddleware(h.Personal(h.UpdateMe))).Methods(http.MethodPatch).Name("me.update")
+	api.HandleFunc("/me", h.AuthMiddleware(h.Personal(h.DeleteMe))).Methods(http.MethodDelete).Name("me.delete")
+	api.HandleFunc("/me/email", h.AuthMiddleware(h.Personal(h.ChangeEmail))).Methods(http.MethodPost).Name("me.email")
+	api.HandleFunc("/me/sessions", h.AuthMiddleware(h.Interactive(h.ListSessions))).Methods(http.MethodGet)
+	api.HandleFunc("/me/sessions/{id}", h.AuthMiddleware(h.Interactive(h.RevokeSession))).Methods(http.MethodDelete).Name("me.sessions.revoke")
+	api.HandleFunc("/me/password", h.AuthMiddleware(h.Personal(h.ChangePassword))).Methods(http.MethodPut).Name("me.password")
+	api.HandleFunc("/me/2fa", h.AuthMiddleware(h.Personal(h.GetTOTPStatus))).Methods(http.MethodGet)
+	api.HandleFunc("/me/2fa/enroll", h.AuthMiddleware(h.Personal(h.EnrollTOTP))).Methods(http.MethodPost).Name("me.2fa.enroll")
+	api.HandleFunc("/me/2fa/enable", h.AuthMiddleware(h.Personal(h.EnableTOTP))).Methods(http.MethodPost).Name("me.2fa.enable")
+	api.HandleFunc("/me/2fa/disable", h.AuthMiddleware(h.Personal(h.DisableTOTP))).Methods(http.MethodPost).Name("me.2fa.disable")
+	api.HandleFunc("/me/2fa/recovery-codes", h.AuthMiddleware(h.Personal(h.RegenerateRecoveryCodes))).Methods(http.MethodPost).Name("me.2fa.recovery_codes")
+	api.HandleFunc("/auth/webauthn/register/begin", h.AuthMiddleware(h.Personal(h.WebAuthnRegisterBegin))).Methods(http.MethodPost).Name("me.webauthn.register.begin")
+	api.HandleFunc("/auth/webauthn/register/finish", h.AuthMiddleware(h.Personal(h.WebAuthnRegisterFinish))).Methods(http.MethodPost).Name("me.webauthn.register")
+	api.HandleFunc("/auth/webauthn/credentials", h.AuthMiddleware(h.Personal(h.ListWebAuthnCredentials))).Methods(http.MethodGet)
+	api.HandleFunc("/auth/webauthn/credentials/{id}", h.AuthMiddleware(h.Personal(h.DeleteWebAuthnCredential))).Methods(http.MethodDelete).Name("me.webauthn.delete")
+	api.HandleFunc("/me/api-keys", h.AuthMiddleware(h.Personal(h.ListAPIKeys))).Methods(http.MethodGet)
+	api.HandleFunc("/me/api-keys", h.AuthMiddleware(h.Personal(h.CreateAPIKey))).Methods(http.MethodPost).Name("me.api_keys.create")
+	api.HandleFunc("/me/api-keys/{id}", h.AuthMiddleware(h.Personal(h.RenameAPIKey))).Methods(http.MethodPatch).Name("me.api_keys.rename")
+	api.HandleFunc("/me/api-keys/{id}", h.AuthMiddleware(h.Personal(h.RevokeAPIKey))).Methods(http.MethodDelete).Name("me.api_keys.revoke")
 
 	// Серверы (CRUD)
-	sh := NewServerHandlers(h.serverService, h.hvFactory)
-	api.HandleFunc("/servers", h.AuthMiddleware(sh.GetServers)).Methods(http.MethodGet)
-	api.HandleFunc("/servers", h.AuthMiddleware(sh.CreateServer)).Methods(http.MethodPost)
-	api.HandleFunc("/servers/{id}", h.AuthMiddleware(sh.GetServer)).Methods(http.MethodGet)
-	api.HandleFunc("/servers/{id}", h.AuthMiddleware(sh.UpdateServer)).Methods(http.MethodPut, http.MethodPatch)
-	api.HandleFunc("/servers/{id}", h.AuthMiddleware(sh.DeleteServer)).Methods(http.MethodDelete)
+	sh := NewServerHandlers(h.serverService, h.hvFactory, h.inventory, h.syncer, h.confirm, h.rbac, h.orgs, h.acl, h.settings)
+	api.HandleFunc("/servers", h.AuthMiddleware(h.Permit(rbac.PermServersRead, sh.GetServers))).Methods(http.MethodGet)
+	api.HandleFunc("/servers", h.AuthMiddleware(h.Permit(rbac.PermServersWrite, sh.CreateServer))).Methods(http.MethodPost).Name("servers.create")
+	api.HandleFunc("/servers/{id}", h.AuthMiddleware(h.Permit(rbac.PermServersRead, sh.GetServer))).Methods(http.MethodGet)
+	api.HandleFunc("/servers/{id}", h.AuthMiddleware(h.Permit(rbac.PermServersWrite, sh.UpdateServer))).Methods(http.MethodPut, http.MethodPatch).Name("servers.update")
+	api.HandleFunc("/servers/{id}", h.AuthMiddleware(h.Permit(rbac.PermServersWrite, sh.DeleteServer))).Methods(http.MethodDelete).Name("servers.delete")
+
+	// Инстансы (права на конкретное действие проверяются в обработчике)
+	api.HandleFunc("/instances", h.AuthMiddleware(h.Permit(rbac.PermInstancesRead, sh.SearchInstances))).Methods(http.MethodGet)
+	api.HandleFunc("/instances/bulk", h.AuthMiddleware(h.Permit(rbac.PermInstancesRead, sh.BulkInstanceAction))).Methods(http.MethodPost).Name("instances.bulk")
+	api.HandleFunc("/servers/{id}/instances", h.AuthMiddleware(h.Permit(rbac.PermInstancesRead, sh.ListInstances))).Methods(http.MethodGet)
+	api.HandleFunc("/servers/{id}/instances/{instanceId}/events", h.AuthMiddleware(h.Permit(rbac.PermInstancesRead, sh.InstanceEvents))).Methods(http.MethodGet)
+	api.HandleFunc("/servers/{id}/instances/{instanceId}/protection", h.AuthMiddleware(h.Permit(rbac.PermInstancesRead, sh.GetProtection))).Methods(http.MethodGet)
+	api.HandleFunc("/servers/{id}/instances/{instanceId}/protection", h.AuthMiddleware(h.Permit(rbac.PermInstancesDelete, sh.SetProtection))).Methods(http.MethodPut).Name("instances.protection")
+	// confirm регистрируется раньше общего маршрута действий
+	api.HandleFunc("/servers/{id}/instances/{instanceId}/confirm", h.AuthMiddleware(h.Permit(rbac.PermInstancesRead, sh.ConfirmAction))).Methods(http.MethodPost).Name("instances.confirm")
+	api.HandleFunc("/servers/{id}/instances/{instanceId}/{action}", h.AuthMiddleware(h.Permit(rbac.PermInstancesRead, sh.InstanceAction))).Methods(http.MethodPost).Name("instances.{action}")
 
-	// Инстансы
-	api.HandleFunc("/servers/{id}/instances", h.AuthMiddleware(sh.ListInstances)).Methods(http.MethodGet)
-	api.HandleFunc("/servers/{id}/instances/{instanceId}/{action}", h.AuthMiddleware(sh.InstanceAction)).Methods(http.MethodPost)
+	// Гранты на отдельные инстансы
+	api.HandleFunc("/servers/{id}/grants", h.AuthMiddleware(h.Permit(rbac.PermServersWrite, sh.ListGrants))).Methods(http.MethodGet)
+	api.HandleFunc("/servers/{id}/grants", h.AuthMiddleware(h.Permit(rbac.PermServersWrite, sh.CreateGrant))).Methods(http.MethodPost).Name("grants.create")
+	api.HandleFunc("/servers/{id}/grants/{grantId}", h.AuthMiddleware(h.Permit(rbac.PermServersWrite, sh.DeleteGrant))).Methods(http.MethodDelete).Name("grants.delete")
 
 	// Hypervisor endpoints
-	api.HandleFunc("/hypervisors", h.AuthMiddleware(h.ListHypervisors)).Methods(http.MethodGet)
-	api.HandleFunc("/hypervisors/check", h.AuthMiddleware(h.CheckHypervisorConnection)).Methods(http.MethodPost)
-	api.HandleFunc("/servers/{id}/connection", h.AuthMiddleware(h.GetServerConnection)).Methods(http.MethodGet)
-	api.HandleFunc("/servers/{id}/connection", h.AuthMiddleware(h.UpdateServerConnection)).Methods(http.MethodPatch)
+	api.HandleFunc("/hypervisors", h.AuthMiddleware(h.Permit(rbac.PermServersRead, h.ListHypervisors))).Methods(http.MethodGet)
+	api.HandleFunc("/hypervisors/check", h.AuthMiddleware(h.Permit(rbac.PermServersWrite, h.CheckHypervisorConnection))).Methods(http.MethodPost).Name("hypervisors.check")
+	api.HandleFunc("/servers/{id}/connection", h.AuthMiddleware(h.Permit(rbac.PermServersRead, h.GetServerConnection))).Methods(http.MethodGet)
+	api.HandleFunc("/servers/{id}/connection", h.AuthMiddleware(h.Permit(rbac.PermServersWrite, h.UpdateServerConnection))).Methods(http.MethodPatch).Name("servers.connection.update")
+
+	// Организации
+	api.HandleFunc("/orgs", h.AuthMiddleware(h.Permit(rbac.PermServersRead, h.ListOrganizations))).Methods(http.MethodGet)
+	api.HandleFunc("/orgs", h.AuthMiddleware(h.Permit(rbac.PermServersWrite, h.CreateOrganization))).Methods(http.MethodPost).Name("orgs.create")
+	api.HandleFunc("/orgs/{id}", h.AuthMiddleware(h.Permit(rbac.PermServersRead, h.GetOrganization))).Methods(http.MethodGet)
+	api.HandleFunc("/orgs/{id}", h.AuthMiddleware(h.Permit(rbac.PermServersWrite, h.DeleteOrganization))).Methods(http.MethodDelete).Name("orgs.delete")
+	api.HandleFunc("/orgs/{id}/members/{userId}", h.AuthMiddleware(h.Permit(rbac.PermServersRead, h.SetMemberRole))).Methods(http.MethodPut).Name("orgs.members.set_role")
+	api.HandleFunc("/orgs/{id}/members/{userId}", h.AuthMiddleware(h.Permit(rbac.PermServersRead, h.RemoveMember))).Methods(http.MethodDelete).Name("orgs.members.remove")
+	api.HandleFunc("/orgs/{id}/invites", h.AuthMiddleware(h.Permit(rbac.PermServersRead, h.ListInvites))).Methods(http.MethodGet)
+	api.HandleFunc("/orgs/{id}/invites", h.AuthMiddleware(h.Permit(rbac.PermServersRead, h.CreateInvite))).Methods(http.MethodPost).Name("orgs.invites.create")
+	api.HandleFunc("/orgs/{id}/invites/{inviteId}", h.AuthMiddleware(h.Permit(rbac.PermServersRead, h.RevokeInvite))).Methods(http.MethodDelete).Name("orgs.invites.revoke")
+	api.HandleFunc("/invites/accept", h.AuthMiddleware(h.Permit(rbac.PermServersRead, h.AcceptInvite))).Methods(http.MethodPost).Name("invites.accept")
+
+	// Роли и права
+	api.HandleFunc("/roles", h.AuthMiddleware(h.Permit(rbac.PermUsersManage, h.ListRoles))).Methods(http.MethodGet)
+	api.HandleFunc("/roles", h.AuthMiddleware(h.Permit(rbac.PermUsersManage, h.CreateRole))).Methods(http.MethodPost).Name("roles.create")
+	api.HandleFunc("/roles/{name}", h.AuthMiddleware(h.Permit(rbac.PermUsersManage, h.UpdateRole))).Methods(http.MethodPut).Name("roles.update")
+	api.HandleFunc("/roles/{name}", h.AuthMiddleware(h.Permit(rbac.PermUsersManage, h.DeleteRole))).Methods(http.MethodDelete).Name("roles.delete")
+	api.HandleFunc("/admin/lockouts", h.AuthMiddleware(h.Permit(rbac.PermUsersManage, h.ListLockouts))).Methods(http.MethodGet)
+	api.HandleFunc("/admin/lockouts/unlock", h.AuthMiddleware(h.Permit(rbac.PermUsersManage, h.UnlockLogin))).Methods(http.MethodPost).Name("admin.lockouts.unlock")
+	api.HandleFunc("/admin/users", h.AuthMiddleware(h.Permit(rbac.PermUsersManage, h.ListUsers))).Methods(http.MethodGet)
+	api.HandleFunc("/admin/users", h.AuthMiddleware(h.Permit(rbac.PermUsersManage, h.CreateUser))).Methods(http.MethodPost).Name("admin.users.create")
+	api.HandleFunc("/admin/users/{id}", h.AuthMiddleware(h.Permit(rbac.PermUsersManage, h.GetUser))).Methods(http.MethodGet)
+	api.HandleFunc("/admin/users/{id}/disable", h.AuthMiddleware(h.Permit(rbac.PermUsersManage, h.SetUserDisabled(true)))).Methods(http.MethodPost).Name("admin.users.disable")
+	api.HandleFunc("/admin/users/{id}/enable", h.AuthMiddleware(h.Permit(rbac.PermUsersManage, h.SetUserDisabled(false)))).Methods(http.MethodPost).Name("admin.users.enable")
+	api.HandleFunc("/admin/users/{id}/reset-password", h.AuthMiddleware(h.Permit(rbac.PermUsersManage, h.ForcePasswordReset))).Methods(http.MethodPost).Name("admin.users.reset_password")
+	api.HandleFunc("/admin/users/{id}/impersonate", h.AuthMiddleware(h.Personal(h.Permit(rbac.PermUsersManage, h.Impersonate)))).Methods(http.MethodPost).Name("admin.users.impersonate")
+	api.HandleFunc("/admin/users/{id}/role", h.AuthMiddleware(h.Permit(rbac.PermUsersManage, h.SetUserRole))).Methods(http.MethodPut).Name("admin.users.role")
+
+	// Журнал аудита
+	api.HandleFunc("/admin/audit", h.AuthMiddleware(h.Permit(rbac.PermAuditRead, h.ListAudit))).Methods(http.MethodGet)
+	api.HandleFunc("/admin/audit/export", h.AuthMiddleware(h.Permit(rbac.PermAuditRead, h.ExportAudit))).Methods(http.MethodGet).Name("audit.export")
+	api.HandleFunc("/admin/audit/verify", h.AuthMiddleware(h.Permit(rbac.PermAuditRead, h.VerifyAudit))).Methods(http.MethodGet).Name("audit.verify")
+
+	// Настройки панели
+	api.HandleFunc("/admin/settings", h.AuthMiddleware(h.Permit(rbac.PermUsersManage, h.GetSettings))).Methods(http.MethodGet)
+	api.HandleFunc("/admin/settings", h.AuthMiddleware(h.Permit(rbac.PermUsersManage, h.UpdateSettings))).Methods(http.MethodPut).Name("admin.settings.update")
+
+	// Журнал изменяющих запросов и попыток входа
+	api.Use(h.Audit)
 
 	// CORS
 	api.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
 			if r.Method == http.MethodOptions {
 				w.WriteHeader(http.StatusOK)
 				return
@@ -2,7 +2,9 @@ package api
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -10,17 +12,30 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"ospab-panel/internal/core/acl"
+	"ospab-panel/internal/core/confirm"
+	"ospab-panel/internal/core/inventory"
+	"ospab-panel/internal/core/org"
+	"ospab-panel/internal/core/rbac"
 	"ospab-panel/internal/core/server"
+	"ospab-panel/internal/core/settings"
 	"ospab-panel/internal/hypervisor"
 )
 
 type ServerHandlers struct {
 	serverService *server.Service
 	hvFactory     *hypervisor.HypervisorFactory
+	inventory     *inventory.Service
+	syncer        *inventory.Syncer
+	confirm       *confirm.Service
+	rbac          *rbac.Service
+	orgs          *org.Service
+	acl           *acl.Service
+	settings      *settings.Service
 }
 
-func NewServerHandlers(serverService *server.Service, hvFactory *hypervisor.HypervisorFactory) *ServerHandlers {
-	return &ServerHandlers{serverService: serverService, hvFactory: hvFactory}
+func NewServerHandlers(serverService *server.Service, hvFactory *hypervisor.HypervisorFactory, inv *inventory.Service, syncer *inventory.Syncer, confirmService *confirm.Service, rbacService *rbac.Service, orgService *org.Service, aclService *acl.Service, settingsService *settings.Service) *ServerHandlers {
+	return &ServerHandlers{serverService: serverService, hvFactory: hvFactory, inventory: inv, syncer: syncer, confirm: confirmService, rbac: rbacService, orgs: orgService, acl: aclService, settings: settingsService}
 }
 
 func (h *ServerHandlers) GetServers(w http.ResponseWriter, r *http.Request) {
@@ -44,6 +59,10 @@ func (h *ServerHandlers) CreateServer(w http.ResponseWriter, r *http.Request) {
 		sendErr(w, http.StatusBadRequest, "missing fields")
 		return
 	}
+	if req.OrganizationID != nil && *req.OrganizationID != 0 && !orgAllows(h.orgs, h.rbac, r, *req.OrganizationID, rbac.PermServersWrite) {
+		sendErr(w, http.StatusForbidden, "permission denied: organization")
+		return
+	}
 	srv, err := h.serverService.CreateServer(&req, uid)
 	if err != nil {
 		sendErr(w, http.StatusInternalServerError, err.Error())
@@ -71,6 +90,19 @@ func (h *ServerHandlers) UpdateServer(w http.ResponseWriter, r *http.Request) {
 		sendErr(w, http.StatusBadRequest, "invalid json")
 		return
 	}
+	existing, err := h.serverService.GetServerByID(id, uid)
+	if err != nil {
+		sendErr(w, http.StatusNotFound, "not found")
+		return
+	}
+	if !serverAllows(h.rbac, r, existing, rbac.PermServersWrite) {
+		sendErr(w, http.StatusForbidden, "permission denied: "+string(rbac.PermServersWrite))
+		return
+	}
+	if req.OrganizationID != nil && *req.OrganizationID != 0 && !orgAllows(h.orgs, h.rbac, r, *req.OrganizationID, rbac.PermServersWrite) {
+		sendErr(w, http.StatusForbidden, "permission denied: organization")
+		return
+	}
 	srv, err := h.serverService.UpdateServer(id, uid, &req)
 	if err != nil {
 		sendErr(w, http.StatusInternalServerError, err.Error())
@@ -82,6 +114,15 @@ func (h *ServerHandlers) UpdateServer(w http.ResponseWriter, r *http.Request) {
 func (h *ServerHandlers) DeleteServer(w http.ResponseWriter, r *http.Request) {
 	uid := userIDFromHeader(r)
 	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	srv, err := h.serverService.GetServerByID(id, uid)
+	if err != nil {
+		sendErr(w, http.StatusNotFound, "server not found")
+		return
+	}
+	if !serverAllows(h.rbac, r, srv, rbac.PermServersWrite) {
+		sendErr(w, http.StatusForbidden, "permission denied: "+string(rbac.PermServersWrite))
+		return
+	}
 	if err := h.serverService.DeleteServer(id, uid); err != nil {
 		sendErr(w, http.StatusNotFound, err.Error())
 		return
@@ -90,31 +131,77 @@ func (h *ServerHandlers) DeleteServer(w http.ResponseWriter, r *http.Request) {
 }
 
 // --- Instances (объединённо VM/LXC) ---
+// Отдаются из локального инвентаря; ?refresh=true принудительно опрашивает гипервизор.
+// Если сервер ещё ни разу не синхронизировался, опрос выполняется сразу.
+// При доступе по грантам список ограничен выданными инстансами.
 func (h *ServerHandlers) ListInstances(w http.ResponseWriter, r *http.Request) {
 	uid := userIDFromHeader(r)
 	id, _ := strconv.Atoi(mux.Vars(r)["id"])
-	srv, err := h.serverService.GetServerByID(id, uid)
+	srv, grants, err := h.serverForUser(uid, id)
 	if err != nil {
 		sendErr(w, http.StatusNotFound, "server not found")
 		return
 	}
-	client, err := h.hvFactory.CreateClient(srv.Type)
+	state, err := h.inventory.GetSyncState(srv.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		sendErr(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if state == nil || state.LastSuccessAt == nil || r.URL.Query().Get("refresh") == "true" {
+		if _, err := h.syncer.SyncServer(r.Context(), srv); err != nil {
+			sendErr(w, hypervisorErrStatus(err), err.Error())
+			return
+		}
+		if state, err = h.inventory.GetSyncState(srv.ID); err != nil {
+			sendErr(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	instances, err := h.inventory.ListByServer(srv.ID, r.URL.Query().Get("include_gone") == "true")
 	if err != nil {
-		sendErr(w, http.StatusBadRequest, err.Error())
+		sendErr(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
-	defer cancel()
-	if err := client.Connect(ctx, &hypervisor.Server{ID: srv.ID, Name: srv.Name, Host: srv.Host, Port: srv.Port, Type: srv.Type, Username: srv.UsernameDecrypted, Password: srv.PasswordDecrypted, UserID: srv.UserID, IsActive: srv.IsActive}); err != nil {
-		sendErr(w, http.StatusBadGateway, "connect failed")
+	if grants != nil {
+		visible := instances[:0]
+		for _, in := range instances {
+			if grants.Visible(srv.ID, in.Type, in.ID) {
+				visible = append(visible, in)
+			}
+		}
+		instances = visible
+	}
+	if state.LastSuccessAt != nil {
+		w.Header().Set("X-Inventory-Synced-At", state.LastSuccessAt.UTC().Format(time.RFC3339))
+	}
+	if state.LastError != "" {
+		w.Header().Set("X-Inventory-Sync-Error", state.LastError)
+	}
+	sendJSON(w, http.StatusOK, instances)
+}
+
+// GET /api/servers/{id}/instances/{instanceId}/events
+func (h *ServerHandlers) InstanceEvents(w http.ResponseWriter, r *http.Request) {
+	uid := userIDFromHeader(r)
+	vars := mux.Vars(r)
+	sid, _ := strconv.Atoi(vars["id"])
+	srv, grants, err := h.serverForUser(uid, sid)
+	if err != nil {
+		sendErr(w, http.StatusNotFound, "server not found")
 		return
 	}
-	instances, err := client.GetInstances(ctx)
+	instType := instanceTypeFromQuery(r)
+	if grants != nil && !grants.Visible(srv.ID, instType, vars["instanceId"]) {
+		sendErr(w, http.StatusNotFound, "instance not found")
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	events, err := h.inventory.Events(srv.ID, instType, vars["instanceId"], limit)
 	if err != nil {
-		sendErr(w, http.StatusBadGateway, err.Error())
+		sendErr(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	sendJSON(w, http.StatusOK, instances)
+	sendJSON(w, http.StatusOK, events)
 }
 
 func (h *ServerHandlers) InstanceAction(w http.ResponseWriter, r *http.Request) {
@@ -123,59 +210,65 @@ func (h *ServerHandlers) InstanceAction(w http.ResponseWriter, r *http.Request)
 	sid, _ := strconv.Atoi(vars["id"])
 	action := vars["action"]
 	instID := vars["instanceId"]
-	srv, err := h.serverService.GetServerByID(sid, uid)
+	if !knownAction(action) {
+		sendErr(w, http.StatusBadRequest, "unsupported action")
+		return
+	}
+	srv, grants, err := h.serverForUser(uid, sid)
 	if err != nil {
 		sendErr(w, http.StatusNotFound, "server not found")
 		return
 	}
-	client, err := h.hvFactory.CreateClient(srv.Type)
+	instType := instanceTypeFromQuery(r)
+	if allowed, visible := h.instanceActionAllowed(r, srv, grants, instType, instID, action); !visible {
+		sendErr(w, http.StatusNotFound, "instance not found")
+		return
+	} else if !allowed {
+		sendErr(w, http.StatusForbidden, h.actionDenied(r, action))
+		return
+	}
+	params, err := actionParamsFromQuery(r)
 	if err != nil {
 		sendErr(w, http.StatusBadRequest, err.Error())
 		return
 	}
 	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
 	defer cancel()
-	if err := client.Connect(ctx, &hypervisor.Server{ID: srv.ID, Name: srv.Name, Host: srv.Host, Port: srv.Port, Type: srv.Type, Username: srv.UsernameDecrypted, Password: srv.PasswordDecrypted, UserID: srv.UserID, IsActive: srv.IsActive}); err != nil {
-		sendErr(w, http.StatusBadGateway, "connect failed")
+	// Неподдерживаемое бэкендом действие отклоняется до подключения
+	client, err := h.connectForAction(ctx, srv.Type, hvServer(srv), action, instType)
+	if err != nil {
+		sendErr(w, actionErrStatus(err), err.Error())
 		return
 	}
-	instType := instanceTypeFromQuery(r)
-	var actErr error
-	switch action {
-	case "start":
-		actErr = client.StartInstance(ctx, instType, instID)
-	case "stop":
-		actErr = client.StopInstance(ctx, instType, instID)
-	case "restart":
-		actErr = client.RestartInstance(ctx, instType, instID)
-	case "status":
-		st, err := client.GetInstanceStatus(ctx, instType, instID)
-		if err != nil {
-			sendErr(w, http.StatusBadGateway, err.Error())
+	if destructiveActions[action] {
+		target := confirm.Target{ServerID: srv.ID, InstanceType: instType, InstanceID: instID}
+		if err := h.checkDestructive(ctx, client, uid, target, action, confirmTokenFromRequest(r)); err != nil {
+			sendErr(w, actionErrStatus(err), err.Error())
 			return
 		}
-		sendJSON(w, http.StatusOK, map[string]string{"status": st})
-		return
-	case "config":
-		cfg, err := client.GetInstanceConfig(ctx, instType, instID)
-		if err != nil {
-			sendErr(w, http.StatusBadGateway, err.Error())
-			return
-		}
-		sendJSON(w, http.StatusOK, cfg)
-		return
-	default:
-		sendErr(w, http.StatusBadRequest, "unsupported action")
+	}
+	res, err := runInstanceAction(ctx, client, action, instType, instID, params)
+	if err != nil {
+		sendErr(w, actionErrStatus(err), err.Error())
 		return
 	}
-	if actErr != nil {
-		sendErr(w, http.StatusBadGateway, actErr.Error())
+	if res != nil {
+		sendJSON(w, http.StatusOK, res)
 		return
 	}
 	sendJSON(w, http.StatusOK, map[string]string{"result": "ok"})
 }
 
 // --- helpers ---
+func hvServer(srv *server.Server) *hypervisor.Server {
+	return &hypervisor.Server{ID: srv.ID, Name: srv.Name, Host: srv.Host, Port: srv.Port, Type: srv.Type, Username: srv.UsernameDecrypted, Password: srv.PasswordDecrypted, UserID: srv.UserID, IsActive: srv.IsActive}
+}
+func hypervisorErrStatus(err error) int {
+	if errors.Is(err, hypervisor.ErrUnsupportedHypervisor) {
+		return http.StatusBadRequest
+	}
+	return http.StatusBadGateway
+}
 func userIDFromHeader(r *http.Request) int {
 	v := r.Header.Get("X-User-ID")
 	id, _ := strconv.Atoi(v)
@@ -189,6 +282,30 @@ func sendJSON(w http.ResponseWriter, code int, v interface{}) {
 func sendErr(w http.ResponseWriter, code int, msg string) {
 	sendJSON(w, code, map[string]string{"error": msg})
 }
+
+// Токен подтверждения: заголовок X-Confirm-Token или ?confirm=
+func confirmTokenFromRequest(r *http.Request) string {
+	if t := r.Header.Get("X-Confirm-Token"); t != "" {
+		return t
+	}
+	return r.URL.Query().Get("confirm")
+}
+
+// actionParamsFromQuery: ?name= для snapshot/rollback, ?timeout=<сек>&force=true для shutdown
+func actionParamsFromQuery(r *http.Request) (actionParams, error) {
+	q := r.URL.Query()
+	p := actionParams{SnapshotName: q.Get("name")}
+	if v := q.Get("timeout"); v != "" {
+		sec, err := strconv.Atoi(v)
+		if err != nil || sec < 0 || sec > 3600 {
+			return p, errors.New("invalid timeout")
+		}
+		p.Shutdown.Timeout = time.Duration(sec) * time.Second
+	}
+	p.Shutdown.Force = q.Get("force") == "true" || q.Get("force") == "1"
+	return p, nil
+}
+
 func instanceTypeFromQuery(r *http.Request) string {
 	t := r.URL.Query().Get("type")
 	if t == "" {
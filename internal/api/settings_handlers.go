@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"ospab-panel/internal/core/settings"
+)
+
+// GET /api/admin/settings
+func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	all, err := h.settings.All()
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, all)
+}
+
+// PUT /api/admin/settings — частичное обновление: {"mfa_required_for_delete": "true"}
+func (h *Handler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	var req map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if err := h.settings.Update(req); err != nil {
+		if errors.Is(err, settings.ErrUnknownKey) || errors.Is(err, settings.ErrInvalidValue) {
+			h.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.GetSettings(w, r)
+}
+
+// GET /api/auth/registration — {"enabled": true}, если открыта регистрация
+func (h *Handler) RegistrationStatus(w http.ResponseWriter, r *http.Request) {
+	h.sendJSON(w, http.StatusOK, map[string]bool{"enabled": h.settings.Bool(settings.RegistrationEnabled)})
+}
+
+// GET /api/auth/password-policy — требования к паролю для подсказок в формах
+func (h *Handler) PasswordPolicy(w http.ResponseWriter, r *http.Request) {
+	h.sendJSON(w, http.StatusOK, h.userService.PasswordRequirements())
+}
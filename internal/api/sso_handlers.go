@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"ospab-panel/internal/core/challenge"
+	"ospab-panel/internal/core/sso"
+	"ospab-panel/internal/core/user"
+)
+
+// GET /api/auth/oidc — доступен ли вход через SSO (для кнопки на странице входа)
+func (h *Handler) OIDCStatus(w http.ResponseWriter, r *http.Request) {
+	h.sendJSON(w, http.StatusOK, map[string]bool{"enabled": h.sso.Enabled()})
+}
+
+// GET /api/auth/oidc/login — перенаправляет браузер к провайдеру OIDC
+func (h *Handler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.sso.Enabled() {
+		h.sendError(w, http.StatusNotFound, "SSO не настроен")
+		return
+	}
+	target, err := h.sso.AuthURL(r.Context())
+	if err != nil {
+		log.Printf("OIDC discovery failed: %v", err)
+		h.sendError(w, http.StatusBadGateway, "Провайдер SSO недоступен")
+		return
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// GET /api/auth/oidc/callback — возврат от провайдера. Браузер уходит на страницу входа
+// фронтенда с одноразовым sso_code (или sso_error).
+func (h *Handler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		http.Redirect(w, r, h.sso.FrontendRedirect("sso_error", e), http.StatusFound)
+		return
+	}
+	id, err := h.sso.Callback(r.Context(), q.Get("code"), q.Get("state"))
+	if err != nil {
+		log.Printf("OIDC callback failed from %s: %v", clientIP(r), err)
+		http.Redirect(w, r, h.sso.FrontendRedirect("sso_error", "Не удалось выполнить вход через SSO"), http.StatusFound)
+		return
+	}
+	u, err := h.sso.Provision(id)
+	if err != nil {
+		log.Printf("OIDC login of %s/%s rejected: %v", id.Issuer, id.Subject, err)
+		msg := "Не удалось выполнить вход через SSO"
+		switch {
+		case errors.Is(err, sso.ErrNoRole):
+			msg = "Доступ к панели для вашей группы не предусмотрен"
+		case errors.Is(err, user.ErrEmailTaken):
+			msg = "Email уже используется другим пользователем"
+		}
+		http.Redirect(w, r, h.sso.FrontendRedirect("sso_error", msg), http.StatusFound)
+		return
+	}
+	code, err := h.sso.IssueResult(u.ID, id.MFA)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	http.Redirect(w, r, h.sso.FrontendRedirect("sso_code", code), http.StatusFound)
+}
+
+// POST /api/auth/oidc/exchange — {"code": "<sso_code>"} → токены панели (как у /api/auth/login)
+func (h *Handler) OIDCExchange(w http.ResponseWriter, r *http.Request) {
+	var req sso.ExchangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	uid, mfa, err := h.sso.Redeem(req.Code)
+	if err != nil {
+		if errors.Is(err, challenge.ErrInvalidChallenge) {
+			h.sendError(w, http.StatusUnauthorized, "Код входа недействителен, войдите заново")
+			return
+		}
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	u, err := h.userService.GetUserByID(uid)
+	if err != nil {
+		h.sendError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+	h.completeLogin(w, r, u, mfa)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"ospab-panel/internal/core/challenge"
+	"ospab-panel/internal/core/passkey"
+)
+
+// POST /api/auth/webauthn/register/begin — параметры для navigator.credentials.create
+func (h *Handler) WebAuthnRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	c, err := h.passkeys.BeginRegistration(atoi(r.Header.Get("X-User-ID")))
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, c)
+}
+
+// POST /api/auth/webauthn/register/finish?challenge_token=...&name=... — тело: ответ аутентификатора
+func (h *Handler) WebAuthnRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	cred, err := h.passkeys.FinishRegistration(atoi(r.Header.Get("X-User-ID")), q.Get("challenge_token"), q.Get("name"), r)
+	if err != nil {
+		h.sendError(w, passkeyErrStatus(err), err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusCreated, cred)
+}
+
+// POST /api/auth/webauthn/login/begin — {"username": "..."} необязателен
+func (h *Handler) WebAuthnLoginBegin(w http.ResponseWriter, r *http.Request) {
+	var req passkey.BeginLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	c, err := h.passkeys.BeginLogin(req.Username)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, c)
+}
+
+// POST /api/auth/webauthn/login/finish?challenge_token=... — тело: ответ аутентификатора.
+// Токены выдаются тем же путём, что и при входе по паролю.
+func (h *Handler) WebAuthnLoginFinish(w http.ResponseWriter, r *http.Request) {
+	uid, verified, err := h.passkeys.FinishLogin(r.URL.Query().Get("challenge_token"), r)
+	if err != nil {
+		if status := passkeyErrStatus(err); status == http.StatusInternalServerError {
+			h.sendError(w, status, err.Error())
+			return
+		}
+		log.Printf("WebAuthn login failed from %s: %v", clientIP(r), err)
+		h.sendError(w, http.StatusUnauthorized, "Не удалось войти по ключу")
+		return
+	}
+	u, err := h.userService.GetUserByID(uid)
+	if err != nil {
+		h.sendError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+	// Ключ с проверкой пользователя (PIN, биометрия) сам по себе двухфакторный
+	h.completeLogin(w, r, u, verified)
+}
+
+// GET /api/auth/webauthn/credentials
+func (h *Handler) ListWebAuthnCredentials(w http.ResponseWriter, r *http.Request) {
+	list, err := h.passkeys.List(atoi(r.Header.Get("X-User-ID")))
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, list)
+}
+
+// DELETE /api/auth/webauthn/credentials/{id}
+func (h *Handler) DeleteWebAuthnCredential(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.passkeys.Delete(atoi(r.Header.Get("X-User-ID")), id); err != nil {
+		h.sendError(w, passkeyErrStatus(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func passkeyErrStatus(err error) int {
+	switch {
+	case errors.Is(err, challenge.ErrInvalidChallenge), errors.Is(err, passkey.ErrCloned):
+		return http.StatusUnauthorized
+	case errors.Is(err, passkey.ErrCeremonyFailed):
+		return http.StatusBadRequest
+	case errors.Is(err, passkey.ErrCredentialExists):
+		return http.StatusConflict
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
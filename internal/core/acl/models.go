@@ -0,0 +1,89 @@
+package acl
+
+import (
+	"time"
+
+	"ospab-panel/internal/hypervisor"
+)
+
+// Действия, которые можно выдать грантом. Просмотр (список, status, config)
+// подразумевается любым грантом на инстанс. Консоли в панели нет — её грант не выдаётся.
+var grantableActions = map[string]bool{
+	hypervisor.ActionStart:     true,
+	hypervisor.ActionStop:      true,
+	hypervisor.ActionShutdown:  true,
+	hypervisor.ActionRestart:   true,
+	hypervisor.ActionReset:     true,
+	hypervisor.ActionSuspend:   true,
+	hypervisor.ActionResume:    true,
+	hypervisor.ActionHibernate: true,
+	hypervisor.ActionSnapshot:  true,
+	hypervisor.ActionRollback:  true,
+	hypervisor.ActionReinstall: true,
+	hypervisor.ActionDelete:    true,
+}
+
+// Grant — доступ пользователя или организации к одному инстансу чужого сервера
+type Grant struct {
+	ID             int       `json:"id"`
+	ServerID       int       `json:"server_id"`
+	InstanceType   string    `json:"type"`
+	InstanceID     string    `json:"instance_id"`
+	UserID         *int      `json:"user_id,omitempty"`
+	OrganizationID *int      `json:"organization_id,omitempty"`
+	Actions        []string  `json:"actions"`
+	CreatedBy      int       `json:"created_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type CreateGrantRequest struct {
+	InstanceID     string   `json:"instance_id"`
+	Type           string   `json:"type"`
+	UserID         *int     `json:"user_id,omitempty"`
+	OrganizationID *int     `json:"organization_id,omitempty"`
+	Actions        []string `json:"actions"`
+}
+
+// Key — инстанс на конкретном сервере
+type Key struct {
+	ServerID     int
+	InstanceType string
+	InstanceID   string
+}
+
+// Access — объединённые гранты пользователя: инстанс → разрешённые действия
+type Access map[Key]map[string]bool
+
+// HasServer сообщает, есть ли у пользователя гранты на инстансы сервера
+func (a Access) HasServer(serverID int) bool {
+	for k := range a {
+		if k.ServerID == serverID {
+			return true
+		}
+	}
+	return false
+}
+
+// ServerIDs — серверы, на которых у пользователя есть гранты
+func (a Access) ServerIDs() []int {
+	seen := map[int]bool{}
+	ids := []int{}
+	for k := range a {
+		if !seen[k.ServerID] {
+			seen[k.ServerID] = true
+			ids = append(ids, k.ServerID)
+		}
+	}
+	return ids
+}
+
+// Visible — виден ли инстанс (есть хотя бы один грант)
+func (a Access) Visible(serverID int, instanceType, instanceID string) bool {
+	_, ok := a[Key{serverID, instanceType, instanceID}]
+	return ok
+}
+
+// Allows — разрешено ли действие над инстансом
+func (a Access) Allows(serverID int, instanceType, instanceID, action string) bool {
+	return a[Key{serverID, instanceType, instanceID}][action]
+}
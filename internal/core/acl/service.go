@@ -0,0 +1,152 @@
+package acl
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrGrantNotFound    = errors.New("grant_not_found")
+	ErrInvalidPrincipal = errors.New("exactly one of user_id and organization_id is required")
+	ErrInvalidGrant     = errors.New("invalid_grant")
+	ErrUnknownAction    = errors.New("unknown_action")
+)
+
+// Service хранит гранты на отдельные инстансы (instance_grants)
+type Service struct{ db *sql.DB }
+
+func NewService(db *sql.DB) *Service { return &Service{db: db} }
+
+const grantColumns = `id,server_id,instance_type,instance_id,user_id,org_id,actions,created_by,created_at`
+
+func scanGrant(sc interface{ Scan(...any) error }) (*Grant, error) {
+	var g Grant
+	var userID, orgID sql.NullInt64
+	var actions string
+	if err := sc.Scan(&g.ID, &g.ServerID, &g.InstanceType, &g.InstanceID, &userID, &orgID, &actions, &g.CreatedBy, &g.CreatedAt); err != nil {
+		return nil, err
+	}
+	if userID.Valid {
+		id := int(userID.Int64)
+		g.UserID = &id
+	}
+	if orgID.Valid {
+		id := int(orgID.Int64)
+		g.OrganizationID = &id
+	}
+	g.Actions = []string{}
+	if actions != "" {
+		g.Actions = strings.Split(actions, ",")
+	}
+	return &g, nil
+}
+
+func (s *Service) query(query string, args ...interface{}) ([]*Grant, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*Grant{}
+	for rows.Next() {
+		g, err := scanGrant(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, g)
+	}
+	return list, rows.Err()
+}
+
+// Create выдаёт грант; повторная выдача тому же субъекту на тот же инстанс заменяет список действий
+func (s *Service) Create(serverID, createdBy int, req *CreateGrantRequest) (*Grant, error) {
+	if (req.UserID == nil) == (req.OrganizationID == nil) {
+		return nil, ErrInvalidPrincipal
+	}
+	req.Type = strings.ToLower(req.Type)
+	if req.Type == "" {
+		req.Type = "vm"
+	}
+	if req.InstanceID == "" || (req.Type != "vm" && req.Type != "lxc") || len(req.Actions) == 0 {
+		return nil, ErrInvalidGrant
+	}
+	set := map[string]bool{}
+	for _, a := range req.Actions {
+		if !grantableActions[a] {
+			return nil, ErrUnknownAction
+		}
+		set[a] = true
+	}
+	actions := make([]string, 0, len(set))
+	for a := range set {
+		actions = append(actions, a)
+	}
+	sort.Strings(actions)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var id int64
+	err = tx.QueryRow(`SELECT id FROM instance_grants WHERE server_id=? AND instance_type=? AND instance_id=? AND user_id <=> ? AND org_id <=> ? FOR UPDATE`,
+		serverID, req.Type, req.InstanceID, req.UserID, req.OrganizationID).Scan(&id)
+	switch {
+	case err == nil:
+		if _, err := tx.Exec(`UPDATE instance_grants SET actions=? WHERE id=?`, strings.Join(actions, ","), id); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		res, err := tx.Exec(`INSERT INTO instance_grants (server_id,instance_type,instance_id,user_id,org_id,actions,created_by,created_at) VALUES (?,?,?,?,?,?,?,?)`,
+			serverID, req.Type, req.InstanceID, req.UserID, req.OrganizationID, strings.Join(actions, ","), createdBy, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		id, _ = res.LastInsertId()
+	default:
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return scanGrant(s.db.QueryRow(`SELECT `+grantColumns+` FROM instance_grants WHERE id=?`, id))
+}
+
+// ListByServer возвращает все гранты на инстансы сервера
+func (s *Service) ListByServer(serverID int) ([]*Grant, error) {
+	return s.query(`SELECT `+grantColumns+` FROM instance_grants WHERE server_id=? ORDER BY instance_type, instance_id, id`, serverID)
+}
+
+func (s *Service) Delete(serverID, grantID int) error {
+	res, err := s.db.Exec(`DELETE FROM instance_grants WHERE id=? AND server_id=?`, grantID, serverID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrGrantNotFound
+	}
+	return nil
+}
+
+// UserAccess собирает гранты пользователя — выданные лично и через его организации
+func (s *Service) UserAccess(userID int) (Access, error) {
+	grants, err := s.query(`SELECT `+grantColumns+` FROM instance_grants g
+		WHERE g.user_id=? OR g.org_id IN (SELECT org_id FROM organization_members WHERE user_id=?)`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	access := Access{}
+	for _, g := range grants {
+		k := Key{g.ServerID, g.InstanceType, g.InstanceID}
+		if access[k] == nil {
+			access[k] = map[string]bool{}
+		}
+		for _, a := range g.Actions {
+			access[k][a] = true
+		}
+	}
+	return access, nil
+}
@@ -0,0 +1,77 @@
+package apikey
+
+import (
+	"time"
+
+	"ospab-panel/internal/core/rbac"
+)
+
+// Prefix — начало каждого ключа: по нему AuthMiddleware отличает ключ от JWT
+const Prefix = "ospab_"
+
+// Scope ограничивает права ключа; итоговые права — пересечение с ролью владельца
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"  // только чтение
+	ScopePower Scope = "power" // чтение, питание и снапшоты
+	ScopeAdmin Scope = "admin" // все права роли
+)
+
+var scopePermissions = map[Scope][]rbac.Permission{
+	ScopeRead:  {rbac.PermServersRead, rbac.PermServersAll, rbac.PermInstancesRead},
+	ScopePower: {rbac.PermServersRead, rbac.PermServersAll, rbac.PermInstancesRead, rbac.PermInstancesPower, rbac.PermInstancesSnapshot},
+	ScopeAdmin: rbac.AllPermissions,
+}
+
+func (s Scope) Valid() bool {
+	_, ok := scopePermissions[s]
+	return ok
+}
+
+// Allows — входит ли право в область ключа
+func (s Scope) Allows(perm rbac.Permission) bool {
+	for _, p := range scopePermissions[s] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Key — API-ключ пользователя (сам ключ хранится только в виде хэша)
+type Key struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // первые символы ключа, чтобы узнать его в списке
+	Scope      Scope      `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// Principal — владелец ключа, предъявленного в запросе
+type Principal struct {
+	KeyID    int
+	Scope    Scope
+	UserID   int
+	Username string
+	Role     string
+}
+
+type CreateRequest struct {
+	Name      string     `json:"name"`
+	Scope     Scope      `json:"scope"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // пусто — бессрочный
+}
+
+type RenameRequest struct {
+	Name string `json:"name"`
+}
+
+// CreateResponse — ключ в открытом виде возвращается только при создании
+type CreateResponse struct {
+	Key    string `json:"key"`
+	APIKey *Key   `json:"api_key"`
+}
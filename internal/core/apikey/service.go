@@ -0,0 +1,161 @@
+package apikey
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"ospab-panel/pkg/auth"
+)
+
+// MaxKeysPerUser — ограничение числа ключей одного пользователя
+const MaxKeysPerUser = 50
+
+// lastUsedPrecision — last_used_at обновляется не чаще раза в минуту, а не на каждый запрос
+const lastUsedPrecision = time.Minute
+
+var (
+	ErrInvalidKey   = errors.New("invalid_api_key")
+	ErrInvalidScope = errors.New("invalid_scope")
+	ErrInvalidName  = errors.New("invalid_name")
+	ErrExpiryPast   = errors.New("expiry_in_past")
+	ErrTooManyKeys  = errors.New("too_many_keys")
+)
+
+// Service — персональные API-ключи для скриптов и CI
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+func validName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 100 {
+		return "", ErrInvalidName
+	}
+	return name, nil
+}
+
+// Create выпускает ключ; открытое значение возвращается один раз
+func (s *Service) Create(userID int, req *CreateRequest) (*CreateResponse, error) {
+	name, err := validName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !req.Scope.Valid() {
+		return nil, ErrInvalidScope
+	}
+	now := time.Now()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return nil, ErrExpiryPast
+	}
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM api_keys WHERE user_id=?`, userID).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count >= MaxKeysPerUser {
+		return nil, ErrTooManyKeys
+	}
+	token, _, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	key := Prefix + token
+	k := &Key{UserID: userID, Name: name, Prefix: key[:len(Prefix)+6], Scope: req.Scope, CreatedAt: now, ExpiresAt: req.ExpiresAt}
+	res, err := s.db.Exec(`INSERT INTO api_keys (user_id,name,prefix,key_hash,scope,created_at,expires_at) VALUES (?,?,?,?,?,?,?)`,
+		userID, k.Name, k.Prefix, auth.HashToken(key), string(k.Scope), now, k.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	k.ID = int(id)
+	return &CreateResponse{Key: key, APIKey: k}, nil
+}
+
+// List — ключи пользователя, включая истёкшие
+func (s *Service) List(userID int) ([]*Key, error) {
+	rows, err := s.db.Query(`SELECT id,user_id,name,prefix,scope,created_at,last_used_at,expires_at FROM api_keys WHERE user_id=? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*Key{}
+	for rows.Next() {
+		var k Key
+		var used, expires sql.NullTime
+		if err := rows.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.Scope, &k.CreatedAt, &used, &expires); err != nil {
+			return nil, err
+		}
+		if used.Valid {
+			k.LastUsedAt = &used.Time
+		}
+		if expires.Valid {
+			k.ExpiresAt = &expires.Time
+		}
+		list = append(list, &k)
+	}
+	return list, rows.Err()
+}
+
+// Rename меняет подпись ключа (sql.ErrNoRows — ключа нет)
+func (s *Service) Rename(userID, id int, name string) error {
+	name, err := validName(name)
+	if err != nil {
+		return err
+	}
+	// RowsAffected в MySQL равен 0 и при неизменном имени, поэтому наличие проверяется отдельно
+	var exists int
+	if err := s.db.QueryRow(`SELECT 1 FROM api_keys WHERE id=? AND user_id=?`, id, userID).Scan(&exists); err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`UPDATE api_keys SET name=? WHERE id=? AND user_id=?`, name, id, userID)
+	return err
+}
+
+// Revoke удаляет ключ пользователя (sql.ErrNoRows — ключа нет)
+func (s *Service) Revoke(userID, id int) error {
+	res, err := s.db.Exec(`DELETE FROM api_keys WHERE id=? AND user_id=?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Authenticate находит действующий ключ и его владельца. Роль читается из users
+// при каждом запросе: её изменение сразу действует и на ключи.
+func (s *Service) Authenticate(key string) (*Principal, error) {
+	if !strings.HasPrefix(key, Prefix) {
+		return nil, ErrInvalidKey
+	}
+	var p Principal
+	var expires, used sql.NullTime
+	err := s.db.QueryRow(`SELECT k.id,k.scope,k.expires_at,k.last_used_at,u.id,u.username,u.role
+		FROM api_keys k JOIN users u ON u.id=k.user_id WHERE k.key_hash=? AND u.disabled=FALSE`, auth.HashToken(key)).
+		Scan(&p.KeyID, &p.Scope, &expires, &used, &p.UserID, &p.Username, &p.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if expires.Valid && !now.Before(expires.Time) {
+		return nil, ErrInvalidKey
+	}
+	if !used.Valid || now.Sub(used.Time) >= lastUsedPrecision {
+		if _, err := s.db.Exec(`UPDATE api_keys SET last_used_at=? WHERE id=?`, now, p.KeyID); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
@@ -0,0 +1,235 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Цепочка: каждая запись хранит хэш предыдущей (prev_hash) и собственный хэш —
+// SHA-256 от её полей вместе с prev_hash. Первая запись цепочки ссылается на
+// genesisHash. Номера записей идут подряд, поэтому удаление из середины видно по
+// пропуску, правка — по несовпадению хэша. Голова цепочки (audit_chain) и подписанные
+// контрольные точки (audit_checkpoints) выдают удаление последних записей.
+
+var ErrNoSigner = errors.New("audit_signer_not_configured")
+
+var genesisHash = strings.Repeat("0", 64)
+
+const (
+	chainVersion = "v1"
+	chainTime    = "2006-01-02T15:04:05.000000Z"
+	verifyBatch  = 1000
+)
+
+// entryHash — хэш записи в том виде, в каком она хранится в БД
+func entryHash(e *Entry) string {
+	b, _ := json.Marshal([]interface{}{
+		chainVersion, e.ID, e.CreatedAt.UTC().Format(chainTime), e.ActorID, e.ActorName, e.ImpersonatorID, e.APIKeyID,
+		e.IP, e.UserAgent, e.Action, e.Method, e.Path, e.ServerID, e.InstanceID, e.Target, string(e.Params), e.Result,
+		e.Status, e.Error, e.DurationMS, e.PrevHash,
+	})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// checkpointMessage — подписываемые данные контрольной точки
+func checkpointMessage(c *Checkpoint) []byte {
+	return []byte(fmt.Sprintf("ospab-audit-checkpoint/%s\n%d\n%s\n%s", chainVersion, c.EntryID, c.EntryHash,
+		c.CreatedAt.UTC().Format(chainTime)))
+}
+
+// appendEntry добавляет запись в конец цепочки. Голова цепочки блокируется до конца
+// транзакции, так что записи разных реплик выстраиваются по очереди.
+func (s *Service) appendEntry(e *Entry, params interface{}) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var lastID int64
+	var lastHash string
+	err = tx.QueryRow("SELECT last_id, last_hash FROM audit_chain WHERE id = 1 FOR UPDATE").Scan(&lastID, &lastHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Цепочка начинается после записей, сделанных до её включения
+		if _, err := tx.Exec("INSERT IGNORE INTO audit_chain (id, last_id, last_hash) SELECT 1, COALESCE(MAX(id), 0), ? FROM audit_log", genesisHash); err != nil {
+			return err
+		}
+		err = tx.QueryRow("SELECT last_id, last_hash FROM audit_chain WHERE id = 1 FOR UPDATE").Scan(&lastID, &lastHash)
+	}
+	if err != nil {
+		return err
+	}
+
+	e.ID, e.PrevHash = lastID+1, lastHash
+	e.Hash = entryHash(e)
+	_, err = tx.Exec(`INSERT INTO audit_log (id, created_at, actor_id, actor_name, impersonator_id, api_key_id, ip, user_agent,
+		action, method, path, server_id, instance_id, target, params, result, status, error, duration_ms, prev_hash, hash)
+		VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		e.ID, e.CreatedAt, nullInt(e.ActorID), e.ActorName, nullInt(e.ImpersonatorID), nullInt(e.APIKeyID), e.IP, e.UserAgent,
+		e.Action, e.Method, e.Path, nullInt(e.ServerID), e.InstanceID, e.Target, params, e.Result, e.Status, e.Error,
+		e.DurationMS, e.PrevHash, e.Hash)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE audit_chain SET last_id = ?, last_hash = ? WHERE id = 1", e.ID, e.Hash); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Checkpoint подписывает последнюю запись цепочки. Если после предыдущей точки
+// записей не было, возвращает nil.
+func (s *Service) Checkpoint() (*Checkpoint, error) {
+	if s.signer == nil {
+		return nil, ErrNoSigner
+	}
+	c := &Checkpoint{CreatedAt: time.Now().UTC().Truncate(time.Microsecond), Algorithm: s.signer.Algorithm(), KeyID: s.signer.KeyID()}
+	err := s.db.QueryRow("SELECT id, hash FROM audit_log WHERE hash <> '' ORDER BY id DESC LIMIT 1").Scan(&c.EntryID, &c.EntryHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var last sql.NullInt64
+	if err := s.db.QueryRow("SELECT MAX(entry_id) FROM audit_checkpoints").Scan(&last); err != nil {
+		return nil, err
+	}
+	if last.Valid && last.Int64 >= c.EntryID {
+		return nil, nil
+	}
+	c.Signature = base64.StdEncoding.EncodeToString(s.signer.Sign(checkpointMessage(c)))
+	res, err := s.db.Exec(`INSERT INTO audit_checkpoints (created_at, entry_id, entry_hash, algorithm, key_id, signature)
+		VALUES (?,?,?,?,?,?)`, c.CreatedAt, c.EntryID, c.EntryHash, c.Algorithm, c.KeyID, c.Signature)
+	if err != nil {
+		return nil, err
+	}
+	c.ID, _ = res.LastInsertId()
+	return c, nil
+}
+
+// Verify проходит журнал от первой записи до последней и сообщает о первом
+// нарушении цепочки или контрольных точек.
+func (s *Service) Verify() (*Report, error) {
+	// Голова и точки читаются до обхода: всё, что они подтверждают, уже записано
+	var headID int64
+	var headHash string
+	err := s.db.QueryRow("SELECT last_id, last_hash FROM audit_chain WHERE id = 1").Scan(&headID, &headHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	checkpoints, err := s.checkpoints()
+	if err != nil {
+		return nil, err
+	}
+	return verifyChain(headID, headHash, checkpoints, s.signer, func(afterID int64) ([]*Entry, error) {
+		var batch []*Entry
+		err := s.each("SELECT "+entryColumns+" FROM audit_log WHERE id > ? ORDER BY id LIMIT ?", []interface{}{afterID, verifyBatch},
+			func(e *Entry) error {
+				batch = append(batch, e)
+				return nil
+			})
+		return batch, err
+	})
+}
+
+// verifyChain — проверка без БД: next возвращает до verifyBatch записей с id > afterID
+// по возрастанию id. signer может быть nil — тогда подписи точек не проверяются.
+func verifyChain(headID int64, headHash string, checkpoints []*Checkpoint, signer Signer, next func(afterID int64) ([]*Entry, error)) (*Report, error) {
+	report := &Report{}
+	pending := map[int64][]*Checkpoint{}
+	for _, c := range checkpoints {
+		pending[c.EntryID] = append(pending[c.EntryID], c)
+	}
+
+	fail := func(entryID, checkpointID int64, reason string) (*Report, error) {
+		report.Broken = &Break{EntryID: entryID, CheckpointID: checkpointID, Reason: reason}
+		return report, nil
+	}
+
+	var afterID int64
+	chained := false
+	for {
+		batch, err := next(afterID)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range batch {
+			switch {
+			case e.Hash == "" && !chained:
+				report.Unchained++
+			case e.Hash == "":
+				return fail(e.ID, 0, BreakUnchainedEntry)
+			case chained && e.ID != report.LastID+1:
+				return fail(e.ID, 0, BreakMissingEntries)
+			case !chained && e.PrevHash != genesisHash, chained && e.PrevHash != report.LastHash:
+				return fail(e.ID, 0, BreakPrevHashMismatch)
+			case entryHash(e) != e.Hash:
+				return fail(e.ID, 0, BreakHashMismatch)
+			default:
+				chained = true
+				report.Entries++
+				report.LastHash = e.Hash
+			}
+			report.LastID = e.ID
+			if e.ID == headID && e.Hash != headHash {
+				return fail(e.ID, 0, BreakHashMismatch)
+			}
+			for _, c := range pending[e.ID] {
+				if c.EntryHash != e.Hash {
+					return fail(e.ID, c.ID, BreakCheckpointMismatch)
+				}
+				if signer == nil || c.Algorithm != signer.Algorithm() || c.KeyID != signer.KeyID() {
+					report.UnverifiedCheckpoints++
+					continue
+				}
+				sig, err := base64.StdEncoding.DecodeString(c.Signature)
+				if err != nil || !signer.Verify(checkpointMessage(c), sig) {
+					return fail(e.ID, c.ID, BreakBadSignature)
+				}
+				report.Checkpoints++
+			}
+			delete(pending, e.ID)
+		}
+		if len(batch) < verifyBatch {
+			break
+		}
+		afterID = batch[len(batch)-1].ID
+	}
+
+	if headID > report.LastID && headHash != genesisHash {
+		return fail(headID, 0, BreakTruncated)
+	}
+	for _, c := range checkpoints {
+		if _, missing := pending[c.EntryID]; missing {
+			return fail(c.EntryID, c.ID, BreakTruncated)
+		}
+	}
+	report.OK = true
+	return report, nil
+}
+
+func (s *Service) checkpoints() ([]*Checkpoint, error) {
+	rows, err := s.db.Query("SELECT id, created_at, entry_id, entry_hash, algorithm, key_id, signature FROM audit_checkpoints ORDER BY entry_id, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*Checkpoint
+	for rows.Next() {
+		c := &Checkpoint{}
+		if err := rows.Scan(&c.ID, &c.CreatedAt, &c.EntryID, &c.EntryHash, &c.Algorithm, &c.KeyID, &c.Signature); err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
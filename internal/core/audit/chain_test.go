@@ -0,0 +1,199 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func testSigner(t *testing.T, seed byte) Signer {
+	t.Helper()
+	s, err := ParseSigner("ed25519:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{seed}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// testChain — цепочка так, как её записал бы appendEntry, после unchained записей без хэша
+func testChain(unchained, n int) []*Entry {
+	var list []*Entry
+	prev := genesisHash
+	base := time.Date(2025, 10, 29, 12, 0, 0, 123456000, time.FixedZone("MSK", 3*3600))
+	for i := 1; i <= unchained+n; i++ {
+		e := &Entry{ID: int64(i), CreatedAt: base.Add(time.Duration(i) * time.Second), ActorID: i % 3, ActorName: "admin",
+			IP: "10.0.0.1", Action: "server.update", Method: "PUT", Path: "/api/servers/1", ServerID: 1,
+			Params: json.RawMessage(`{"name":"srv"}`), Result: ResultSuccess, Status: 200, DurationMS: int64(i)}
+		if i > unchained {
+			e.PrevHash = prev
+			e.Hash = entryHash(e)
+			prev = e.Hash
+		}
+		list = append(list, e)
+	}
+	return list
+}
+
+func checkpoint(t *testing.T, signer Signer, id int64, e *Entry) *Checkpoint {
+	t.Helper()
+	c := &Checkpoint{ID: id, CreatedAt: e.CreatedAt.Add(time.Minute), EntryID: e.ID, EntryHash: e.Hash,
+		Algorithm: signer.Algorithm(), KeyID: signer.KeyID()}
+	c.Signature = base64.StdEncoding.EncodeToString(signer.Sign(checkpointMessage(c)))
+	return c
+}
+
+// rows отдаёт записи порциями, как SELECT ... WHERE id > ? ORDER BY id LIMIT verifyBatch
+func rows(list []*Entry) func(int64) ([]*Entry, error) {
+	return func(afterID int64) ([]*Entry, error) {
+		var batch []*Entry
+		for _, e := range list {
+			if e.ID > afterID && len(batch) < verifyBatch {
+				batch = append(batch, e)
+			}
+		}
+		return batch, nil
+	}
+}
+
+func TestEntryHash(t *testing.T) {
+	e := testChain(0, 1)[0]
+	moved := *e
+	moved.CreatedAt = e.CreatedAt.UTC()
+	if entryHash(&moved) != e.Hash {
+		t.Fatal("hash depends on the time zone of created_at")
+	}
+	for name, change := range map[string]func(*Entry){
+		"actor":     func(e *Entry) { e.ActorID++ },
+		"params":    func(e *Entry) { e.Params = json.RawMessage(`{"name":"other"}`) },
+		"status":    func(e *Entry) { e.Status = 500 },
+		"prev_hash": func(e *Entry) { e.PrevHash = e.Hash },
+		"time":      func(e *Entry) { e.CreatedAt = e.CreatedAt.Add(time.Microsecond) },
+	} {
+		c := *e
+		change(&c)
+		if entryHash(&c) == e.Hash {
+			t.Errorf("hash ignores %s", name)
+		}
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	signer := testSigner(t, 1)
+
+	tests := []struct {
+		name   string
+		modify func(list []*Entry, cps []*Checkpoint) ([]*Entry, []*Checkpoint)
+		broken *Break
+	}{
+		{"intact", func(l []*Entry, c []*Checkpoint) ([]*Entry, []*Checkpoint) { return l, c }, nil},
+		{"modified", func(l []*Entry, c []*Checkpoint) ([]*Entry, []*Checkpoint) {
+			l[4].Status = 403
+			return l, c
+		}, &Break{EntryID: 5, Reason: BreakHashMismatch}},
+		{"modified with recomputed hashes", func(l []*Entry, c []*Checkpoint) ([]*Entry, []*Checkpoint) {
+			// Злоумышленник пересчитал хвост цепочки, но подписанная точка на записи 6 осталась
+			l[4].Status = 403
+			for i := 4; i < len(l); i++ {
+				l[i].PrevHash = l[i-1].Hash
+				l[i].Hash = entryHash(l[i])
+			}
+			return l, c
+		}, &Break{EntryID: 6, CheckpointID: 1, Reason: BreakCheckpointMismatch}},
+		{"removed", func(l []*Entry, c []*Checkpoint) ([]*Entry, []*Checkpoint) {
+			return append(l[:3:3], l[4:]...), c
+		}, &Break{EntryID: 5, Reason: BreakMissingEntries}},
+		{"removed and renumbered", func(l []*Entry, c []*Checkpoint) ([]*Entry, []*Checkpoint) {
+			l = append(l[:3:3], l[4:]...)
+			for i := 3; i < len(l); i++ {
+				l[i].ID--
+			}
+			return l, c
+		}, &Break{EntryID: 4, Reason: BreakPrevHashMismatch}},
+		{"reordered", func(l []*Entry, c []*Checkpoint) ([]*Entry, []*Checkpoint) {
+			l[2], l[3] = l[3], l[2]
+			l[2].ID, l[3].ID = l[3].ID, l[2].ID
+			return l, c
+		}, &Break{EntryID: 3, Reason: BreakPrevHashMismatch}},
+		{"tail removed", func(l []*Entry, c []*Checkpoint) ([]*Entry, []*Checkpoint) {
+			return l[:7], c
+		}, &Break{EntryID: 10, Reason: BreakTruncated}},
+		{"unchained entry inside chain", func(l []*Entry, c []*Checkpoint) ([]*Entry, []*Checkpoint) {
+			l[5].Hash, l[5].PrevHash = "", ""
+			return l, c
+		}, &Break{EntryID: 6, Reason: BreakUnchainedEntry}},
+		{"bad signature", func(l []*Entry, c []*Checkpoint) ([]*Entry, []*Checkpoint) {
+			sig, _ := base64.StdEncoding.DecodeString(c[0].Signature)
+			sig[0] ^= 1
+			c[0].Signature = base64.StdEncoding.EncodeToString(sig)
+			return l, c
+		}, &Break{EntryID: 6, CheckpointID: 1, Reason: BreakBadSignature}},
+		{"checkpoint moved to other entry", func(l []*Entry, c []*Checkpoint) ([]*Entry, []*Checkpoint) {
+			c[0].EntryID, c[0].EntryHash = 7, l[6].Hash
+			return l, c
+		}, &Break{EntryID: 7, CheckpointID: 1, Reason: BreakBadSignature}},
+		{"checkpoint signed by other key", func(l []*Entry, c []*Checkpoint) ([]*Entry, []*Checkpoint) {
+			c[0] = checkpoint(t, testSigner(t, 2), 1, l[5])
+			return l, c
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := testChain(2, 8)
+			head := list[len(list)-1]
+			cps := []*Checkpoint{checkpoint(t, signer, 1, list[5])}
+			list, cps = tt.modify(list, cps)
+
+			report, err := verifyChain(head.ID, head.Hash, cps, signer, rows(list))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.broken == nil {
+				if !report.OK || report.Broken != nil {
+					t.Fatalf("report = %+v, broken %+v; want OK", report, report.Broken)
+				}
+				return
+			}
+			if report.OK || report.Broken == nil || *report.Broken != *tt.broken {
+				t.Fatalf("broken = %+v; want %+v", report.Broken, tt.broken)
+			}
+		})
+	}
+}
+
+func TestVerifyChainReport(t *testing.T) {
+	signer := testSigner(t, 1)
+	list := testChain(3, 2*verifyBatch+5)
+	head := list[len(list)-1]
+	cps := []*Checkpoint{
+		checkpoint(t, signer, 1, list[10]),
+		checkpoint(t, signer, 2, list[verifyBatch+10]),
+		checkpoint(t, testSigner(t, 2), 3, head),
+	}
+
+	report, err := verifyChain(head.ID, head.Hash, cps, signer, rows(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Report{OK: true, Entries: 2*verifyBatch + 5, Unchained: 3, LastID: head.ID, LastHash: head.Hash,
+		Checkpoints: 2, UnverifiedCheckpoints: 1}
+	if *report != want {
+		t.Fatalf("report = %+v; want %+v", *report, want)
+	}
+
+	// Без ключа подписи точки не проверяются, но хэши в них сверяются
+	report, err = verifyChain(head.ID, head.Hash, cps, nil, rows(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK || report.Checkpoints != 0 || report.UnverifiedCheckpoints != 3 {
+		t.Fatalf("report without signer = %+v", *report)
+	}
+
+	// Пустой журнал и голова, ещё не созданная appendEntry
+	report, err = verifyChain(0, "", nil, signer, rows(nil))
+	if err != nil || !report.OK {
+		t.Fatalf("empty log: %+v, %v", report, err)
+	}
+}
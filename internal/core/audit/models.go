@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Результат действия
+const (
+	ResultSuccess = "success"
+	ResultDenied  = "denied" // 401/403/429: нет прав, не выполнен вход или действие заблокировано
+	ResultFailure = "failure"
+)
+
+// Entry — запись журнала: кто, откуда, что сделал и чем закончилось
+type Entry struct {
+	ID             int64           `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	ActorID        int             `json:"actor_id,omitempty"`
+	ActorName      string          `json:"actor_name,omitempty"`
+	ImpersonatorID int             `json:"impersonator_id,omitempty"` // администратор, вошедший от имени пользователя
+	APIKeyID       int             `json:"api_key_id,omitempty"`
+	IP             string          `json:"ip"`
+	UserAgent      string          `json:"user_agent,omitempty"`
+	Action         string          `json:"action"`
+	Method         string          `json:"method"`
+	Path           string          `json:"path"`
+	ServerID       int             `json:"server_id,omitempty"`
+	InstanceID     string          `json:"instance_id,omitempty"`
+	Target         string          `json:"target,omitempty"` // прочие объекты: "users/5", "orgs/3"
+	Params         json.RawMessage `json:"params,omitempty"` // тело и query запроса без секретов
+	Result         string          `json:"result"`
+	Status         int             `json:"status"`
+	Error          string          `json:"error,omitempty"`
+	DurationMS     int64           `json:"duration_ms"`
+	PrevHash       string          `json:"prev_hash,omitempty"` // хэш предыдущей записи цепочки
+	Hash           string          `json:"hash,omitempty"`      // пусто у записей, сделанных до включения цепочки
+}
+
+// Filter — отбор записей; пустые поля не ограничивают. Action с "*" на конце — префикс.
+type Filter struct {
+	UserID   int
+	ServerID int
+	Action   string
+	Result   string
+	From     time.Time
+	To       time.Time
+	Page     int
+	PerPage  int
+}
+
+type Page struct {
+	Items   []*Entry `json:"items"`
+	Total   int      `json:"total"`
+	Page    int      `json:"page"`
+	PerPage int      `json:"per_page"`
+}
+
+// Checkpoint — подписанная контрольная точка: хэш последней записи цепочки на момент
+// создания. Подпись не подделать без ключа, поэтому пересчёт хэшей после правки
+// записей или удаление хвоста журнала обнаруживаются.
+type Checkpoint struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	EntryID   int64     `json:"entry_id"`
+	EntryHash string    `json:"entry_hash"`
+	Algorithm string    `json:"algorithm"`
+	KeyID     string    `json:"key_id"`
+	Signature string    `json:"signature"`
+}
+
+// Причины разрыва цепочки
+const (
+	BreakHashMismatch       = "hash_mismatch"      // запись изменена
+	BreakPrevHashMismatch   = "prev_hash_mismatch" // предыдущая запись удалена или заменена
+	BreakMissingEntries     = "missing_entries"    // пропуск в номерах записей
+	BreakUnchainedEntry     = "unchained_entry"    // запись без хэша после начала цепочки
+	BreakCheckpointMismatch = "checkpoint_mismatch"
+	BreakBadSignature       = "bad_signature"
+	BreakTruncated          = "truncated" // нет записей, известных заголовку цепочки или контрольной точке
+)
+
+// Break — первое найденное нарушение
+type Break struct {
+	EntryID      int64  `json:"entry_id"`
+	CheckpointID int64  `json:"checkpoint_id,omitempty"`
+	Reason       string `json:"reason"`
+}
+
+// Report — результат проверки журнала
+type Report struct {
+	OK                    bool   `json:"ok"`
+	Entries               int64  `json:"entries"`   // проверено записей цепочки
+	Unchained             int64  `json:"unchained"` // записи, сделанные до включения цепочки
+	LastID                int64  `json:"last_id"`
+	LastHash              string `json:"last_hash,omitempty"`
+	Checkpoints           int    `json:"checkpoints"`            // подпись проверена
+	UnverifiedCheckpoints int    `json:"unverified_checkpoints"` // подписаны другим ключом или ключ не задан
+	Broken                *Break `json:"broken,omitempty"`
+}
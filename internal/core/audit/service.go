@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxPerPage = 200
+
+// MaxExport — предел записей в одной выгрузке
+const MaxExport = 100000
+
+// Service — журнал аудита. Записи только добавляются: методов изменения и удаления
+// нет, а триггеры БД запрещают UPDATE и DELETE в audit_log. Записи связаны в цепочку
+// хэшей (см. chain.go); signer подписывает контрольные точки, nil — без подписи.
+type Service struct {
+	db     *sql.DB
+	signer Signer
+}
+
+func NewService(db *sql.DB, signer Signer) *Service {
+	return &Service{db: db, signer: signer}
+}
+
+const entryColumns = `id, created_at, actor_id, actor_name, impersonator_id, api_key_id, ip, user_agent, action,
+	method, path, server_id, instance_id, target, params, result, status, error, duration_ms, prev_hash, hash`
+
+// Record добавляет запись в конец цепочки; CreatedAt по умолчанию — текущее время.
+// Поля приводятся к виду, в котором хранятся, чтобы хэш совпал при проверке.
+func (s *Service) Record(e *Entry) error {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	e.CreatedAt = e.CreatedAt.Truncate(time.Microsecond)
+	e.ActorName, e.IP, e.UserAgent = truncate(e.ActorName, 64), truncate(e.IP, 64), truncate(e.UserAgent, 255)
+	e.Action, e.Method, e.Path = truncate(e.Action, 96), truncate(e.Method, 8), truncate(e.Path, 255)
+	e.InstanceID, e.Target = truncate(e.InstanceID, 128), truncate(e.Target, 128)
+	e.Result, e.Error = truncate(e.Result, 16), truncate(e.Error, 255)
+	var params interface{}
+	if len(e.Params) > 0 {
+		e.Params = []byte(strings.ToValidUTF8(string(e.Params), "\uFFFD"))
+		params = string(e.Params)
+	} else {
+		e.Params = nil
+	}
+	return s.appendEntry(e, params)
+}
+
+// List — записи по фильтру, новые первыми
+func (s *Service) List(f Filter) (*Page, error) {
+	if f.PerPage <= 0 || f.PerPage > maxPerPage {
+		f.PerPage = 50
+	}
+	if f.Page <= 0 {
+		f.Page = 1
+	}
+	cond, args := f.where()
+	page := &Page{Items: []*Entry{}, Page: f.Page, PerPage: f.PerPage}
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM audit_log WHERE "+cond, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+	err := s.each("SELECT "+entryColumns+" FROM audit_log WHERE "+cond+" ORDER BY id DESC LIMIT ? OFFSET ?",
+		append(args, f.PerPage, (f.Page-1)*f.PerPage), func(e *Entry) error {
+			page.Items = append(page.Items, e)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Export передаёт fn записи по фильтру в хронологическом порядке, не больше MaxExport
+func (s *Service) Export(f Filter, fn func(*Entry) error) error {
+	cond, args := f.where()
+	return s.each("SELECT "+entryColumns+" FROM audit_log WHERE "+cond+" ORDER BY id LIMIT ?", append(args, MaxExport), fn)
+}
+
+func (f Filter) where() (string, []interface{}) {
+	where := []string{"1=1"}
+	var args []interface{}
+	if f.UserID != 0 {
+		// Действия, выполненные от имени пользователя администратором, видны и в его выборке
+		where = append(where, "(actor_id = ? OR impersonator_id = ?)")
+		args = append(args, f.UserID, f.UserID)
+	}
+	if f.ServerID != 0 {
+		where = append(where, "server_id = ?")
+		args = append(args, f.ServerID)
+	}
+	if prefix, ok := strings.CutSuffix(f.Action, "*"); ok {
+		where = append(where, "action LIKE ?")
+		args = append(args, strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)+"%")
+	} else if f.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, f.Action)
+	}
+	if f.Result != "" {
+		where = append(where, "result = ?")
+		args = append(args, f.Result)
+	}
+	if !f.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.From)
+	}
+	if !f.To.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, f.To)
+	}
+	return strings.Join(where, " AND "), args
+}
+
+func (s *Service) each(query string, args []interface{}, fn func(*Entry) error) error {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &Entry{}
+		var actorID, impersonatorID, apiKeyID, serverID sql.NullInt64
+		var params sql.NullString
+		if err := rows.Scan(&e.ID, &e.CreatedAt, &actorID, &e.ActorName, &impersonatorID, &apiKeyID, &e.IP, &e.UserAgent,
+			&e.Action, &e.Method, &e.Path, &serverID, &e.InstanceID, &e.Target, &params, &e.Result, &e.Status, &e.Error,
+			&e.DurationMS, &e.PrevHash, &e.Hash); err != nil {
+			return err
+		}
+		e.ActorID, e.ImpersonatorID, e.APIKeyID, e.ServerID = int(actorID.Int64), int(impersonatorID.Int64), int(apiKeyID.Int64), int(serverID.Int64)
+		if params.Valid {
+			e.Params = []byte(params.String)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func nullInt(v int) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+// truncate обрезает строку до n символов под размер столбца (заодно заменяет
+// некорректный UTF-8, который БД сохранила бы иначе)
+func truncate(s string, n int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("invalid_audit_key")
+
+// Signer подписывает контрольные точки журнала. KeyID отличает ключи друг от друга,
+// чтобы после смены ключа старые точки считались непроверенными, а не поддельными.
+type Signer interface {
+	Algorithm() string
+	KeyID() string
+	Sign(msg []byte) []byte
+	Verify(msg, sig []byte) bool
+}
+
+// ParseSigner разбирает ключ вида "hmac:<base64>" или "ed25519:<base64>"
+// (32 байта seed или 64 байта закрытого ключа). Пустая строка — подписи нет.
+func ParseSigner(spec string) (Signer, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	alg, encoded, ok := strings.Cut(spec, ":")
+	if !ok {
+		return nil, fmt.Errorf("%w: ожидается hmac:<base64> или ed25519:<base64>", ErrInvalidKey)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	switch alg {
+	case "hmac":
+		if len(key) < 32 {
+			return nil, fmt.Errorf("%w: ключ HMAC короче 32 байт", ErrInvalidKey)
+		}
+		return hmacSigner{key: key}, nil
+	case "ed25519":
+		switch len(key) {
+		case ed25519.SeedSize:
+			return ed25519Signer{key: ed25519.NewKeyFromSeed(key)}, nil
+		case ed25519.PrivateKeySize:
+			return ed25519Signer{key: ed25519.PrivateKey(key)}, nil
+		}
+		return nil, fmt.Errorf("%w: ключ Ed25519 — 32 или 64 байта", ErrInvalidKey)
+	}
+	return nil, fmt.Errorf("%w: неизвестный алгоритм %q", ErrInvalidKey, alg)
+}
+
+type hmacSigner struct {
+	key []byte
+}
+
+func (hmacSigner) Algorithm() string { return "hmac-sha256" }
+
+// KeyID — отпечаток ключа; сам ключ из него не восстановить
+func (s hmacSigner) KeyID() string {
+	sum := sha256.Sum256(append([]byte("ospab-audit-key:"), s.key...))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (s hmacSigner) Sign(msg []byte) []byte {
+	m := hmac.New(sha256.New, s.key)
+	m.Write(msg)
+	return m.Sum(nil)
+}
+
+func (s hmacSigner) Verify(msg, sig []byte) bool {
+	return hmac.Equal(s.Sign(msg), sig)
+}
+
+type ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+func (ed25519Signer) Algorithm() string { return "ed25519" }
+
+// KeyID — открытый ключ: по нему точки можно проверить и без закрытого ключа
+func (s ed25519Signer) KeyID() string {
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+func (s ed25519Signer) Sign(msg []byte) []byte {
+	return ed25519.Sign(s.key, msg)
+}
+
+func (s ed25519Signer) Verify(msg, sig []byte) bool {
+	return ed25519.Verify(s.key.Public().(ed25519.PublicKey), msg, sig)
+}
@@ -0,0 +1,231 @@
+package authn
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"ospab-panel/internal/core/rbac"
+	"ospab-panel/internal/core/user"
+)
+
+// LDAPConfig — параметры каталога LDAP / Active Directory
+type LDAPConfig struct {
+	URL                string // ldap://host:389 или ldaps://host:636
+	StartTLS           bool   // перейти на TLS после подключения по ldap://
+	InsecureSkipVerify bool   // не проверять сертификат (только для тестов)
+	// BindDN/BindPassword — служебная учётка для поиска пользователя; пусто — анонимный поиск
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	UserFilter   string // "(uid=%s)"; %s — экранированный логин
+	UsernameAttr string // uid / sAMAccountName — логин нового пользователя панели
+	EmailAttr    string
+	IDAttr       string // неизменяемый идентификатор (entryUUID / objectGUID); пусто — DN
+	GroupAttr    string // memberOf; группы — полный DN и его cn
+	// GroupFilter — поиск групп, если в каталоге нет memberOf: "(member=%s)", %s — DN пользователя
+	GroupFilter string
+	GroupBaseDN string // пусто — BaseDN
+	// RoleMapping — группа каталога → роль панели; порядок задаёт приоритет
+	RoleMapping []rbac.GroupRole
+	// DefaultRole выдаётся записям, ни одна группа которых не сопоставлена роли;
+	// пусто — такие пользователи каталога в панель не входят
+	DefaultRole string
+	Timeout     time.Duration
+}
+
+// LDAP — вход с паролем каталога: поиск записи пользователя и bind от её имени.
+// Пользователь панели создаётся при первом входе, роль синхронизируется с группами.
+type LDAP struct {
+	cfg   LDAPConfig
+	users user.External
+	rbac  rbac.Roles
+}
+
+func NewLDAP(cfg LDAPConfig, users user.External, rbacService rbac.Roles) (*LDAP, error) {
+	if cfg.URL == "" || cfg.BaseDN == "" {
+		return nil, errors.New("LDAP URL and base DN are required")
+	}
+	if !strings.Contains(cfg.UserFilter, "%s") {
+		return nil, errors.New("LDAP user filter must contain %s")
+	}
+	if cfg.GroupFilter != "" && !strings.Contains(cfg.GroupFilter, "%s") {
+		return nil, errors.New("LDAP group filter must contain %s")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &LDAP{cfg: cfg, users: users, rbac: rbacService}, nil
+}
+
+func (p *LDAP) Name() string { return "ldap" }
+
+// issuer — ключ привязки в user_identities: каталог определяется base DN, а не адресом сервера
+func (p *LDAP) issuer() string { return "ldap:" + strings.ToLower(p.cfg.BaseDN) }
+
+func (p *LDAP) dial() (*ldap.Conn, error) {
+	u, err := url.Parse(p.cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	tc := &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: p.cfg.InsecureSkipVerify}
+	conn, err := ldap.DialURL(p.cfg.URL, ldap.DialWithDialer(&net.Dialer{Timeout: p.cfg.Timeout}), ldap.DialWithTLSConfig(tc))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(p.cfg.Timeout)
+	if p.cfg.StartTLS {
+		if err := conn.StartTLS(tc); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (p *LDAP) serviceBind(conn *ldap.Conn) error {
+	if p.cfg.BindDN == "" {
+		return nil
+	}
+	return conn.Bind(p.cfg.BindDN, p.cfg.BindPassword)
+}
+
+func (p *LDAP) Authenticate(_ context.Context, username, password string) (*user.User, error) {
+	// Пустой пароль — анонимный bind, который сервер примет как успешный
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+	conn, err := p.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := p.serviceBind(conn); err != nil {
+		return nil, fmt.Errorf("service bind: %w", err)
+	}
+
+	attrs := []string{"dn", p.cfg.UsernameAttr, p.cfg.EmailAttr}
+	if p.cfg.IDAttr != "" {
+		attrs = append(attrs, p.cfg.IDAttr)
+	}
+	if p.cfg.GroupAttr != "" {
+		attrs = append(attrs, p.cfg.GroupAttr)
+	}
+	res, err := conn.Search(ldap.NewSearchRequest(p.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(p.cfg.Timeout/time.Second), false,
+		fmt.Sprintf(p.cfg.UserFilter, ldap.EscapeFilter(username)), attrs, nil))
+	if err != nil {
+		return nil, fmt.Errorf("user search: %w", err)
+	}
+	switch len(res.Entries) {
+	case 0:
+		return nil, ErrInvalidCredentials
+	case 1:
+	default:
+		return nil, fmt.Errorf("user filter matched several entries for %q", username)
+	}
+	entry := res.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	groups, err := p.groups(conn, entry)
+	if err != nil {
+		return nil, err
+	}
+	role, ok := rbac.RoleForGroups(p.cfg.RoleMapping, groups, p.cfg.DefaultRole)
+	if !ok {
+		return nil, ErrNoRole
+	}
+	if !p.rbac.Exists(role) {
+		return nil, fmt.Errorf("role %q from LDAP mapping does not exist", role)
+	}
+
+	subject := entry.DN
+	if p.cfg.IDAttr != "" {
+		if strings.EqualFold(p.cfg.IDAttr, "objectGUID") {
+			if raw := entry.GetEqualFoldRawAttributeValue(p.cfg.IDAttr); len(raw) > 0 {
+				subject = hex.EncodeToString(raw)
+			}
+		} else if v := entry.GetEqualFoldAttributeValue(p.cfg.IDAttr); v != "" {
+			subject = v
+		}
+	}
+	email := strings.ToLower(entry.GetEqualFoldAttributeValue(p.cfg.EmailAttr))
+
+	u, err := p.users.IdentityUser(p.issuer(), subject, email)
+	switch {
+	case err == nil:
+		if len(p.cfg.RoleMapping) > 0 && u.Role != role {
+			return p.users.SetRole(u.ID, role)
+		}
+		return u, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+	// Первый вход: существующие локальные учётки не привязываются (совпадение логина или
+	// email ещё не значит, что это тот же человек), создаётся новый пользователь
+	if email == "" {
+		return nil, fmt.Errorf("LDAP entry %s has no %s attribute", entry.DN, p.cfg.EmailAttr)
+	}
+	login := entry.GetEqualFoldAttributeValue(p.cfg.UsernameAttr)
+	if login == "" {
+		login = username
+	}
+	if u, err = p.users.CreateExternalUser(login, email, role); err != nil {
+		return nil, err
+	}
+	// Адрес из каталога заполняет администратор
+	if err := p.users.MarkEmailVerified(u.ID); err != nil {
+		return nil, err
+	}
+	if err := p.users.LinkIdentity(u.ID, p.issuer(), subject, email); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// groups — группы пользователя: DN и cn из memberOf и/или поиска по GroupFilter
+func (p *LDAP) groups(conn *ldap.Conn, entry *ldap.Entry) ([]string, error) {
+	var dns []string
+	if p.cfg.GroupAttr != "" {
+		dns = append(dns, entry.GetEqualFoldAttributeValues(p.cfg.GroupAttr)...)
+	}
+	if p.cfg.GroupFilter != "" {
+		// Поиск групп от имени служебной учётки: у пользователя может не быть прав на чтение
+		if err := p.serviceBind(conn); err != nil {
+			return nil, fmt.Errorf("service bind: %w", err)
+		}
+		base := p.cfg.GroupBaseDN
+		if base == "" {
+			base = p.cfg.BaseDN
+		}
+		res, err := conn.Search(ldap.NewSearchRequest(base, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(p.cfg.Timeout/time.Second), false,
+			fmt.Sprintf(p.cfg.GroupFilter, ldap.EscapeFilter(entry.DN)), []string{"dn"}, nil))
+		if err != nil {
+			return nil, fmt.Errorf("group search: %w", err)
+		}
+		for _, g := range res.Entries {
+			dns = append(dns, g.DN)
+		}
+	}
+	out := make([]string, 0, 2*len(dns))
+	for _, dn := range dns {
+		out = append(out, dn)
+		if parsed, err := ldap.ParseDN(dn); err == nil && len(parsed.RDNs) > 0 && len(parsed.RDNs[0].Attributes) > 0 {
+			out = append(out, parsed.RDNs[0].Attributes[0].Value)
+		}
+	}
+	return out, nil
+}
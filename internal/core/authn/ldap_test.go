@@ -0,0 +1,402 @@
+package authn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+
+	"ospab-panel/internal/core/rbac"
+	"ospab-panel/internal/core/user"
+	"ospab-panel/internal/core/user/usertest"
+)
+
+const (
+	testBaseDN  = "dc=example,dc=org"
+	testSvcDN   = "cn=panel,ou=services,dc=example,dc=org"
+	testSvcPass = "svc-pass"
+)
+
+// testEntry — запись каталога; password — для bind от её имени
+type testEntry struct {
+	dn       string
+	password string
+	attrs    map[string][]string
+}
+
+// testDirectory — LDAP-сервер в объёме, который использует провайдер: simple bind и
+// поиск по фильтру равенства. Прочие фильтры (подстроки, присутствие, |, &) совпадают
+// со всеми записями — так незаэкранированный логин выдаст себя.
+type testDirectory struct {
+	t  *testing.T
+	ln net.Listener
+
+	mu       sync.Mutex
+	entries  []*testEntry
+	filters  []string
+	searches map[string]string // фильтр → DN, от имени которого выполнен поиск
+}
+
+func newTestDirectory(t *testing.T, entries ...*testEntry) *testDirectory {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &testDirectory{t: t, ln: ln, entries: entries, searches: map[string]string{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+	return d
+}
+
+func (d *testDirectory) url() string { return "ldap://" + d.ln.Addr().String() }
+
+func (d *testDirectory) entry(dn string) *testEntry {
+	for _, e := range d.entries {
+		if strings.EqualFold(e.dn, dn) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (d *testDirectory) serve(conn net.Conn) {
+	defer conn.Close()
+	bound := ""
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, password := op.Children[1].Data.String(), op.Children[2].Data.String()
+			d.mu.Lock()
+			e := d.entry(dn)
+			d.mu.Unlock()
+			if dn != "" && (e == nil || e.password == "" || e.password != password) {
+				d.reply(conn, id, ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials)
+				continue
+			}
+			bound = dn
+			d.reply(conn, id, ldap.ApplicationBindResponse, ldap.LDAPResultSuccess)
+		case ldap.ApplicationSearchRequest:
+			d.search(conn, id, op, bound)
+		case ldap.ApplicationUnbindRequest:
+			return
+		default:
+			d.t.Errorf("unexpected LDAP operation %d", op.Tag)
+			return
+		}
+	}
+}
+
+func (d *testDirectory) search(conn net.Conn, id int64, op *ber.Packet, bound string) {
+	base, filter := op.Children[0].Data.String(), op.Children[6]
+	text, err := ldap.DecompileFilter(filter)
+	if err != nil {
+		d.t.Errorf("bad filter: %v", err)
+	}
+	d.mu.Lock()
+	d.filters = append(d.filters, text)
+	d.searches[text] = bound
+	var found []*testEntry
+	for _, e := range d.entries {
+		if !strings.HasSuffix(strings.ToLower(e.dn), strings.ToLower(base)) {
+			continue
+		}
+		if filter.Tag != ldap.FilterEqualityMatch {
+			found = append(found, e)
+			continue
+		}
+		attr, value := filter.Children[0].Data.String(), filter.Children[1].Data.String()
+		for name, values := range e.attrs {
+			if strings.EqualFold(name, attr) {
+				for _, v := range values {
+					if strings.EqualFold(v, value) {
+						found = append(found, e)
+					}
+				}
+			}
+		}
+	}
+	d.mu.Unlock()
+
+	for _, e := range found {
+		res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+		res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "DN"))
+		attrs := ber.NewSequence("Attributes")
+		for name, values := range e.attrs {
+			attr := ber.NewSequence("Attribute")
+			attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+			set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+			for _, v := range values {
+				set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+			}
+			attr.AppendChild(set)
+			attrs.AppendChild(attr)
+		}
+		res.AppendChild(attrs)
+		d.write(conn, id, res)
+	}
+	d.reply(conn, id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)
+}
+
+func (d *testDirectory) reply(conn net.Conn, id int64, tag ber.Tag, code uint16) {
+	res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	res.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	d.write(conn, id, res)
+}
+
+func (d *testDirectory) write(conn net.Conn, id int64, op *ber.Packet) {
+	msg := ber.NewSequence("LDAP Response")
+	msg.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "Message ID"))
+	msg.AppendChild(op)
+	if _, err := conn.Write(msg.Bytes()); err != nil {
+		d.t.Errorf("write LDAP response: %v", err)
+	}
+}
+
+func (d *testDirectory) lastFilter() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.filters) == 0 {
+		return ""
+	}
+	return d.filters[len(d.filters)-1]
+}
+
+// staticProvider — второй провайдер цепочки с одним пользователем
+type staticProvider struct {
+	username, password string
+	calls              int
+}
+
+func (p *staticProvider) Name() string { return "static" }
+
+func (p *staticProvider) Authenticate(_ context.Context, username, password string) (*user.User, error) {
+	p.calls++
+	if username != p.username || password != p.password {
+		return nil, ErrInvalidCredentials
+	}
+	return &user.User{ID: 100, Username: username, Role: rbac.RoleAdmin}, nil
+}
+
+func testEntries() []*testEntry {
+	return []*testEntry{
+		{dn: testSvcDN, password: testSvcPass},
+		{dn: "uid=alice,ou=people,dc=example,dc=org", password: "alice-pass", attrs: map[string][]string{
+			"uid": {"alice"}, "mail": {"Alice@Example.org"}, "entryUUID": {"6f1c3a2e-0001"},
+			"memberOf": {"cn=staff,ou=groups,dc=example,dc=org", "cn=ops,ou=groups,dc=example,dc=org"},
+		}},
+		{dn: "uid=bob,ou=people,dc=example,dc=org", password: "bob-pass", attrs: map[string][]string{
+			"uid": {"bob"}, "mail": {"bob@example.org"}, "entryUUID": {"6f1c3a2e-0002"},
+		}},
+		{dn: "cn=Admins,ou=groups,dc=example,dc=org", attrs: map[string][]string{
+			"cn": {"Admins"}, "member": {"uid=bob,ou=people,dc=example,dc=org"},
+		}},
+	}
+}
+
+func testConfig(d *testDirectory) LDAPConfig {
+	return LDAPConfig{
+		URL:          d.url(),
+		BindDN:       testSvcDN,
+		BindPassword: testSvcPass,
+		BaseDN:       testBaseDN,
+		UserFilter:   "(uid=%s)",
+		UsernameAttr: "uid",
+		EmailAttr:    "mail",
+		IDAttr:       "entryUUID",
+		GroupAttr:    "memberOf",
+		RoleMapping:  []rbac.GroupRole{{Group: "admins", Role: rbac.RoleAdmin}, {Group: "ops", Role: rbac.RoleOperator}},
+		Timeout:      5 * time.Second,
+	}
+}
+
+func newTestLDAP(t *testing.T, cfg LDAPConfig, users user.External) *LDAP {
+	t.Helper()
+	p, err := NewLDAP(cfg, users, usertest.Roles{rbac.RoleAdmin: true, rbac.RoleOperator: true, rbac.RoleViewer: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLDAPFirstLogin(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDirectory(t, testEntries()...)
+	users := usertest.NewUsers()
+	p := newTestLDAP(t, testConfig(d), users)
+
+	u, err := p.Authenticate(ctx, "alice", "alice-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Username != "alice" || u.Email != "alice@example.org" || u.Role != rbac.RoleOperator || !u.EmailVerified {
+		t.Fatalf("created user = %+v", *u)
+	}
+	if users.Identities["ldap:"+testBaseDN+"|6f1c3a2e-0001"] != u.ID {
+		t.Fatalf("identity not linked by entryUUID: %v", users.Identities)
+	}
+
+	// Группы изменились в каталоге: при следующем входе роль синхронизируется
+	d.mu.Lock()
+	d.entry("uid=alice,ou=people,dc=example,dc=org").attrs["memberOf"] = []string{"cn=Admins,ou=groups,dc=example,dc=org"}
+	d.mu.Unlock()
+	again, err := p.Authenticate(ctx, "alice", "alice-pass")
+	if err != nil || again.ID != u.ID || again.Role != rbac.RoleAdmin || len(users.ByID) != 1 {
+		t.Fatalf("second login = %+v, %v", again, err)
+	}
+
+	// Без подходящей группы и роли по умолчанию вход запрещён
+	d.mu.Lock()
+	d.entry("uid=alice,ou=people,dc=example,dc=org").attrs["memberOf"] = []string{"cn=staff,ou=groups,dc=example,dc=org"}
+	d.mu.Unlock()
+	if _, err := p.Authenticate(ctx, "alice", "alice-pass"); !errors.Is(err, ErrNoRole) {
+		t.Fatalf("no matching group: %v; want ErrNoRole", err)
+	}
+	if users.ByID[u.ID].Role != rbac.RoleAdmin {
+		t.Fatal("role changed on rejected login")
+	}
+}
+
+func TestLDAPGroupFilter(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDirectory(t, testEntries()...)
+	cfg := testConfig(d)
+	cfg.GroupAttr, cfg.GroupFilter = "", "(member=%s)"
+	cfg.DefaultRole = rbac.RoleViewer
+	users := usertest.NewUsers()
+	p := newTestLDAP(t, cfg, users)
+
+	bob, err := p.Authenticate(ctx, "bob", "bob-pass")
+	if err != nil || bob.Role != rbac.RoleAdmin {
+		t.Fatalf("bob = %+v, %v; want admin from group search", bob, err)
+	}
+	// Группы ищет служебная учётка, а не пользователь
+	if by := d.searches["(member=uid=bob,ou=people,dc=example,dc=org)"]; by != testSvcDN {
+		t.Fatalf("group search bound as %q; want service account", by)
+	}
+	alice, err := p.Authenticate(ctx, "alice", "alice-pass")
+	if err != nil || alice.Role != rbac.RoleViewer {
+		t.Fatalf("alice = %+v, %v; want default role", alice, err)
+	}
+}
+
+func TestLDAPFilterEscaping(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDirectory(t, testEntries()...)
+	users := usertest.NewUsers()
+	p := newTestLDAP(t, testConfig(d), users)
+
+	tests := []struct {
+		username string
+		filter   string
+	}{
+		{"*", `(uid=\2a)`},
+		{"ali*", `(uid=ali\2a)`},
+		{"alice)(uid=*", `(uid=alice\29\28uid=\2a)`},
+		{"*)(|(uid=*", `(uid=\2a\29\28|\28uid=\2a)`},
+		{`bob\`, `(uid=bob\5c)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.username, func(t *testing.T) {
+			// Пароль записи alice: при инъекции фильтр нашёл бы её и bind прошёл
+			_, err := p.Authenticate(ctx, tt.username, "alice-pass")
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("Authenticate = %v; want ErrInvalidCredentials", err)
+			}
+			if got := d.lastFilter(); got != tt.filter {
+				t.Fatalf("search filter = %s; want %s", got, tt.filter)
+			}
+		})
+	}
+	if len(users.ByID) != 0 {
+		t.Fatalf("users created: %v", users.ByID)
+	}
+}
+
+func TestLDAPChain(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDirectory(t, testEntries()...)
+
+	tests := []struct {
+		name      string
+		cfg       func(*LDAPConfig)
+		username  string
+		password  string
+		wantID    int
+		want      error
+		fallback  bool // пароль проверял следующий провайдер
+		directory bool // вход выполнен каталогом
+	}{
+		{name: "directory password", username: "alice", password: "alice-pass", directory: true},
+		{name: "wrong password falls through", username: "alice", password: "local-pass", wantID: 100, fallback: true},
+		{name: "unknown user falls through", username: "carol", password: "local-pass", wantID: 100, fallback: true},
+		{name: "empty password", username: "alice", password: "", want: ErrInvalidCredentials, fallback: true},
+		{name: "rejected by all", username: "alice", password: "nope", want: ErrInvalidCredentials, fallback: true},
+		{name: "service bind failure falls through", cfg: func(c *LDAPConfig) { c.BindPassword = "wrong" },
+			username: "alice", password: "local-pass", wantID: 100, fallback: true},
+		{name: "directory unavailable falls through", cfg: func(c *LDAPConfig) { c.URL = "ldap://127.0.0.1:1" },
+			username: "alice", password: "local-pass", wantID: 100, fallback: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(d)
+			if tt.cfg != nil {
+				tt.cfg(&cfg)
+			}
+			cfg.Timeout = time.Second
+			local := &staticProvider{username: "alice", password: "local-pass"}
+			if tt.username == "carol" {
+				local.username = "carol"
+			}
+			chain := Chain{newTestLDAP(t, cfg, usertest.NewUsers()), local}
+
+			u, err := chain.Authenticate(ctx, tt.username, tt.password)
+			if tt.want != nil {
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("Authenticate = %+v, %v; want %v", u, err, tt.want)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if tt.fallback != (local.calls > 0) {
+				t.Fatalf("next provider called %d times; fallback %v", local.calls, tt.fallback)
+			}
+			if tt.directory && (u == nil || u.Email != "alice@example.org") {
+				t.Fatalf("user = %+v; want directory user", u)
+			}
+			if tt.wantID != 0 && (u == nil || u.ID != tt.wantID) {
+				t.Fatalf("user = %+v; want id %d", u, tt.wantID)
+			}
+		})
+	}
+
+	// Сбой каталога не маскируется под неверный пароль, если никто не принял вход
+	cfg := testConfig(d)
+	cfg.BindPassword = "wrong"
+	_, err := Chain{newTestLDAP(t, cfg, usertest.NewUsers())}.Authenticate(ctx, "alice", "alice-pass")
+	if err == nil || errors.Is(err, ErrInvalidCredentials) || !ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		t.Fatalf("service bind failure = %v; want LDAP error", err)
+	}
+}
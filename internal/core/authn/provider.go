@@ -0,0 +1,74 @@
+package authn
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"ospab-panel/internal/core/user"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid_credentials")
+	ErrNoRole             = errors.New("no_role_for_groups")
+)
+
+// Provider проверяет логин и пароль (первый фактор входа).
+// ErrInvalidCredentials — пользователь неизвестен провайдеру или пароль неверен.
+type Provider interface {
+	Name() string
+	Authenticate(ctx context.Context, username, password string) (*user.User, error)
+}
+
+// Chain опрашивает провайдеры по порядку, первый успешный побеждает.
+// Недоступность одного провайдера не мешает остальным (например, локальному администратору).
+type Chain []Provider
+
+func (c Chain) Name() string { return "chain" }
+
+func (c Chain) Authenticate(ctx context.Context, username, password string) (*user.User, error) {
+	var failure error
+	for _, p := range c {
+		u, err := p.Authenticate(ctx, username, password)
+		if err == nil {
+			return u, nil
+		}
+		if errors.Is(err, ErrInvalidCredentials) {
+			continue
+		}
+		log.Printf("%s authentication of %q failed: %v", p.Name(), username, err)
+		if failure == nil {
+			failure = err
+		}
+	}
+	if failure != nil {
+		return nil, failure
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// Local — пароль из таблицы users (bcrypt)
+type Local struct {
+	users *user.Service
+}
+
+func NewLocal(users *user.Service) *Local {
+	return &Local{users: users}
+}
+
+func (l *Local) Name() string { return "local" }
+
+func (l *Local) Authenticate(_ context.Context, username, password string) (*user.User, error) {
+	u, err := l.users.GetUserByUsername(username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !l.users.ValidatePassword(u, password) {
+		return nil, ErrInvalidCredentials
+	}
+	return u, nil
+}
@@ -0,0 +1,96 @@
+package challenge
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"ospab-panel/pkg/auth"
+)
+
+// Виды незавершённых этапов входа
+const (
+	KindMFA              = "mfa"            // пароль проверен, ждём второй фактор
+	KindWebAuthnRegister = "webauthn_reg"   // регистрация ключа WebAuthn
+	KindWebAuthnLogin    = "webauthn_login" // вход по ключу WebAuthn
+	KindOIDCState        = "oidc_state"     // ожидаем возврата от провайдера OIDC
+	KindOIDCResult       = "oidc_result"    // вход через OIDC выполнен, код для обмена на токены
+)
+
+// MaxAttempts — после стольких неверных ответов вызов аннулируется
+const MaxAttempts = 5
+
+var ErrInvalidChallenge = errors.New("challenge is invalid, expired or exhausted")
+
+// Challenge — промежуточное состояние многошагового входа
+type Challenge struct {
+	UserID    int
+	Kind      string
+	Data      string
+	Attempts  int
+	ExpiresAt time.Time
+}
+
+// Service хранит одноразовые вызовы (auth_challenges); в БД только хэш токена
+type Service struct{ db *sql.DB }
+
+func NewService(db *sql.DB) *Service { return &Service{db: db} }
+
+// Issue создаёт вызов и возвращает токен для клиента
+func (s *Service) Issue(kind string, userID int, data string, ttl time.Duration) (string, error) {
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	var uid interface{}
+	if userID != 0 {
+		uid = userID
+	}
+	_, err = s.db.Exec(`INSERT INTO auth_challenges (token_hash,kind,user_id,data,attempts,expires_at,created_at) VALUES (?,?,?,?,0,?,?)`,
+		hash, kind, uid, data, time.Now().Add(ttl), time.Now())
+	return token, err
+}
+
+// Get возвращает действующий вызов без погашения
+func (s *Service) Get(kind, token string) (*Challenge, error) {
+	var c Challenge
+	var uid sql.NullInt64
+	err := s.db.QueryRow(`SELECT kind,user_id,data,attempts,expires_at FROM auth_challenges WHERE token_hash=? AND kind=? AND expires_at > ? AND attempts < ?`,
+		auth.HashToken(token), kind, time.Now(), MaxAttempts).Scan(&c.Kind, &uid, &c.Data, &c.Attempts, &c.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.UserID = int(uid.Int64)
+	return &c, nil
+}
+
+// Consume атомарно погашает вызов; повторно использовать токен нельзя
+func (s *Service) Consume(kind, token string) (*Challenge, error) {
+	c, err := s.Get(kind, token)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.db.Exec(`DELETE FROM auth_challenges WHERE token_hash=? AND kind=?`, auth.HashToken(token), kind)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return nil, ErrInvalidChallenge
+	}
+	return c, nil
+}
+
+// Fail учитывает неверный ответ на вызов
+func (s *Service) Fail(kind, token string) error {
+	_, err := s.db.Exec(`UPDATE auth_challenges SET attempts=attempts+1 WHERE token_hash=? AND kind=?`, auth.HashToken(token), kind)
+	return err
+}
+
+// PurgeExpired удаляет истёкшие и исчерпанные вызовы
+func (s *Service) PurgeExpired() error {
+	_, err := s.db.Exec(`DELETE FROM auth_challenges WHERE expires_at <= ? OR attempts >= ?`, time.Now(), MaxAttempts)
+	return err
+}
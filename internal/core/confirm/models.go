@@ -0,0 +1,17 @@
+package confirm
+
+import "time"
+
+// Target — инстанс, для которого запрошено подтверждение
+type Target struct {
+	ServerID     int
+	InstanceType string
+	InstanceID   string
+}
+
+// Confirmation — выданный токен подтверждения (сам токен возвращается только один раз)
+type Confirmation struct {
+	Token     string    `json:"confirm_token"`
+	Action    string    `json:"action"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,58 @@
+package confirm
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"ospab-panel/pkg/auth"
+)
+
+// TTL токена подтверждения
+const TTL = 5 * time.Minute
+
+var ErrInvalidToken = errors.New("confirmation token is invalid, expired or already used")
+
+// Service выдаёт и погашает одноразовые токены подтверждения разрушительных действий.
+// Токен привязан к пользователю, инстансу и действию; в БД хранится только хэш.
+type Service struct{ db *sql.DB }
+
+func NewService(db *sql.DB) *Service { return &Service{db: db} }
+
+func (s *Service) Issue(userID int, t Target, action string) (*Confirmation, error) {
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(TTL)
+	_, err = s.db.Exec(`INSERT INTO action_confirmations (token_hash,user_id,server_id,instance_type,instance_id,action,expires_at,created_at) VALUES (?,?,?,?,?,?,?,NOW())`,
+		hash, userID, t.ServerID, t.InstanceType, t.InstanceID, action, expires)
+	if err != nil {
+		return nil, err
+	}
+	return &Confirmation{Token: token, Action: action, ExpiresAt: expires}, nil
+}
+
+// Consume атомарно погашает токен; повторное использование невозможно
+func (s *Service) Consume(userID int, t Target, action, token string) error {
+	if token == "" {
+		return ErrInvalidToken
+	}
+	now := time.Now()
+	res, err := s.db.Exec(`UPDATE action_confirmations SET used_at=?
+		WHERE token_hash=? AND user_id=? AND server_id=? AND instance_type=? AND instance_id=? AND action=? AND used_at IS NULL AND expires_at > ?`,
+		now, auth.HashToken(token), userID, t.ServerID, t.InstanceType, t.InstanceID, action, now)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// PurgeExpired удаляет истёкшие и погашенные токены
+func (s *Service) PurgeExpired() error {
+	_, err := s.db.Exec(`DELETE FROM action_confirmations WHERE expires_at <= ? OR used_at IS NOT NULL`, time.Now())
+	return err
+}
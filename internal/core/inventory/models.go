@@ -0,0 +1,49 @@
+package inventory
+
+import "time"
+
+// Instance — закэшированная запись о VM/LXC, полученная фоновой синхронизацией
+type Instance struct {
+	ServerID        int        `json:"server_id"`
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	Type            string     `json:"type"` // vm или lxc
+	Status          string     `json:"status"`
+	CPU             int        `json:"cpu"`
+	RAM             int        `json:"ram"`
+	Disk            int        `json:"disk"`
+	OS              string     `json:"os"`
+	Node            string     `json:"node"`
+	Tags            []string   `json:"tags"`
+	Protected       bool       `json:"protected"` // защита от удаления, выставленная через панель
+	FirstSeenAt     time.Time  `json:"first_seen_at"`
+	LastSeenAt      time.Time  `json:"last_seen_at"`
+	StatusChangedAt time.Time  `json:"status_changed_at"`
+	DisappearedAt   *time.Time `json:"disappeared_at,omitempty"`
+}
+
+// Event — изменение в инвентаре (появление, смена статуса, исчезновение)
+type Event struct {
+	ID         int64     `json:"id"`
+	ServerID   int       `json:"server_id"`
+	InstanceID string    `json:"instance_id"`
+	Type       string    `json:"type"`
+	Event      string    `json:"event"`
+	OldStatus  string    `json:"old_status,omitempty"`
+	NewStatus  string    `json:"new_status,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+const (
+	EventAppeared      = "appeared"
+	EventStatusChanged = "status_changed"
+	EventDisappeared   = "disappeared"
+)
+
+// SyncState — результат последней синхронизации сервера
+type SyncState struct {
+	ServerID      int        `json:"server_id"`
+	LastAttemptAt time.Time  `json:"last_attempt_at"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+}
@@ -0,0 +1,195 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ospab-panel/internal/core/server"
+	"ospab-panel/internal/hypervisor"
+)
+
+// ServerInstance — инстанс с указанием сервера, на котором он работает
+type ServerInstance struct {
+	ServerID   int    `json:"server_id"`
+	ServerName string `json:"server_name"`
+	*hypervisor.Instance
+}
+
+// ServerFailure — сервер, который не ответил при агрегации
+type ServerFailure struct {
+	ServerID   int    `json:"server_id"`
+	ServerName string `json:"server_name"`
+	Error      string `json:"error"`
+}
+
+// Query — фильтры, сортировка и пагинация глобального списка инстансов
+type Query struct {
+	Name    string // подстрока имени или точный ID, без учёта регистра
+	Status  string
+	Type    string
+	Node    string
+	Tag     string
+	Sort    string // name, id, status, type, node, server, cpu, ram, disk; "-" в начале — по убыванию
+	Page    int
+	PerPage int
+}
+
+// SearchResult — страница результатов и список недоступных серверов
+type SearchResult struct {
+	Items   []*ServerInstance `json:"items"`
+	Total   int               `json:"total"`
+	Page    int               `json:"page"`
+	PerPage int               `json:"per_page"`
+	Failed  []ServerFailure   `json:"failed"`
+}
+
+// Collect параллельно опрашивает серверы, ограничивая время ответа каждого perServer.
+// Ошибки отдельных серверов не прерывают сбор, а возвращаются списком.
+func (s *Syncer) Collect(ctx context.Context, servers []*server.Server, perServer time.Duration) ([]*ServerInstance, []ServerFailure) {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		items  []*ServerInstance
+		failed = []ServerFailure{}
+		sem    = make(chan struct{}, 8)
+	)
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *server.Server) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			sctx, cancel := context.WithTimeout(ctx, perServer)
+			defer cancel()
+			live, err := s.fetch(sctx, srv)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if sctx.Err() == context.DeadlineExceeded {
+					err = fmt.Errorf("timeout after %s", perServer)
+				}
+				failed = append(failed, ServerFailure{ServerID: srv.ID, ServerName: srv.Name, Error: err.Error()})
+				return
+			}
+			for _, in := range live {
+				items = append(items, &ServerInstance{ServerID: srv.ID, ServerName: srv.Name, Instance: in})
+			}
+		}(srv)
+	}
+	wg.Wait()
+	// Детерминированный порядок до пользовательской сортировки
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].ServerID != items[j].ServerID {
+			return items[i].ServerID < items[j].ServerID
+		}
+		return lessID(items[i].ID, items[j].ID)
+	})
+	sort.Slice(failed, func(i, j int) bool { return failed[i].ServerID < failed[j].ServerID })
+	return items, failed
+}
+
+// Search применяет фильтры, сортировку и пагинацию к собранному списку
+func Search(items []*ServerInstance, q Query) *SearchResult {
+	filtered := make([]*ServerInstance, 0, len(items))
+	for _, it := range items {
+		if q.matches(it) {
+			filtered = append(filtered, it)
+		}
+	}
+	sortInstances(filtered, q.Sort)
+
+	if q.PerPage <= 0 {
+		q.PerPage = 50
+	}
+	if q.PerPage > 500 {
+		q.PerPage = 500
+	}
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	start := (q.Page - 1) * q.PerPage
+	if start > len(filtered) {
+		start = len(filtered)
+	}
+	end := start + q.PerPage
+	if end > len(filtered) {
+		end = len(filtered)
+	}
+	return &SearchResult{Items: filtered[start:end], Total: len(filtered), Page: q.Page, PerPage: q.PerPage}
+}
+
+func (q Query) matches(it *ServerInstance) bool {
+	if q.Name != "" {
+		name := strings.ToLower(q.Name)
+		if !strings.Contains(strings.ToLower(it.Name), name) && it.ID != q.Name {
+			return false
+		}
+	}
+	if q.Status != "" && !strings.EqualFold(it.Status, q.Status) {
+		return false
+	}
+	if q.Type != "" && !strings.EqualFold(it.Type, q.Type) {
+		return false
+	}
+	if q.Node != "" && !strings.EqualFold(it.Node, q.Node) {
+		return false
+	}
+	if q.Tag != "" {
+		found := false
+		for _, t := range it.Tags {
+			if strings.EqualFold(t, q.Tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func sortInstances(list []*ServerInstance, by string) {
+	desc := strings.HasPrefix(by, "-")
+	by = strings.TrimPrefix(by, "-")
+	less := func(a, b *ServerInstance) bool {
+		switch by {
+		case "id":
+			return lessID(a.ID, b.ID)
+		case "status":
+			return a.Status < b.Status
+		case "type":
+			return a.Type < b.Type
+		case "node":
+			return a.Node < b.Node
+		case "server":
+			return a.ServerName < b.ServerName
+		case "cpu":
+			return a.CPU < b.CPU
+		case "ram":
+			return a.RAM < b.RAM
+		case "disk":
+			return a.Disk < b.Disk
+		default:
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if desc {
+			return less(list[j], list[i])
+		}
+		return less(list[i], list[j])
+	})
+}
+
+// lessID сравнивает числовые VMID как числа, остальные — как строки
+func lessID(a, b string) bool {
+	if len(a) != len(b) && strings.Trim(a+b, "0123456789") == "" {
+		return len(a) < len(b)
+	}
+	return a < b
+}
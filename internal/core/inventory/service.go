@@ -0,0 +1,217 @@
+package inventory
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"ospab-panel/internal/hypervisor"
+)
+
+type Service struct{ db *sql.DB }
+
+func NewService(db *sql.DB) *Service { return &Service{db: db} }
+
+const instanceColumns = `server_id,instance_id,name,type,status,cpu,ram,disk,os,node,tags,protected,first_seen_at,last_seen_at,status_changed_at,disappeared_at`
+
+func scanInstance(sc interface{ Scan(...any) error }) (*Instance, error) {
+	var in Instance
+	var gone sql.NullTime
+	var tags string
+	if err := sc.Scan(&in.ServerID, &in.ID, &in.Name, &in.Type, &in.Status, &in.CPU, &in.RAM, &in.Disk, &in.OS, &in.Node, &tags, &in.Protected, &in.FirstSeenAt, &in.LastSeenAt, &in.StatusChangedAt, &gone); err != nil {
+		return nil, err
+	}
+	in.Tags = []string{}
+	if tags != "" {
+		in.Tags = strings.Split(tags, ";")
+	}
+	if gone.Valid {
+		in.DisappearedAt = &gone.Time
+	}
+	return &in, nil
+}
+
+// ListByServer возвращает закэшированные инстансы сервера.
+// Исчезнувшие инстансы включаются только при includeGone.
+func (s *Service) ListByServer(serverID int, includeGone bool) ([]*Instance, error) {
+	query := `SELECT ` + instanceColumns + ` FROM instances WHERE server_id=?`
+	if !includeGone {
+		query += ` AND disappeared_at IS NULL`
+	}
+	query += ` ORDER BY type, CAST(instance_id AS UNSIGNED), instance_id`
+	rows, err := s.db.Query(query, serverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*Instance{}
+	for rows.Next() {
+		in, err := scanInstance(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, in)
+	}
+	return list, rows.Err()
+}
+
+// SetProtected выставляет флаг защиты. Если инстанса ещё нет в кэше, возвращает sql.ErrNoRows.
+func (s *Service) SetProtected(serverID int, instanceType, instanceID string, protected bool) error {
+	res, err := s.db.Exec(`UPDATE instances SET protected=? WHERE server_id=? AND type=? AND instance_id=?`, protected, serverID, instanceType, instanceID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var exists bool
+		// RowsAffected=0 также при неизменном значении — проверяем наличие строки
+		if err := s.db.QueryRow(`SELECT 1 FROM instances WHERE server_id=? AND type=? AND instance_id=?`, serverID, instanceType, instanceID).Scan(&exists); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsProtected сообщает, защищён ли инстанс флагом панели (отсутствие в кэше — не защищён)
+func (s *Service) IsProtected(serverID int, instanceType, instanceID string) (bool, error) {
+	var protected bool
+	err := s.db.QueryRow(`SELECT protected FROM instances WHERE server_id=? AND type=? AND instance_id=?`, serverID, instanceType, instanceID).Scan(&protected)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return protected, err
+}
+
+// Events возвращает последние события по инстансу (новые сверху)
+func (s *Service) Events(serverID int, instanceType, instanceID string, limit int) ([]*Event, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	rows, err := s.db.Query(`SELECT id,server_id,instance_id,type,event,old_status,new_status,created_at FROM instance_events WHERE server_id=? AND type=? AND instance_id=? ORDER BY id DESC LIMIT ?`, serverID, instanceType, instanceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*Event{}
+	for rows.Next() {
+		var ev Event
+		if err := rows.Scan(&ev.ID, &ev.ServerID, &ev.InstanceID, &ev.Type, &ev.Event, &ev.OldStatus, &ev.NewStatus, &ev.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, &ev)
+	}
+	return list, rows.Err()
+}
+
+// GetSyncState возвращает состояние последней синхронизации; sql.ErrNoRows если сервер ещё не синхронизировался
+func (s *Service) GetSyncState(serverID int) (*SyncState, error) {
+	var st SyncState
+	var success sql.NullTime
+	var lastErr sql.NullString
+	err := s.db.QueryRow(`SELECT server_id,last_attempt_at,last_success_at,last_error FROM inventory_sync WHERE server_id=?`, serverID).Scan(&st.ServerID, &st.LastAttemptAt, &success, &lastErr)
+	if err != nil {
+		return nil, err
+	}
+	if success.Valid {
+		st.LastSuccessAt = &success.Time
+	}
+	st.LastError = lastErr.String
+	return &st, nil
+}
+
+// RecordSyncError фиксирует неудачную попытку синхронизации, кэш при этом не трогается
+func (s *Service) RecordSyncError(serverID int, at time.Time, syncErr error) error {
+	_, err := s.db.Exec(`INSERT INTO inventory_sync (server_id,last_attempt_at,last_error) VALUES (?,?,?)
+		ON DUPLICATE KEY UPDATE last_attempt_at=VALUES(last_attempt_at), last_error=VALUES(last_error)`, serverID, at, syncErr.Error())
+	return err
+}
+
+type instanceKey struct{ typ, id string }
+
+// Sync сверяет живой список инстансов с кэшем: добавляет новые, обновляет
+// last_seen/статус и помечает пропавшие. Все изменения пишутся в instance_events.
+func (s *Service) Sync(serverID int, live []*hypervisor.Instance, at time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT `+instanceColumns+` FROM instances WHERE server_id=? FOR UPDATE`, serverID)
+	if err != nil {
+		return err
+	}
+	known := map[instanceKey]*Instance{}
+	for rows.Next() {
+		in, err := scanInstance(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		known[instanceKey{in.Type, in.ID}] = in
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	seen := map[instanceKey]bool{}
+	for _, li := range live {
+		k := instanceKey{li.Type, li.ID}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		old, ok := known[k]
+		switch {
+		case !ok:
+			if _, err := tx.Exec(`INSERT INTO instances (server_id,instance_id,name,type,status,cpu,ram,disk,os,node,tags,first_seen_at,last_seen_at,status_changed_at) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+				serverID, li.ID, li.Name, li.Type, li.Status, li.CPU, li.RAM, li.Disk, li.OS, li.Node, strings.Join(li.Tags, ";"), at, at, at); err != nil {
+				return err
+			}
+			if err := addEvent(tx, serverID, li, EventAppeared, "", li.Status, at); err != nil {
+				return err
+			}
+		default:
+			statusChanged := old.Status != li.Status
+			if _, err := tx.Exec(`UPDATE instances SET name=?,status=?,cpu=?,ram=?,disk=?,os=?,node=?,tags=?,last_seen_at=?,disappeared_at=NULL,
+				status_changed_at=IF(?, ?, status_changed_at) WHERE server_id=? AND type=? AND instance_id=?`,
+				li.Name, li.Status, li.CPU, li.RAM, li.Disk, li.OS, li.Node, strings.Join(li.Tags, ";"), at, statusChanged, at, serverID, li.Type, li.ID); err != nil {
+				return err
+			}
+			if old.DisappearedAt != nil {
+				if err := addEvent(tx, serverID, li, EventAppeared, old.Status, li.Status, at); err != nil {
+					return err
+				}
+			} else if statusChanged {
+				if err := addEvent(tx, serverID, li, EventStatusChanged, old.Status, li.Status, at); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for k, old := range known {
+		if seen[k] || old.DisappearedAt != nil {
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE instances SET disappeared_at=? WHERE server_id=? AND type=? AND instance_id=?`, at, serverID, k.typ, k.id); err != nil {
+			return err
+		}
+		gone := &hypervisor.Instance{ID: old.ID, Type: old.Type}
+		if err := addEvent(tx, serverID, gone, EventDisappeared, old.Status, "", at); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT INTO inventory_sync (server_id,last_attempt_at,last_success_at,last_error) VALUES (?,?,?,NULL)
+		ON DUPLICATE KEY UPDATE last_attempt_at=VALUES(last_attempt_at), last_success_at=VALUES(last_success_at), last_error=NULL`, serverID, at, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func addEvent(tx *sql.Tx, serverID int, in *hypervisor.Instance, event, oldStatus, newStatus string, at time.Time) error {
+	_, err := tx.Exec(`INSERT INTO instance_events (server_id,instance_id,type,event,old_status,new_status,created_at) VALUES (?,?,?,?,?,?,?)`,
+		serverID, in.ID, in.Type, event, oldStatus, newStatus, at)
+	return err
+}
@@ -0,0 +1,105 @@
+package inventory
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"ospab-panel/internal/core/server"
+	"ospab-panel/internal/hypervisor"
+)
+
+// Syncer периодически опрашивает все активные серверы и обновляет кэш инстансов
+type Syncer struct {
+	inventory   *Service
+	servers     *server.Service
+	hvFactory   *hypervisor.HypervisorFactory
+	interval    time.Duration
+	timeout     time.Duration
+	concurrency int
+}
+
+func NewSyncer(inventory *Service, servers *server.Service, hvFactory *hypervisor.HypervisorFactory, interval time.Duration) *Syncer {
+	return &Syncer{
+		inventory:   inventory,
+		servers:     servers,
+		hvFactory:   hvFactory,
+		interval:    interval,
+		timeout:     30 * time.Second,
+		concurrency: 4,
+	}
+}
+
+// Run выполняет синхронизацию сразу и затем по таймеру до отмены контекста
+func (s *Syncer) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		log.Println("Inventory sync disabled")
+		return
+	}
+	log.Printf("Inventory sync every %s", s.interval)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.SyncAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncAll синхронизирует все активные серверы с ограниченным параллелизмом
+func (s *Syncer) SyncAll(ctx context.Context) {
+	list, err := s.servers.GetActiveServers()
+	if err != nil {
+		log.Printf("inventory: list servers: %v", err)
+		return
+	}
+	sem := make(chan struct{}, s.concurrency)
+	var wg sync.WaitGroup
+	for _, srv := range list {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(srv *server.Server) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if _, err := s.SyncServer(ctx, srv); err != nil {
+				log.Printf("inventory: sync server %d (%s): %v", srv.ID, srv.Name, err)
+			}
+		}(srv)
+	}
+	wg.Wait()
+}
+
+// SyncServer опрашивает один сервер и возвращает обновлённый кэш.
+// При ошибке гипервизора ошибка сохраняется в inventory_sync, а кэш остаётся прежним.
+func (s *Syncer) SyncServer(ctx context.Context, srv *server.Server) ([]*Instance, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	live, err := s.fetch(ctx, srv)
+	now := time.Now()
+	if err != nil {
+		if recErr := s.inventory.RecordSyncError(srv.ID, now, err); recErr != nil {
+			log.Printf("inventory: record sync error for server %d: %v", srv.ID, recErr)
+		}
+		return nil, err
+	}
+	if err := s.inventory.Sync(srv.ID, live, now); err != nil {
+		return nil, err
+	}
+	return s.inventory.ListByServer(srv.ID, false)
+}
+
+func (s *Syncer) fetch(ctx context.Context, srv *server.Server) ([]*hypervisor.Instance, error) {
+	client, err := s.hvFactory.Connect(ctx, &hypervisor.Server{ID: srv.ID, Name: srv.Name, Host: srv.Host, Port: srv.Port, Type: srv.Type, Username: srv.UsernameDecrypted, Password: srv.PasswordDecrypted, UserID: srv.UserID, IsActive: srv.IsActive})
+	if err != nil {
+		return nil, err
+	}
+	defer client.Disconnect()
+	return client.GetInstances(ctx)
+}
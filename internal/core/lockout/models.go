@@ -0,0 +1,46 @@
+package lockout
+
+import "time"
+
+// Config — политика задержек. После FreeAttempts неудач каждая следующая удваивает
+// паузу (BackoffBase, 2×, 4×...), после UserLockAfter/IPLockAfter — блокировка на
+// LockoutDuration. Счётчик сбрасывается, если неудач не было дольше Window.
+type Config struct {
+	FreeAttempts    int
+	UserLockAfter   int
+	IPLockAfter     int
+	BackoffBase     time.Duration
+	LockoutDuration time.Duration
+	Window          time.Duration
+}
+
+var DefaultConfig = Config{
+	FreeAttempts:    3,
+	UserLockAfter:   10,
+	IPLockAfter:     50,
+	BackoffBase:     time.Second,
+	LockoutDuration: 15 * time.Minute,
+	Window:          15 * time.Minute,
+}
+
+// Block — вход временно запрещён
+type Block struct {
+	Kind       string
+	RetryAfter time.Duration
+	Locked     bool // блокировка, а не короткая пауза между попытками
+}
+
+// Entry — счётчик для администратора
+type Entry struct {
+	Kind          string    `json:"kind"`
+	Subject       string    `json:"subject"`
+	Failures      int       `json:"failures"`
+	Locked        bool      `json:"locked"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+	BlockedUntil  time.Time `json:"blocked_until"`
+}
+
+type UnlockRequest struct {
+	Username string `json:"username"`
+	IP       string `json:"ip"`
+}
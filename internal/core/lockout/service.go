@@ -0,0 +1,154 @@
+package lockout
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Виды счётчиков неудачных попыток
+const (
+	KindUser = "user"
+	KindIP   = "ip"
+)
+
+// Service считает неудачные входы по IP и логину. Состояние хранится в БД
+// (login_failures), поэтому ограничения общие для всех реплик панели.
+type Service struct {
+	db  *sql.DB
+	cfg Config
+}
+
+func NewService(db *sql.DB, cfg Config) *Service {
+	return &Service{db: db, cfg: cfg}
+}
+
+func normalizeUsername(username string) string {
+	username = strings.ToLower(strings.TrimSpace(username))
+	if len(username) > 255 {
+		username = username[:255]
+	}
+	return username
+}
+
+// Check — запрещён ли сейчас вход с этого IP или под этим логином (nil — разрешён)
+func (s *Service) Check(ip, username string) (*Block, error) {
+	rows, err := s.db.Query(`SELECT kind,locked,blocked_until FROM login_failures
+		WHERE ((kind=? AND subject=?) OR (kind=? AND subject=?)) AND blocked_until > ?`,
+		KindIP, ip, KindUser, normalizeUsername(username), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var block *Block
+	for rows.Next() {
+		var b Block
+		var until time.Time
+		if err := rows.Scan(&b.Kind, &b.Locked, &until); err != nil {
+			return nil, err
+		}
+		b.RetryAfter = time.Until(until)
+		if block == nil || b.RetryAfter > block.RetryAfter {
+			block = &b
+		}
+	}
+	return block, rows.Err()
+}
+
+// Fail учитывает неудачную попытку входа
+func (s *Service) Fail(ip, username string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := time.Now()
+	if err := s.fail(tx, KindIP, ip, s.cfg.IPLockAfter, now); err != nil {
+		return err
+	}
+	if username = normalizeUsername(username); username != "" {
+		if err := s.fail(tx, KindUser, username, s.cfg.UserLockAfter, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Service) fail(tx *sql.Tx, kind, subject string, lockAfter int, now time.Time) error {
+	// Атомарный инкремент: реплики не теряют попытки друг друга. failures присваивается
+	// первым, поэтому сравнивается ещё прежнее last_failure_at.
+	if _, err := tx.Exec(`INSERT INTO login_failures (kind,subject,failures,locked,last_failure_at,blocked_until) VALUES (?,?,1,FALSE,?,?)
+		ON DUPLICATE KEY UPDATE failures = IF(last_failure_at < ?, 1, failures + 1), last_failure_at = ?`,
+		kind, subject, now, now, now.Add(-s.cfg.Window), now); err != nil {
+		return err
+	}
+	var failures int
+	if err := tx.QueryRow(`SELECT failures FROM login_failures WHERE kind=? AND subject=? FOR UPDATE`, kind, subject).Scan(&failures); err != nil {
+		return err
+	}
+	delay, locked := s.delay(failures, lockAfter)
+	_, err := tx.Exec(`UPDATE login_failures SET locked=?, blocked_until=? WHERE kind=? AND subject=?`, locked, now.Add(delay), kind, subject)
+	return err
+}
+
+// delay — пауза после failures неудач подряд
+func (s *Service) delay(failures, lockAfter int) (time.Duration, bool) {
+	if lockAfter > 0 && failures >= lockAfter {
+		return s.cfg.LockoutDuration, true
+	}
+	n := failures - s.cfg.FreeAttempts
+	if n <= 0 {
+		return 0, false
+	}
+	d := s.cfg.BackoffBase
+	for i := 1; i < n && d < s.cfg.LockoutDuration; i++ {
+		d *= 2
+	}
+	if d > s.cfg.LockoutDuration {
+		d = s.cfg.LockoutDuration
+	}
+	return d, false
+}
+
+// Succeed сбрасывает счётчик логина после успешного входа. Счётчик IP не сбрасывается:
+// иначе перебор с одного адреса можно было бы «разбавлять» входом в свою учётку.
+func (s *Service) Succeed(username string) error {
+	_, err := s.db.Exec(`DELETE FROM login_failures WHERE kind=? AND subject=?`, KindUser, normalizeUsername(username))
+	return err
+}
+
+// Active — действующие паузы и блокировки
+func (s *Service) Active() ([]*Entry, error) {
+	rows, err := s.db.Query(`SELECT kind,subject,failures,locked,last_failure_at,blocked_until FROM login_failures
+		WHERE blocked_until > ? ORDER BY blocked_until DESC LIMIT 500`, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*Entry{}
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.Kind, &e.Subject, &e.Failures, &e.Locked, &e.LastFailureAt, &e.BlockedUntil); err != nil {
+			return nil, err
+		}
+		list = append(list, &e)
+	}
+	return list, rows.Err()
+}
+
+// Unlock снимает блокировку логина и/или IP; возвращает число снятых счётчиков
+func (s *Service) Unlock(username, ip string) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM login_failures WHERE (kind=? AND subject=?) OR (kind=? AND subject=?)`,
+		KindUser, normalizeUsername(username), KindIP, strings.TrimSpace(ip))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// PurgeExpired удаляет счётчики, по которым и блокировка, и окно подсчёта истекли
+func (s *Service) PurgeExpired() error {
+	now := time.Now()
+	_, err := s.db.Exec(`DELETE FROM login_failures WHERE blocked_until < ? AND last_failure_at < ?`, now, now.Add(-s.cfg.Window))
+	return err
+}
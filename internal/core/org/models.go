@@ -0,0 +1,89 @@
+package org
+
+import (
+	"time"
+
+	"ospab-panel/internal/core/rbac"
+)
+
+// Роли участника внутри организации
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	RoleViewer = "viewer"
+)
+
+// Права роли в организации на её серверы. Итоговое право — пересечение
+// с глобальной ролью пользователя (rbac).
+var rolePermissions = map[string][]rbac.Permission{
+	RoleOwner:  {rbac.PermServersRead, rbac.PermServersWrite, rbac.PermInstancesRead, rbac.PermInstancesPower, rbac.PermInstancesSnapshot, rbac.PermInstancesDelete},
+	RoleAdmin:  {rbac.PermServersRead, rbac.PermServersWrite, rbac.PermInstancesRead, rbac.PermInstancesPower, rbac.PermInstancesSnapshot, rbac.PermInstancesDelete},
+	RoleMember: {rbac.PermServersRead, rbac.PermInstancesRead, rbac.PermInstancesPower, rbac.PermInstancesSnapshot},
+	RoleViewer: {rbac.PermServersRead, rbac.PermInstancesRead},
+}
+
+// ValidRole проверяет имя роли в организации
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Allows сообщает, разрешает ли роль в организации право perm
+func Allows(role string, perm rbac.Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// CanManage — может ли роль приглашать и удалять участников
+func CanManage(role string) bool { return role == RoleOwner || role == RoleAdmin }
+
+type Organization struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy int       `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	// Роль текущего пользователя (заполняется при выборке для пользователя)
+	MyRole string `json:"my_role,omitempty"`
+}
+
+type Member struct {
+	UserID   int       `json:"user_id"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type Invite struct {
+	ID        int       `json:"id"`
+	OrgID     int       `json:"organization_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	InvitedBy int       `json:"invited_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	// Токен возвращается только при создании приглашения
+	Token string `json:"token,omitempty"`
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+type InviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type AcceptInviteRequest struct {
+	Token string `json:"token"`
+}
+
+type SetMemberRoleRequest struct {
+	Role string `json:"role"`
+}
@@ -0,0 +1,322 @@
+package org
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"ospab-panel/pkg/auth"
+)
+
+// InviteTTL — срок действия приглашения
+const InviteTTL = 7 * 24 * time.Hour
+
+var (
+	ErrNotFound      = errors.New("organization_not_found")
+	ErrNotMember     = errors.New("not_a_member")
+	ErrForbidden     = errors.New("insufficient_organization_role")
+	ErrInvalidRole   = errors.New("invalid_organization_role")
+	ErrInvalidName   = errors.New("invalid_organization_name")
+	ErrLastOwner     = errors.New("last_owner")
+	ErrHasServers    = errors.New("organization_has_servers")
+	ErrAlreadyMember = errors.New("already_a_member")
+	ErrInvalidInvite = errors.New("invite is invalid, expired or issued for another email")
+	// ErrEmailNotVerified — приглашение по email принимается только с подтверждённым адресом
+	ErrEmailNotVerified = errors.New("email_not_verified")
+)
+
+type Service struct{ db *sql.DB }
+
+func NewService(db *sql.DB) *Service { return &Service{db: db} }
+
+// Create создаёт организацию; создатель становится владельцем
+func (s *Service) Create(name string, userID int) (*Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 128 {
+		return nil, ErrInvalidName
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`INSERT INTO organizations (name,created_by,created_at) VALUES (?,?,NOW())`, name, userID)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+	if _, err := tx.Exec(`INSERT INTO organization_members (org_id,user_id,role,created_at) VALUES (?,?,?,NOW())`, id, userID, RoleOwner); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.Get(int(id), userID)
+}
+
+// ListForUser возвращает организации, в которых состоит пользователь
+func (s *Service) ListForUser(userID int) ([]*Organization, error) {
+	rows, err := s.db.Query(`SELECT o.id,o.name,o.created_by,o.created_at,m.role FROM organizations o
+		JOIN organization_members m ON m.org_id=o.id WHERE m.user_id=? ORDER BY o.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*Organization{}
+	for rows.Next() {
+		var o Organization
+		if err := rows.Scan(&o.ID, &o.Name, &o.CreatedBy, &o.CreatedAt, &o.MyRole); err != nil {
+			return nil, err
+		}
+		list = append(list, &o)
+	}
+	return list, rows.Err()
+}
+
+// Get возвращает организацию, если пользователь в ней состоит
+func (s *Service) Get(orgID, userID int) (*Organization, error) {
+	var o Organization
+	err := s.db.QueryRow(`SELECT o.id,o.name,o.created_by,o.created_at,m.role FROM organizations o
+		JOIN organization_members m ON m.org_id=o.id WHERE o.id=? AND m.user_id=?`, orgID, userID).Scan(&o.ID, &o.Name, &o.CreatedBy, &o.CreatedAt, &o.MyRole)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// MemberRole возвращает роль пользователя в организации (ErrNotMember, если не состоит)
+func (s *Service) MemberRole(orgID, userID int) (string, error) {
+	var role string
+	err := s.db.QueryRow(`SELECT role FROM organization_members WHERE org_id=? AND user_id=?`, orgID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotMember
+	}
+	return role, err
+}
+
+// Delete удаляет организацию; разрешено только владельцу и только без серверов
+func (s *Service) Delete(orgID, userID int) error {
+	role, err := s.MemberRole(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if role != RoleOwner {
+		return ErrForbidden
+	}
+	var servers int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM servers WHERE organization_id=? AND is_active=1`, orgID).Scan(&servers); err != nil {
+		return err
+	}
+	if servers > 0 {
+		return ErrHasServers
+	}
+	_, err = s.db.Exec(`DELETE FROM organizations WHERE id=?`, orgID)
+	return err
+}
+
+func (s *Service) Members(orgID int) ([]*Member, error) {
+	rows, err := s.db.Query(`SELECT u.id,u.username,u.email,m.role,m.created_at FROM organization_members m
+		JOIN users u ON u.id=m.user_id WHERE m.org_id=? ORDER BY u.username`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*Member{}
+	for rows.Next() {
+		var m Member
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Email, &m.Role, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, &m)
+	}
+	return list, rows.Err()
+}
+
+// Invite создаёт приглашение по email. Назначать владельца может только владелец.
+func (s *Service) Invite(orgID, inviterID int, req *InviteRequest) (*Invite, error) {
+	role, err := s.MemberRole(orgID, inviterID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Role == "" {
+		req.Role = RoleMember
+	}
+	if !ValidRole(req.Role) {
+		return nil, ErrInvalidRole
+	}
+	if !CanManage(role) || (req.Role == RoleOwner && role != RoleOwner) {
+		return nil, ErrForbidden
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if !strings.Contains(email, "@") {
+		return nil, ErrInvalidInvite
+	}
+	var exists int
+	err = s.db.QueryRow(`SELECT 1 FROM organization_members m JOIN users u ON u.id=m.user_id WHERE m.org_id=? AND LOWER(u.email)=?`, orgID, email).Scan(&exists)
+	if err == nil {
+		return nil, ErrAlreadyMember
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	inv := &Invite{OrgID: orgID, Email: email, Role: req.Role, InvitedBy: inviterID, ExpiresAt: now.Add(InviteTTL), CreatedAt: now, Token: token}
+	res, err := s.db.Exec(`INSERT INTO organization_invites (org_id,email,role,token_hash,invited_by,expires_at,created_at) VALUES (?,?,?,?,?,?,?)`,
+		orgID, inv.Email, inv.Role, hash, inviterID, inv.ExpiresAt, now)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+	inv.ID = int(id)
+	return inv, nil
+}
+
+// Invites возвращает действующие приглашения организации
+func (s *Service) Invites(orgID int) ([]*Invite, error) {
+	rows, err := s.db.Query(`SELECT id,org_id,email,role,invited_by,expires_at,created_at FROM organization_invites
+		WHERE org_id=? AND accepted_at IS NULL AND expires_at > ? ORDER BY id DESC`, orgID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*Invite{}
+	for rows.Next() {
+		var inv Invite
+		if err := rows.Scan(&inv.ID, &inv.OrgID, &inv.Email, &inv.Role, &inv.InvitedBy, &inv.ExpiresAt, &inv.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, &inv)
+	}
+	return list, rows.Err()
+}
+
+// RevokeInvite отзывает неиспользованное приглашение
+func (s *Service) RevokeInvite(orgID, userID, inviteID int) error {
+	role, err := s.MemberRole(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if !CanManage(role) {
+		return ErrForbidden
+	}
+	res, err := s.db.Exec(`DELETE FROM organization_invites WHERE id=? AND org_id=? AND accepted_at IS NULL`, inviteID, orgID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidInvite
+	}
+	return nil
+}
+
+// AcceptInvite принимает приглашение. Email пользователя должен совпадать с адресом приглашения.
+func (s *Service) AcceptInvite(token string, userID int) (*Organization, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var (
+		id, orgID   int
+		email, role string
+		userEmail   string
+		verified    bool
+	)
+	err = tx.QueryRow(`SELECT id,org_id,email,role FROM organization_invites WHERE token_hash=? AND accepted_at IS NULL AND expires_at > ? FOR UPDATE`,
+		auth.HashToken(token), time.Now()).Scan(&id, &orgID, &email, &role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidInvite
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.QueryRow(`SELECT email,email_verified FROM users WHERE id=?`, userID).Scan(&userEmail, &verified); err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(userEmail, email) {
+		return nil, ErrInvalidInvite
+	}
+	if !verified {
+		return nil, ErrEmailNotVerified
+	}
+	// Уже состоящему участнику роль не понижаем
+	if _, err := tx.Exec(`INSERT IGNORE INTO organization_members (org_id,user_id,role,created_at) VALUES (?,?,?,NOW())`, orgID, userID, role); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE organization_invites SET accepted_at=?, accepted_by=? WHERE id=?`, time.Now(), userID, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.Get(orgID, userID)
+}
+
+// SetMemberRole меняет роль участника. Владельца назначает и снимает только владелец.
+func (s *Service) SetMemberRole(orgID, actorID, userID int, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+	actorRole, err := s.MemberRole(orgID, actorID)
+	if err != nil {
+		return err
+	}
+	current, err := s.MemberRole(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if !CanManage(actorRole) || ((role == RoleOwner || current == RoleOwner) && actorRole != RoleOwner) {
+		return ErrForbidden
+	}
+	if current == RoleOwner && role != RoleOwner {
+		if err := s.ensureAnotherOwner(orgID, userID); err != nil {
+			return err
+		}
+	}
+	_, err = s.db.Exec(`UPDATE organization_members SET role=? WHERE org_id=? AND user_id=?`, role, orgID, userID)
+	return err
+}
+
+// RemoveMember исключает участника; любой участник может выйти сам.
+// Последнего владельца удалить нельзя.
+func (s *Service) RemoveMember(orgID, actorID, userID int) error {
+	current, err := s.MemberRole(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if actorID != userID {
+		actorRole, err := s.MemberRole(orgID, actorID)
+		if err != nil {
+			return err
+		}
+		if !CanManage(actorRole) || (current == RoleOwner && actorRole != RoleOwner) {
+			return ErrForbidden
+		}
+	}
+	if current == RoleOwner {
+		if err := s.ensureAnotherOwner(orgID, userID); err != nil {
+			return err
+		}
+	}
+	_, err = s.db.Exec(`DELETE FROM organization_members WHERE org_id=? AND user_id=?`, orgID, userID)
+	return err
+}
+
+func (s *Service) ensureAnotherOwner(orgID, userID int) error {
+	var owners int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM organization_members WHERE org_id=? AND role=? AND user_id<>?`, orgID, RoleOwner, userID).Scan(&owners); err != nil {
+		return err
+	}
+	if owners == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package passkey
+
+import "time"
+
+// Config — параметры проверяющей стороны (Relying Party)
+type Config struct {
+	RPID    string   // домен панели, например panel.example.com
+	RPName  string   // отображаемое имя
+	Origins []string // допустимые origin фронтенда
+}
+
+// Credential — зарегистрированный ключ FIDO2 или passkey пользователя
+type Credential struct {
+	ID             int        `json:"id"`
+	UserID         int        `json:"-"`
+	Name           string     `json:"name"`
+	BackupEligible bool       `json:"backup_eligible"` // синхронизируемый passkey
+	SignCount      uint32     `json:"sign_count"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Ceremony — параметры для navigator.credentials.create/get и токен для завершения
+type Ceremony struct {
+	ChallengeToken string      `json:"challenge_token"`
+	Options        interface{} `json:"options"`
+}
+
+type BeginLoginRequest struct {
+	Username string `json:"username"` // необязательно: без него — вход по passkey без ввода логина
+}
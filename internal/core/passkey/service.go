@@ -0,0 +1,295 @@
+package passkey
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	mysql "github.com/go-sql-driver/mysql"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+
+	"ospab-panel/internal/core/challenge"
+)
+
+// CeremonyTTL — время на касание ключа
+const CeremonyTTL = 5 * time.Minute
+
+const maxNameLen = 64
+
+var (
+	ErrCeremonyFailed   = errors.New("webauthn_verification_failed")
+	ErrCredentialExists = errors.New("credential_already_registered")
+	// ErrCloned — счётчик подписей не вырос: ключ, вероятно, скопирован
+	ErrCloned = errors.New("credential_sign_count_regressed")
+)
+
+// Service — регистрация ключей WebAuthn и вход по ним.
+// Состояние церемоний хранится в auth_challenges.
+type Service struct {
+	db         *sql.DB
+	wa         *webauthn.WebAuthn
+	challenges *challenge.Service
+}
+
+func NewService(db *sql.DB, challenges *challenge.Service, cfg Config) (*Service, error) {
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPName,
+		RPOrigins:     cfg.Origins,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Service{db: db, wa: wa, challenges: challenges}, nil
+}
+
+// account — пользователь в терминах библиотеки (webauthn.User)
+type account struct {
+	id    int
+	name  string
+	creds []webauthn.Credential
+}
+
+func (a *account) WebAuthnID() []byte                         { return userHandle(a.id) }
+func (a *account) WebAuthnName() string                       { return a.name }
+func (a *account) WebAuthnDisplayName() string                { return a.name }
+func (a *account) WebAuthnCredentials() []webauthn.Credential { return a.creds }
+
+// userHandle — идентификатор пользователя внутри ключа
+func userHandle(id int) []byte { return []byte(strconv.Itoa(id)) }
+
+func (s *Service) account(userID int) (*account, error) {
+	acc := &account{id: userID}
+	if err := s.db.QueryRow(`SELECT username FROM users WHERE id=?`, userID).Scan(&acc.name); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT credential_id,public_key,attestation_type,transports,aaguid,flags,sign_count
+		FROM webauthn_credentials WHERE user_id=?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c webauthn.Credential
+		var transports string
+		var flags uint8
+		if err := rows.Scan(&c.ID, &c.PublicKey, &c.AttestationType, &transports, &c.Authenticator.AAGUID, &flags, &c.Authenticator.SignCount); err != nil {
+			return nil, err
+		}
+		for _, t := range strings.Split(transports, ",") {
+			if t != "" {
+				c.Transport = append(c.Transport, protocol.AuthenticatorTransport(t))
+			}
+		}
+		c.Flags = webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(flags))
+		acc.creds = append(acc.creds, c)
+	}
+	return acc, rows.Err()
+}
+
+// BeginRegistration начинает добавление ключа; уже зарегистрированные ключи исключаются
+func (s *Service) BeginRegistration(userID int) (*Ceremony, error) {
+	acc, err := s.account(userID)
+	if err != nil {
+		return nil, err
+	}
+	creation, sess, err := s.wa.BeginRegistration(acc,
+		webauthn.WithExclusions(webauthn.Credentials(acc.creds).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred))
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.saveSession(challenge.KindWebAuthnRegister, userID, sess)
+	if err != nil {
+		return nil, err
+	}
+	return &Ceremony{ChallengeToken: token, Options: creation}, nil
+}
+
+// FinishRegistration проверяет ответ аутентификатора (тело r) и сохраняет ключ
+func (s *Service) FinishRegistration(userID int, token, name string, r *http.Request) (*Credential, error) {
+	sess, ownerID, err := s.takeSession(challenge.KindWebAuthnRegister, token)
+	if err != nil {
+		return nil, err
+	}
+	if ownerID != userID {
+		return nil, challenge.ErrInvalidChallenge
+	}
+	acc, err := s.account(userID)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := s.wa.FinishRegistration(acc, *sess, r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCeremonyFailed, err)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Ключ безопасности"
+	}
+	if len([]rune(name)) > maxNameLen {
+		name = string([]rune(name)[:maxNameLen])
+	}
+	transports := make([]string, 0, len(cred.Transport))
+	for _, t := range cred.Transport {
+		transports = append(transports, string(t))
+	}
+	now := time.Now()
+	res, err := s.db.Exec(`INSERT INTO webauthn_credentials (user_id,name,credential_id,public_key,attestation_type,transports,aaguid,flags,sign_count,created_at)
+		VALUES (?,?,?,?,?,?,?,?,?,?)`, userID, name, cred.ID, cred.PublicKey, cred.AttestationType, strings.Join(transports, ","),
+		cred.Authenticator.AAGUID, uint8(cred.Flags.ProtocolValue()), cred.Authenticator.SignCount, now)
+	if err != nil {
+		if me, ok := err.(*mysql.MySQLError); ok && me.Number == 1062 {
+			return nil, ErrCredentialExists
+		}
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+	return &Credential{ID: int(id), UserID: userID, Name: name, BackupEligible: cred.Flags.BackupEligible, SignCount: cred.Authenticator.SignCount, CreatedAt: now}, nil
+}
+
+// BeginLogin начинает вход. С логином — по ключам этого пользователя, без логина
+// (или если у пользователя нет ключей, чтобы не раскрывать это) — по passkey.
+func (s *Service) BeginLogin(username string) (*Ceremony, error) {
+	var (
+		assertion *protocol.CredentialAssertion
+		sess      *webauthn.SessionData
+		userID    int
+		err       error
+	)
+	if username = strings.TrimSpace(username); username != "" {
+		var acc *account
+		if err := s.db.QueryRow(`SELECT id FROM users WHERE username=?`, username).Scan(&userID); err == nil {
+			if acc, err = s.account(userID); err != nil {
+				return nil, err
+			}
+		}
+		if acc != nil && len(acc.creds) > 0 {
+			assertion, sess, err = s.wa.BeginLogin(acc, webauthn.WithUserVerification(protocol.VerificationPreferred))
+		} else {
+			userID = 0
+		}
+	}
+	if assertion == nil && err == nil {
+		assertion, sess, err = s.wa.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationPreferred))
+	}
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.saveSession(challenge.KindWebAuthnLogin, userID, sess)
+	if err != nil {
+		return nil, err
+	}
+	return &Ceremony{ChallengeToken: token, Options: assertion}, nil
+}
+
+// FinishLogin проверяет подпись и счётчик ключа. Возвращает пользователя и признак
+// проверки пользователя на ключе (PIN/биометрия) — такой вход равносилен 2FA.
+func (s *Service) FinishLogin(token string, r *http.Request) (userID int, verified bool, err error) {
+	sess, userID, err := s.takeSession(challenge.KindWebAuthnLogin, token)
+	if err != nil {
+		return 0, false, err
+	}
+	var cred *webauthn.Credential
+	if userID == 0 {
+		var u webauthn.User
+		u, cred, err = s.wa.FinishPasskeyLogin(func(_, handle []byte) (webauthn.User, error) {
+			id, err := strconv.Atoi(string(handle))
+			if err != nil {
+				return nil, err
+			}
+			return s.account(id)
+		}, *sess, r)
+		if err == nil {
+			userID = u.(*account).id
+		}
+	} else {
+		var acc *account
+		if acc, err = s.account(userID); err != nil {
+			return 0, false, err
+		}
+		cred, err = s.wa.FinishLogin(acc, *sess, r)
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("%w: %v", ErrCeremonyFailed, err)
+	}
+	if cred.Authenticator.CloneWarning {
+		log.Printf("WebAuthn credential of user %d rejected: sign count %d did not increase", userID, cred.Authenticator.SignCount)
+		return 0, false, ErrCloned
+	}
+	// Условие по счётчику защищает от параллельного входа с тем же ответом
+	res, err := s.db.Exec(`UPDATE webauthn_credentials SET sign_count=?, flags=?, last_used_at=?
+		WHERE user_id=? AND credential_id=? AND (sign_count < ? OR ? = 0)`,
+		cred.Authenticator.SignCount, uint8(cred.Flags.ProtocolValue()), time.Now(), userID, cred.ID, cred.Authenticator.SignCount, cred.Authenticator.SignCount)
+	if err != nil {
+		return 0, false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, false, ErrCloned
+	}
+	return userID, cred.Flags.UserVerified, nil
+}
+
+// List — ключи пользователя
+func (s *Service) List(userID int) ([]*Credential, error) {
+	rows, err := s.db.Query(`SELECT id,user_id,name,flags,sign_count,created_at,last_used_at FROM webauthn_credentials WHERE user_id=? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*Credential{}
+	for rows.Next() {
+		var c Credential
+		var flags uint8
+		var used sql.NullTime
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &flags, &c.SignCount, &c.CreatedAt, &used); err != nil {
+			return nil, err
+		}
+		c.BackupEligible = protocol.AuthenticatorFlags(flags).HasBackupEligible()
+		if used.Valid {
+			c.LastUsedAt = &used.Time
+		}
+		list = append(list, &c)
+	}
+	return list, rows.Err()
+}
+
+// Delete удаляет ключ пользователя (sql.ErrNoRows — ключа нет)
+func (s *Service) Delete(userID, id int) error {
+	res, err := s.db.Exec(`DELETE FROM webauthn_credentials WHERE id=? AND user_id=?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Service) saveSession(kind string, userID int, sess *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return "", err
+	}
+	return s.challenges.Issue(kind, userID, string(data), CeremonyTTL)
+}
+
+// takeSession погашает вызов: ответ на церемонию принимается один раз
+func (s *Service) takeSession(kind, token string) (*webauthn.SessionData, int, error) {
+	c, err := s.challenges.Consume(kind, token)
+	if err != nil {
+		return nil, 0, err
+	}
+	var sess webauthn.SessionData
+	if err := json.Unmarshal([]byte(c.Data), &sess); err != nil {
+		return nil, 0, err
+	}
+	return &sess, c.UserID, nil
+}
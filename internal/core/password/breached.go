@@ -0,0 +1,117 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Встроенный список: SHA-1 самых распространённых паролей, по одному в строке
+//
+//go:embed breached.txt
+var bundledList []byte
+
+// rangePrefixLen — длина префикса SHA-1 в именах файлов диапазонов (как в API Pwned Passwords)
+const rangePrefixLen = 5
+
+// breachedList — проверка по локальной базе утёкших паролей. Пароль не покидает
+// сервер; в памяти и на диске — только SHA-1.
+type breachedList interface {
+	Contains(hash string) (bool, error)
+}
+
+// loadBreached открывает базу по пути:
+//   - каталог — файлы диапазонов <PREFIX> или <PREFIX>.txt (первые 5 hex-символов SHA-1)
+//     со строками SUFFIX[:COUNT], как их выгружает haveibeenpwned-downloader. Проверка
+//     читает один небольшой файл, поэтому подходит для полной базы;
+//   - файл — строки HASH[:COUNT], загружаются в память целиком;
+//   - пустой путь — встроенный список.
+func loadBreached(path string) (breachedList, error) {
+	if path == "" {
+		return parseHashSet(bytes.NewReader(bundledList))
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return rangeDir(path), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseHashSet(f)
+}
+
+type hashSet map[[sha1.Size]byte]struct{}
+
+func parseHashSet(r io.Reader) (hashSet, error) {
+	set := hashSet{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		var key [sha1.Size]byte
+		if len(hash) != 2*sha1.Size {
+			return nil, fmt.Errorf("breached list line %d: not a SHA-1 hash", n)
+		}
+		if _, err := hex.Decode(key[:], []byte(hash)); err != nil {
+			return nil, fmt.Errorf("breached list line %d: %w", n, err)
+		}
+		set[key] = struct{}{}
+	}
+	return set, sc.Err()
+}
+
+func (s hashSet) Contains(hash string) (bool, error) {
+	var key [sha1.Size]byte
+	if _, err := hex.Decode(key[:], []byte(hash)); err != nil {
+		return false, err
+	}
+	_, ok := s[key]
+	return ok, nil
+}
+
+type rangeDir string
+
+func (d rangeDir) Contains(hash string) (bool, error) {
+	prefix, suffix := hash[:rangePrefixLen], hash[rangePrefixLen:]
+	f, err := os.Open(filepath.Join(string(d), prefix+".txt"))
+	if os.IsNotExist(err) {
+		f, err = os.Open(filepath.Join(string(d), prefix))
+	}
+	if os.IsNotExist(err) {
+		// Нет файла диапазона — нет и утёкших паролей с таким префиксом
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		s, _, _ := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if strings.EqualFold(s, suffix) {
+			return true, nil
+		}
+	}
+	return false, sc.Err()
+}
+
+// sha1Hex — SHA-1 пароля в верхнем регистре, как в базах утечек
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,123 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Хэши хранятся в формате PHC, схема определяется по префиксу:
+//   - $argon2id$v=19$m=19456,t=2,p=1$<соль>$<хэш> — текущая схема;
+//   - $bcrypt-salted$<соль>$2a$10$... — bcrypt от "пароль:соль", так пароли хранились,
+//     пока соль лежала в отдельном столбце users.password_salt;
+//   - $2a$10$... — bcrypt без собственной соли.
+//
+// Устаревшие схемы только проверяются: после успешного входа хэш пересчитывается (Verify
+// возвращает rehash).
+const (
+	schemeArgon2id     = "argon2id"
+	schemeBcryptSalted = "bcrypt-salted"
+)
+
+// Argon2Params — параметры argon2id (память в КиБ)
+type Argon2Params struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+	SaltLen int
+	KeyLen  uint32
+}
+
+// DefaultArgon2 — рекомендация OWASP: 19 МиБ, 2 прохода, 1 поток
+var DefaultArgon2 = Argon2Params{Memory: 19 * 1024, Time: 2, Threads: 1, SaltLen: 16, KeyLen: 32}
+
+// Пределы параметров argon2id из хэша: повреждённая запись не должна уронить вход
+// (t=0 или p=0 — паника в x/crypto) или занимать гигабайты памяти на каждую попытку
+const (
+	maxArgon2Memory = 256 * 1024 // 256 МиБ
+	maxArgon2Time   = 16
+)
+
+var ErrUnknownHash = errors.New("unknown_password_hash")
+
+// Hash — хэш пароля по текущей схеме
+func Hash(password string) (string, error) {
+	p := DefaultArgon2
+	salt := make([]byte, p.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", schemeArgon2id, argon2.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify сравнивает пароль с хэшем. rehash — пароль верный, но хэш устаревшей схемы
+// или с другими параметрами и его стоит заменить на Hash(password). Пустой хэш
+// (учётки SSO/LDAP) не совпадает ни с одним паролем.
+func Verify(password, encoded string) (ok, rehash bool, err error) {
+	switch {
+	case encoded == "":
+		return false, false, nil
+	case strings.HasPrefix(encoded, "$"+schemeArgon2id+"$"):
+		return verifyArgon2id(password, encoded)
+	case strings.HasPrefix(encoded, "$"+schemeBcryptSalted+"$"):
+		salt, hash, found := strings.Cut(strings.TrimPrefix(encoded, "$"+schemeBcryptSalted+"$"), "$")
+		if !found {
+			return false, false, ErrUnknownHash
+		}
+		ok, err := verifyBcrypt(password+":"+salt, "$"+hash)
+		return ok, ok, err
+	case strings.HasPrefix(encoded, "$2"):
+		ok, err := verifyBcrypt(password, encoded)
+		return ok, ok, err
+	default:
+		return false, false, ErrUnknownHash
+	}
+}
+
+func verifyBcrypt(password, hash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) || errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func verifyArgon2id(password, encoded string) (ok, rehash bool, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, false, ErrUnknownHash
+	}
+	var version int
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, ErrUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return false, false, ErrUnknownHash
+	}
+	if p.Time < 1 || p.Time > maxArgon2Time || p.Threads < 1 || p.Memory < 8*uint32(p.Threads) || p.Memory > maxArgon2Memory {
+		return false, false, ErrUnknownHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false, ErrUnknownHash
+	}
+	p.SaltLen, p.KeyLen = len(salt), uint32(len(key))
+	actual := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+	if subtle.ConstantTimeCompare(actual, key) != 1 {
+		return false, false, nil
+	}
+	return true, p != DefaultArgon2, nil
+}
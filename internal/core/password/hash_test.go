@@ -0,0 +1,120 @@
+package password
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func bcryptHash(t *testing.T, password string) string {
+	t.Helper()
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(h)
+}
+
+func TestHashRoundTrip(t *testing.T) {
+	encoded, err := Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Fatalf("Hash = %q; want argon2id PHC with default params", encoded)
+	}
+	if other, _ := Hash("correct horse"); other == encoded {
+		t.Fatal("Hash reuses salt")
+	}
+
+	tests := []struct {
+		password string
+		ok       bool
+	}{
+		{"correct horse", true},
+		{"correct horse ", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		ok, rehash, err := Verify(tt.password, encoded)
+		if err != nil || ok != tt.ok || rehash {
+			t.Errorf("Verify(%q) = %v, %v, %v; want %v, false, nil", tt.password, ok, rehash, err, tt.ok)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	// Параметры в строке хэша подменены: ключ считается заново и не совпадает
+	weaker := strings.Replace(mustHash(t, "pw"), "m=19456,t=2", "m=19456,t=1", 1)
+	weakerOK := argon2Hash("pw", Argon2Params{Memory: 19 * 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32})
+
+	tests := []struct {
+		name     string
+		password string
+		encoded  string
+		ok       bool
+		rehash   bool
+		err      error
+	}{
+		{"salted bcrypt", "pw", "$bcrypt-salted$s4lt" + bcryptHash(t, "pw:s4lt"), true, true, nil},
+		{"salted bcrypt wrong password", "px", "$bcrypt-salted$s4lt" + bcryptHash(t, "pw:s4lt"), false, false, nil},
+		{"salted bcrypt wrong salt", "pw", "$bcrypt-salted$other" + bcryptHash(t, "pw:s4lt"), false, false, nil},
+		{"plain bcrypt", "pw", bcryptHash(t, "pw"), true, true, nil},
+		{"argon2id other params", "pw", weakerOK, true, true, nil},
+		{"argon2id tampered params", "pw", weaker, false, false, nil},
+		{"empty hash", "", "", false, false, nil},
+		{"unknown scheme", "pw", "$scrypt$abc", false, false, ErrUnknownHash},
+		{"salted bcrypt without hash", "pw", "$bcrypt-salted$s4lt", false, false, ErrUnknownHash},
+		{"argon2id missing part", "pw", "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA", false, false, ErrUnknownHash},
+		{"argon2id bad version", "pw", "$argon2id$v=16$m=19456,t=2,p=1$c2FsdA$a2V5", false, false, ErrUnknownHash},
+		{"argon2id bad params", "pw", "$argon2id$v=19$m=x,t=2,p=1$c2FsdA$a2V5", false, false, ErrUnknownHash},
+		{"argon2id zero time", "pw", "$argon2id$v=19$m=19456,t=0,p=1$c2FsdA$a2V5", false, false, ErrUnknownHash},
+		{"argon2id zero threads", "pw", "$argon2id$v=19$m=19456,t=2,p=0$c2FsdA$a2V5", false, false, ErrUnknownHash},
+		{"argon2id memory below 8*p", "pw", "$argon2id$v=19$m=8,t=2,p=4$c2FsdA$a2V5", false, false, ErrUnknownHash},
+		{"argon2id huge memory", "pw", "$argon2id$v=19$m=4294967295,t=2,p=1$c2FsdA$a2V5", false, false, ErrUnknownHash},
+		{"argon2id huge time", "pw", "$argon2id$v=19$m=19456,t=1000000,p=1$c2FsdA$a2V5", false, false, ErrUnknownHash},
+		{"argon2id bad salt", "pw", "$argon2id$v=19$m=19456,t=2,p=1$!!$a2V5", false, false, ErrUnknownHash},
+		{"argon2id empty key", "pw", "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$", false, false, ErrUnknownHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash, err := Verify(tt.password, tt.encoded)
+			if ok != tt.ok || rehash != tt.rehash || !errors.Is(err, tt.err) {
+				t.Fatalf("Verify = %v, %v, %v; want %v, %v, %v", ok, rehash, err, tt.ok, tt.rehash, tt.err)
+			}
+		})
+	}
+}
+
+// TestVerifyMigration — вход со старым хэшем даёт rehash, новый хэш проверяется без него
+func TestVerifyMigration(t *testing.T) {
+	legacy := "$bcrypt-salted$s4lt" + bcryptHash(t, "pw:s4lt")
+	if ok, rehash, err := Verify("pw", legacy); !ok || !rehash || err != nil {
+		t.Fatalf("Verify(legacy) = %v, %v, %v", ok, rehash, err)
+	}
+	upgraded := mustHash(t, "pw")
+	if ok, rehash, err := Verify("pw", upgraded); !ok || rehash || err != nil {
+		t.Fatalf("Verify(upgraded) = %v, %v, %v", ok, rehash, err)
+	}
+}
+
+func argon2Hash(password string, p Argon2Params) string {
+	salt := []byte(strings.Repeat("s", p.SaltLen))
+	key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func mustHash(t *testing.T, password string) string {
+	t.Helper()
+	h, err := Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
@@ -0,0 +1,73 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Config — требования к паролям. Классы символов: строчные и заглавные буквы,
+// цифры, прочие символы; MinClasses — сколько разных классов должно встретиться.
+// BreachedPath — файл или каталог с SHA-1 утёкших паролей (см. breached.go);
+// пустой путь — встроенный список самых распространённых паролей.
+type Config struct {
+	MinLength      int
+	MaxLength      int
+	MinClasses     int
+	ForbidUserInfo bool // пароль не должен содержать логин или email
+	BreachedCheck  bool
+	BreachedPath   string
+}
+
+var DefaultConfig = Config{
+	MinLength:      8,
+	MaxLength:      128,
+	MinClasses:     1,
+	ForbidUserInfo: true,
+	BreachedCheck:  true,
+}
+
+var (
+	ErrTooShort   = errors.New("password_too_short")
+	ErrTooLong    = errors.New("password_too_long")
+	ErrTooSimple  = errors.New("password_too_few_character_classes")
+	ErrContainsID = errors.New("password_contains_user_info")
+	ErrBreached   = errors.New("password_breached")
+	ErrEmpty      = errors.New("password_empty")
+)
+
+// Violation — пароль не соответствует политике. Error() возвращает сообщение для
+// пользователя, errors.Is сравнивает по Err.
+type Violation struct {
+	Err   error
+	Limit int // длина или число классов для сообщения
+}
+
+func (v *Violation) Error() string {
+	switch v.Err {
+	case ErrTooShort:
+		return fmt.Sprintf("Пароль слишком короткий (минимум %d символов)", v.Limit)
+	case ErrTooLong:
+		return fmt.Sprintf("Пароль слишком длинный (не более %d символов)", v.Limit)
+	case ErrTooSimple:
+		return fmt.Sprintf("Пароль должен содержать символы хотя бы %d видов из: строчные буквы, заглавные буквы, цифры, прочие символы", v.Limit)
+	case ErrContainsID:
+		return "Пароль не должен содержать логин или email"
+	case ErrBreached:
+		return "Этот пароль встречается в утечках данных, выберите другой"
+	case ErrEmpty:
+		return "Требуется пароль"
+	default:
+		return v.Err.Error()
+	}
+}
+
+func (v *Violation) Unwrap() error { return v.Err }
+
+// Requirements — политика для подсказок в формах (GET /api/auth/password-policy)
+type Requirements struct {
+	MinLength      int  `json:"min_length"`
+	MaxLength      int  `json:"max_length"`
+	MinClasses     int  `json:"min_classes"`
+	ForbidUserInfo bool `json:"forbid_user_info"`
+	BreachedCheck  bool `json:"breached_check"`
+}
@@ -0,0 +1,109 @@
+package password
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// minUserInfoLen — более короткие логины и части email в пароле не ищутся
+const minUserInfoLen = 3
+
+// Policy проверяет новые пароли при регистрации, сбросе и смене
+type Policy struct {
+	cfg      Config
+	breached breachedList
+}
+
+// NewPolicy загружает базу утёкших паролей, если проверка включена
+func NewPolicy(cfg Config) (*Policy, error) {
+	if cfg.MinLength < 1 {
+		cfg.MinLength = 1
+	}
+	if cfg.MaxLength < cfg.MinLength {
+		cfg.MaxLength = DefaultConfig.MaxLength
+	}
+	p := &Policy{cfg: cfg}
+	if cfg.BreachedCheck {
+		list, err := loadBreached(cfg.BreachedPath)
+		if err != nil {
+			return nil, err
+		}
+		p.breached = list
+	}
+	return p, nil
+}
+
+// Requirements — требования для отображения в формах
+func (p *Policy) Requirements() Requirements {
+	return Requirements{
+		MinLength:      p.cfg.MinLength,
+		MaxLength:      p.cfg.MaxLength,
+		MinClasses:     p.cfg.MinClasses,
+		ForbidUserInfo: p.cfg.ForbidUserInfo,
+		BreachedCheck:  p.cfg.BreachedCheck,
+	}
+}
+
+// Check возвращает *Violation, если пароль не подходит; прочие ошибки — сбой чтения базы утечек
+func (p *Policy) Check(password, username, email string) error {
+	n := utf8.RuneCountInString(password)
+	switch {
+	case password == "":
+		return &Violation{Err: ErrEmpty}
+	case n < p.cfg.MinLength:
+		return &Violation{Err: ErrTooShort, Limit: p.cfg.MinLength}
+	case n > p.cfg.MaxLength:
+		return &Violation{Err: ErrTooLong, Limit: p.cfg.MaxLength}
+	case characterClasses(password) < p.cfg.MinClasses:
+		return &Violation{Err: ErrTooSimple, Limit: p.cfg.MinClasses}
+	case p.cfg.ForbidUserInfo && containsUserInfo(password, username, email):
+		return &Violation{Err: ErrContainsID}
+	}
+	if p.breached != nil {
+		found, err := p.breached.Contains(sha1Hex(password))
+		if err != nil {
+			return err
+		}
+		if found {
+			return &Violation{Err: ErrBreached}
+		}
+	}
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	n := 0
+	for _, ok := range []bool{lower, upper, digit, other} {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
+
+// containsUserInfo — пароль содержит логин, email или его локальную часть (без учёта регистра)
+func containsUserInfo(password, username, email string) bool {
+	password = strings.ToLower(password)
+	email = strings.ToLower(strings.TrimSpace(email))
+	local, _, _ := strings.Cut(email, "@")
+	for _, s := range []string{strings.ToLower(strings.TrimSpace(username)), email, local} {
+		if utf8.RuneCountInString(s) >= minUserInfoLen && strings.Contains(password, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package rbac
+
+import (
+	"strings"
+	"time"
+)
+
+// Permission — право на группу операций API
+type Permission string
+
+const (
+	PermServersRead       Permission = "servers:read"
+	PermServersWrite      Permission = "servers:write" // создание/изменение/удаление своих серверов
+	PermServersAll        Permission = "servers:all"   // доступ к серверам всех пользователей
+	PermInstancesRead     Permission = "instances:read"
+	PermInstancesPower    Permission = "instances:power" // start/stop/shutdown/restart/reset/suspend/resume/hibernate
+	PermInstancesSnapshot Permission = "instances:snapshot"
+	PermInstancesDelete   Permission = "instances:delete" // delete/rollback/reinstall и снятие защиты
+	PermUsersManage       Permission = "users:manage"
+	PermAuditRead         Permission = "audit:read" // журнал аудита всех пользователей
+)
+
+// AllPermissions — полный список прав (для валидации пользовательских ролей)
+var AllPermissions = []Permission{
+	PermServersRead, PermServersWrite, PermServersAll,
+	PermInstancesRead, PermInstancesPower, PermInstancesSnapshot, PermInstancesDelete,
+	PermUsersManage, PermAuditRead,
+}
+
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleViewer   = "viewer"
+
+	// Роль для новых пользователей
+	DefaultRole = RoleOperator
+)
+
+// Role — встроенная или пользовательская роль
+type Role struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions"`
+	BuiltIn     bool         `json:"built_in"`
+	CreatedAt   *time.Time   `json:"created_at,omitempty"`
+}
+
+var builtInRoles = []*Role{
+	{Name: RoleAdmin, Description: "Управление пользователями и всеми серверами", Permissions: AllPermissions, BuiltIn: true},
+	{Name: RoleOperator, Description: "Свои серверы и управление питанием инстансов", Permissions: []Permission{
+		PermServersRead, PermServersWrite, PermInstancesRead, PermInstancesPower, PermInstancesSnapshot,
+	}, BuiltIn: true},
+	{Name: RoleViewer, Description: "Только чтение", Permissions: []Permission{PermServersRead, PermInstancesRead}, BuiltIn: true},
+}
+
+type CreateRoleRequest struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// GroupRole — сопоставление группы внешнего каталога (OIDC, LDAP) роли панели
+type GroupRole struct {
+	Group string
+	Role  string
+}
+
+// ParseGroupMapping разбирает "admins=admin,ops=operator"
+func ParseGroupMapping(s string) []GroupRole {
+	var out []GroupRole
+	for _, pair := range strings.Split(s, ",") {
+		group, role, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && group != "" && role != "" {
+			out = append(out, GroupRole{Group: strings.TrimSpace(group), Role: strings.TrimSpace(role)})
+		}
+	}
+	return out
+}
+
+// RoleForGroups выбирает роль по группам (без учёта регистра); порядок mapping задаёт
+// приоритет. Без совпадений — defaultRole; false — вход запрещён (defaultRole пуст).
+func RoleForGroups(mapping []GroupRole, groups []string, defaultRole string) (string, bool) {
+	for _, m := range mapping {
+		for _, g := range groups {
+			if strings.EqualFold(g, m.Group) {
+				return m.Role, true
+			}
+		}
+	}
+	return defaultRole, defaultRole != ""
+}
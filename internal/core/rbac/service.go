@@ -0,0 +1,237 @@
+package rbac
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	mysql "github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrRoleNotFound      = errors.New("role_not_found")
+	ErrRoleExists        = errors.New("role_exists")
+	ErrRoleBuiltIn       = errors.New("role_built_in")
+	ErrRoleInUse         = errors.New("role_in_use")
+	ErrInvalidRoleName   = errors.New("invalid_role_name")
+	ErrUnknownPermission = errors.New("unknown_permission")
+)
+
+var roleNameRe = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+// Кэш прав пользовательских ролей, чтобы не ходить в БД на каждый запрос
+const cacheTTL = 30 * time.Second
+
+type cachedPerms struct {
+	perms   map[Permission]bool
+	expires time.Time
+}
+
+type Service struct {
+	db    *sql.DB
+	mu    sync.Mutex
+	cache map[string]cachedPerms
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db, cache: map[string]cachedPerms{}}
+}
+
+func builtIn(name string) *Role {
+	for _, r := range builtInRoles {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Permissions возвращает набор прав роли
+func (s *Service) Permissions(role string) (map[Permission]bool, error) {
+	if r := builtIn(role); r != nil {
+		return permSet(r.Permissions), nil
+	}
+	s.mu.Lock()
+	c, ok := s.cache[role]
+	s.mu.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.perms, nil
+	}
+	r, err := s.GetRole(role)
+	if err != nil {
+		return nil, err
+	}
+	perms := permSet(r.Permissions)
+	s.mu.Lock()
+	s.cache[role] = cachedPerms{perms: perms, expires: time.Now().Add(cacheTTL)}
+	s.mu.Unlock()
+	return perms, nil
+}
+
+// Can сообщает, есть ли у роли право; неизвестная роль прав не имеет
+func (s *Service) Can(role string, perm Permission) bool {
+	perms, err := s.Permissions(role)
+	return err == nil && perms[perm]
+}
+
+// Roles — проверка существования роли; реализуется *Service
+type Roles interface {
+	Exists(role string) bool
+}
+
+// Exists проверяет, что роль встроенная или создана в БД
+func (s *Service) Exists(role string) bool {
+	_, err := s.Permissions(role)
+	return err == nil
+}
+
+func (s *Service) GetRole(name string) (*Role, error) {
+	if r := builtIn(name); r != nil {
+		return r, nil
+	}
+	var r Role
+	var perms string
+	var created time.Time
+	err := s.db.QueryRow(`SELECT name,description,permissions,created_at FROM roles WHERE name=?`, name).Scan(&r.Name, &r.Description, &perms, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRoleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.Permissions = splitPerms(perms)
+	r.CreatedAt = &created
+	return &r, nil
+}
+
+// ListRoles — встроенные роли, затем пользовательские
+func (s *Service) ListRoles() ([]*Role, error) {
+	list := append([]*Role{}, builtInRoles...)
+	rows, err := s.db.Query(`SELECT name,description,permissions,created_at FROM roles ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r Role
+		var perms string
+		var created time.Time
+		if err := rows.Scan(&r.Name, &r.Description, &perms, &created); err != nil {
+			return nil, err
+		}
+		r.Permissions = splitPerms(perms)
+		r.CreatedAt = &created
+		list = append(list, &r)
+	}
+	return list, rows.Err()
+}
+
+func (s *Service) CreateRole(req *CreateRoleRequest) (*Role, error) {
+	name := strings.ToLower(strings.TrimSpace(req.Name))
+	if !roleNameRe.MatchString(name) {
+		return nil, ErrInvalidRoleName
+	}
+	if builtIn(name) != nil {
+		return nil, ErrRoleExists
+	}
+	if err := validatePerms(req.Permissions); err != nil {
+		return nil, err
+	}
+	_, err := s.db.Exec(`INSERT INTO roles (name,description,permissions,created_at) VALUES (?,?,?,NOW())`, name, req.Description, joinPerms(req.Permissions))
+	if err != nil {
+		if me, ok := err.(*mysql.MySQLError); ok && me.Number == 1062 {
+			return nil, ErrRoleExists
+		}
+		return nil, err
+	}
+	return s.GetRole(name)
+}
+
+func (s *Service) UpdateRole(name string, req *CreateRoleRequest) (*Role, error) {
+	if builtIn(name) != nil {
+		return nil, ErrRoleBuiltIn
+	}
+	if err := validatePerms(req.Permissions); err != nil {
+		return nil, err
+	}
+	res, err := s.db.Exec(`UPDATE roles SET description=?, permissions=? WHERE name=?`, req.Description, joinPerms(req.Permissions), name)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := s.GetRole(name); err != nil {
+			return nil, err
+		}
+	}
+	s.invalidate(name)
+	return s.GetRole(name)
+}
+
+// DeleteRole удаляет пользовательскую роль, если она никому не назначена
+func (s *Service) DeleteRole(name string) error {
+	if builtIn(name) != nil {
+		return ErrRoleBuiltIn
+	}
+	var inUse int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM users WHERE role=?`, name).Scan(&inUse); err != nil {
+		return err
+	}
+	if inUse > 0 {
+		return ErrRoleInUse
+	}
+	res, err := s.db.Exec(`DELETE FROM roles WHERE name=?`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRoleNotFound
+	}
+	s.invalidate(name)
+	return nil
+}
+
+func (s *Service) invalidate(name string) {
+	s.mu.Lock()
+	delete(s.cache, name)
+	s.mu.Unlock()
+}
+
+func validatePerms(perms []Permission) error {
+	known := permSet(AllPermissions)
+	for _, p := range perms {
+		if !known[p] {
+			return ErrUnknownPermission
+		}
+	}
+	return nil
+}
+
+func permSet(perms []Permission) map[Permission]bool {
+	m := make(map[Permission]bool, len(perms))
+	for _, p := range perms {
+		m[p] = true
+	}
+	return m
+}
+
+// Права хранятся строкой через запятую — это позволяет проверять их в SQL через FIND_IN_SET
+func joinPerms(perms []Permission) string {
+	parts := make([]string, 0, len(perms))
+	for _, p := range perms {
+		parts = append(parts, string(p))
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitPerms(s string) []Permission {
+	perms := []Permission{}
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			perms = append(perms, Permission(p))
+		}
+	}
+	return perms
+}
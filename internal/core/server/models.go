@@ -14,9 +14,12 @@ type Server struct {
 	UsernameDecrypted string    `json:"username"`
 	PasswordDecrypted string    `json:"-"`
 	UserID            int       `json:"user_id"`
+	OrganizationID    *int      `json:"organization_id"` // nil — личный сервер
 	IsActive          bool      `json:"is_active"`
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
+	// Роль текущего пользователя в организации сервера ("" — личный сервер или доступ по servers:all)
+	MemberRole string `json:"member_role,omitempty"`
 }
 
 type CreateServerRequest struct {
@@ -26,6 +29,8 @@ type CreateServerRequest struct {
 	Type     string `json:"type"`
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// Организация-владелец; пусто — личный сервер
+	OrganizationID *int `json:"organization_id,omitempty"`
 }
 
 type UpdateServerRequest struct {
@@ -35,4 +40,6 @@ type UpdateServerRequest struct {
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"`
 	IsActive *bool  `json:"is_active,omitempty"`
+	// Перенос в организацию; 0 — вернуть в личные серверы
+	OrganizationID *int `json:"organization_id,omitempty"`
 }
@@ -1,71 +1,128 @@
 package server
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"os"
+	"log"
 	"ospab-panel/internal/infra/crypto"
+	"ospab-panel/internal/infra/secrets"
 	"strings"
 )
 
-type Service struct{ db *sql.DB }
+// Service — серверы гипервизоров. Учётные данные хранятся в secrets, в БД — только ссылки.
+type Service struct {
+	db      *sql.DB
+	secrets *secrets.Router
+}
+
+func NewService(db *sql.DB, store *secrets.Router) *Service { return &Service{db: db, secrets: store} }
+
+// accessCond — SQL-условие доступа к серверу: личный сервер владельца, сервер организации,
+// в которой состоит пользователь, либо роль с правом servers:all (встроенная admin или
+// пользовательская роль). Параметры: userID, userID, userID.
+const accessCond = `((organization_id IS NULL AND user_id = ?)
+	OR organization_id IN (SELECT org_id FROM organization_members WHERE user_id = ?)
+	OR EXISTS (SELECT 1 FROM users u LEFT JOIN roles r ON r.name = u.role
+		WHERE u.id = ? AND (u.role = 'admin' OR FIND_IN_SET('servers:all', r.permissions) > 0)))`
+
+const serverColumns = `id,name,host,port,type,username_enc,password_enc,user_id,organization_id,is_active,created_at,updated_at`
+
+// memberRoleCol — роль пользователя в организации сервера ("" для личных серверов). Параметр: userID.
+const memberRoleCol = `COALESCE((SELECT m.role FROM organization_members m WHERE m.org_id = servers.organization_id AND m.user_id = ?), '')`
 
-func (s *Service) key() ([]byte, error) {
-	k := os.Getenv("SERVER_SECRET_KEY")
-	if len(k) == 0 { // fallback dev insecure
-		k = "dev-insecure-key-dev-insecure-key-32!!" // 32 bytes
+func (s *Service) scanServer(sc interface{ Scan(...any) error }) (*Server, error) {
+	var srv Server
+	var orgID sql.NullInt64
+	if err := sc.Scan(&srv.ID, &srv.Name, &srv.Host, &srv.Port, &srv.Type, &srv.UsernameEnc, &srv.PasswordEnc, &srv.UserID, &orgID, &srv.IsActive, &srv.CreatedAt, &srv.UpdatedAt, &srv.MemberRole); err != nil {
+		return nil, err
 	}
-	if len(k) < 32 {
-		k = (k + strings.Repeat("0", 32))[:32]
+	if orgID.Valid {
+		id := int(orgID.Int64)
+		srv.OrganizationID = &id
 	}
-	return []byte(k[:32]), nil
+	if err := s.decryptRuntime(&srv); err != nil {
+		return nil, err
+	}
+	return &srv, nil
 }
 
-func NewService(db *sql.DB) *Service { return &Service{db: db} }
-
-func (s *Service) GetServersByUserID(userID int) ([]*Server, error) {
-	rows, err := s.db.Query(`SELECT id,name,host,port,type,username_enc,password_enc,user_id,is_active,created_at,updated_at FROM servers WHERE user_id = ? AND is_active=1`, userID)
+func (s *Service) queryServers(query string, args ...interface{}) ([]*Server, error) {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	list := []*Server{}
 	for rows.Next() {
-		var srv Server
-		if err := rows.Scan(&srv.ID, &srv.Name, &srv.Host, &srv.Port, &srv.Type, &srv.UsernameEnc, &srv.PasswordEnc, &srv.UserID, &srv.IsActive, &srv.CreatedAt, &srv.UpdatedAt); err != nil {
-			return nil, err
-		}
-		if err := s.decryptRuntime(&srv); err != nil {
+		srv, err := s.scanServer(rows)
+		if err != nil {
 			return nil, err
 		}
-		list = append(list, &srv)
+		list = append(list, srv)
 	}
-	return list, nil
+	return list, rows.Err()
+}
+
+func (s *Service) GetServersByUserID(userID int) ([]*Server, error) {
+	return s.queryServers(`SELECT `+serverColumns+`,`+memberRoleCol+` FROM servers WHERE `+accessCond+` AND is_active=1`, userID, userID, userID, userID)
+}
+
+// GetActiveServers возвращает все активные серверы (для фоновых задач, без учёта владельца)
+func (s *Service) GetActiveServers() ([]*Server, error) {
+	return s.queryServers(`SELECT ` + serverColumns + `,'' FROM servers WHERE is_active=1`)
+}
+
+// GetActiveServer возвращает активный сервер без проверки доступа — для случаев,
+// когда доступ уже подтверждён иначе (например, грантом на инстанс)
+func (s *Service) GetActiveServer(id int) (*Server, error) {
+	return s.scanServer(s.db.QueryRow(`SELECT `+serverColumns+`,'' FROM servers WHERE id=? AND is_active=1`, id))
 }
 
 func (s *Service) GetServerByID(id, userID int) (*Server, error) {
-	var srv Server
-	err := s.db.QueryRow(`SELECT id,name,host,port,type,username_enc,password_enc,user_id,is_active,created_at,updated_at FROM servers WHERE id=? AND user_id=?`, id, userID).Scan(&srv.ID, &srv.Name, &srv.Host, &srv.Port, &srv.Type, &srv.UsernameEnc, &srv.PasswordEnc, &srv.UserID, &srv.IsActive, &srv.CreatedAt, &srv.UpdatedAt)
+	return s.scanServer(s.db.QueryRow(`SELECT `+serverColumns+`,`+memberRoleCol+` FROM servers WHERE id=? AND `+accessCond, userID, id, userID, userID, userID))
+}
+
+// CreateServer создаёт сервер; при req.OrganizationID он принадлежит организации.
+// Право пользователя добавлять серверы в организацию проверяет вызывающий.
+func (s *Service) CreateServer(req *CreateServerRequest, userID int) (*Server, error) {
+	// Секреты привязаны к id записи, поэтому сначала вставляется запись, затем — ссылки
+	tx, err := s.db.Begin()
 	if err != nil {
 		return nil, err
 	}
-	if err := s.decryptRuntime(&srv); err != nil {
+	defer tx.Rollback()
+	res, err := tx.Exec(`INSERT INTO servers (name,host,port,type,username_enc,password_enc,user_id,organization_id,is_active,created_at,updated_at) VALUES (?,?,?,?,'','',?,?,1,NOW(),NOW())`, req.Name, req.Host, req.Port, req.Type, userID, orgIDArg(req.OrganizationID))
+	if err != nil {
 		return nil, err
 	}
-	return &srv, nil
-}
-
-func (s *Service) CreateServer(req *CreateServerRequest, userID int) (*Server, error) {
-	encUser, encPass, err := s.encryptCredentials(req.Username, req.Password)
+	id64, err := res.LastInsertId()
 	if err != nil {
 		return nil, err
 	}
-	res, err := s.db.Exec(`INSERT INTO servers (name,host,port,type,username_enc,password_enc,user_id,is_active,created_at,updated_at) VALUES (?,?,?,?,?,?,?,1,NOW(),NOW())`, req.Name, req.Host, req.Port, req.Type, encUser, encPass, userID)
+	id := int(id64)
+	encUser, encPass, err := s.encryptCredentials(id, req.Username, req.Password)
 	if err != nil {
 		return nil, err
 	}
-	id, _ := res.LastInsertId()
-	return s.GetServerByID(int(id), userID)
+	if _, err := tx.Exec("UPDATE servers SET username_enc=?, password_enc=? WHERE id=?", encUser, encPass, id); err != nil {
+		s.DeleteSecrets(encUser, encPass)
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		s.DeleteSecrets(encUser, encPass)
+		return nil, err
+	}
+	return s.GetServerByID(id, userID)
+}
+
+// orgIDArg: nil или 0 — личный сервер (NULL)
+func orgIDArg(id *int) interface{} {
+	if id == nil || *id == 0 {
+		return nil
+	}
+	return *id
 }
 
 func (s *Service) UpdateServer(id, userID int, req *UpdateServerRequest) (*Server, error) {
@@ -75,6 +132,8 @@ func (s *Service) UpdateServer(id, userID int, req *UpdateServerRequest) (*Serve
 	}
 	set := []string{}
 	args := []interface{}{}
+	// Ссылки на заменённые секреты и на новые — одни удаляются после записи, другие при ошибке
+	var replaced, added []string
 	if req.Name != "" {
 		set = append(set, "name=?")
 		args = append(args, req.Name)
@@ -88,39 +147,53 @@ func (s *Service) UpdateServer(id, userID int, req *UpdateServerRequest) (*Serve
 		args = append(args, req.Port)
 	}
 	if req.Username != "" {
-		encUser, _, err := s.encryptCredentials(req.Username, existing.PasswordDecrypted)
+		encUser, err := s.secrets.Put(context.Background(), req.Username, usernameAAD(id))
 		if err != nil {
 			return nil, err
 		}
 		set = append(set, "username_enc=?")
 		args = append(args, encUser)
+		replaced, added = append(replaced, existing.UsernameEnc), append(added, encUser)
 	}
 	if req.Password != "" {
-		_, encPass, err := s.encryptCredentials(existing.UsernameDecrypted, req.Password)
+		encPass, err := s.secrets.Put(context.Background(), req.Password, passwordAAD(id))
 		if err != nil {
+			s.DeleteSecrets(added...)
 			return nil, err
 		}
 		set = append(set, "password_enc=?")
 		args = append(args, encPass)
+		replaced, added = append(replaced, existing.PasswordEnc), append(added, encPass)
 	}
 	if req.IsActive != nil {
 		set = append(set, "is_active=?")
 		args = append(args, *req.IsActive)
 	}
+	if req.OrganizationID != nil {
+		// При возврате в личные серверы владельцем становится тот, кто переносит
+		set = append(set, "organization_id=?", "user_id=IF(? IS NULL, ?, user_id)")
+		args = append(args, orgIDArg(req.OrganizationID), orgIDArg(req.OrganizationID), userID)
+	}
 	if len(set) == 0 {
 		return existing, nil
 	}
-	query := fmt.Sprintf("UPDATE servers SET %s, updated_at=NOW() WHERE id=? AND user_id=?", strings.Join(set, ","))
-	args = append(args, id, userID)
-	_, err = s.db.Exec(query, args...)
+	query := fmt.Sprintf("UPDATE servers SET %s, updated_at=NOW() WHERE id=? AND %s", strings.Join(set, ","), accessCond)
+	args = append(args, id, userID, userID, userID)
+	res, err := s.db.Exec(query, args...)
 	if err != nil {
+		s.DeleteSecrets(added...)
 		return nil, err
 	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		s.DeleteSecrets(replaced...)
+	} else {
+		s.DeleteSecrets(added...)
+	}
 	return s.GetServerByID(id, userID)
 }
 
 func (s *Service) DeleteServer(id, userID int) error {
-	res, err := s.db.Exec(`UPDATE servers SET is_active=0, updated_at=NOW() WHERE id=? AND user_id=?`, id, userID)
+	res, err := s.db.Exec(`UPDATE servers SET is_active=0, updated_at=NOW() WHERE id=? AND `+accessCond, id, userID, userID, userID)
 	if err != nil {
 		return err
 	}
@@ -132,26 +205,31 @@ func (s *Service) DeleteServer(id, userID int) error {
 }
 
 // --- Internal helpers ---
-func (s *Service) encryptCredentials(username, password string) (string, string, error) {
-	key, _ := s.key()
-	u, err := crypto.EncryptString(key, username)
+
+// Учётные данные привязаны к записи сервера и столбцу: ссылку или шифротекст нельзя
+// перенести в другую запись или поменять местами логин и пароль
+func usernameAAD(id int) []byte { return crypto.AAD("servers", "username_enc", id) }
+func passwordAAD(id int) []byte { return crypto.AAD("servers", "password_enc", id) }
+
+func (s *Service) encryptCredentials(id int, username, password string) (string, string, error) {
+	u, err := s.secrets.Put(context.Background(), username, usernameAAD(id))
 	if err != nil {
 		return "", "", err
 	}
-	p, err := crypto.EncryptString(key, password)
+	p, err := s.secrets.Put(context.Background(), password, passwordAAD(id))
 	if err != nil {
+		s.DeleteSecrets(u)
 		return "", "", err
 	}
 	return u, p, nil
 }
 
 func (s *Service) decryptRuntime(srv *Server) error {
-	key, _ := s.key()
-	u, err := crypto.DecryptString(key, srv.UsernameEnc)
+	u, err := s.secrets.Get(context.Background(), srv.UsernameEnc, usernameAAD(srv.ID))
 	if err != nil {
 		return err
 	}
-	p, err := crypto.DecryptString(key, srv.PasswordEnc)
+	p, err := s.secrets.Get(context.Background(), srv.PasswordEnc, passwordAAD(srv.ID))
 	if err != nil {
 		return err
 	}
@@ -159,3 +237,72 @@ func (s *Service) decryptRuntime(srv *Server) error {
 	srv.PasswordDecrypted = p
 	return nil
 }
+
+// DeleteSecrets удаляет секреты, на которые больше не ссылается ни одна запись.
+// Ошибка не критична: во внешнем хранилище останется лишний секрет.
+func (s *Service) DeleteSecrets(refs ...string) {
+	for _, ref := range refs {
+		if err := s.secrets.Delete(context.Background(), ref); err != nil {
+			log.Printf("Delete server secret: %v", err)
+		}
+	}
+}
+
+// RotateCredentials переносит учётные данные серверов (включая удалённые) в текущее
+// хранилище секретов, перешифровывает их активным ключом и привязывает к записи. Запись обновляется, только
+// если её не изменили параллельно. Возвращает число обновлённых серверов; ошибки по
+// отдельным серверам не прерывают обход.
+func (s *Service) RotateCredentials() (int, error) {
+	rows, err := s.db.Query("SELECT id, username_enc, password_enc FROM servers")
+	if err != nil {
+		return 0, err
+	}
+	type creds struct {
+		id         int
+		user, pass string
+	}
+	var list []creds
+	for rows.Next() {
+		var c creds
+		if err := rows.Scan(&c.id, &c.user, &c.pass); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if s.secrets.Outdated(c.user) || s.secrets.Outdated(c.pass) {
+			list = append(list, c)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	var errs []error
+	for _, c := range list {
+		srv := &Server{ID: c.id, UsernameEnc: c.user, PasswordEnc: c.pass}
+		if err := s.decryptRuntime(srv); err != nil {
+			errs = append(errs, fmt.Errorf("server %d: %w", c.id, err))
+			continue
+		}
+		user, pass, err := s.encryptCredentials(c.id, srv.UsernameDecrypted, srv.PasswordDecrypted)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("server %d: %w", c.id, err))
+			continue
+		}
+		res, err := s.db.Exec("UPDATE servers SET username_enc=?, password_enc=? WHERE id=? AND username_enc=? AND password_enc=?",
+			user, pass, c.id, c.user, c.pass)
+		if err != nil {
+			s.DeleteSecrets(user, pass)
+			errs = append(errs, fmt.Errorf("server %d: %w", c.id, err))
+			continue
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			rotated++
+			s.DeleteSecrets(c.user, c.pass)
+		} else {
+			s.DeleteSecrets(user, pass)
+		}
+	}
+	return rotated, errors.Join(errs...)
+}
@@ -0,0 +1,23 @@
+package session
+
+import "time"
+
+// Session — вход пользователя с конкретного устройства; продлевается refresh-токенами
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int       `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	MFA        bool      `json:"mfa"` // вход подтверждён вторым фактором
+	// ImpersonatorID — администратор, открывший сессию от имени пользователя
+	ImpersonatorID int `json:"impersonator_id,omitempty"`
+	// Текущая сессия запроса (заполняется обработчиком)
+	Current bool `json:"current,omitempty"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
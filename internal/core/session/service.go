@@ -0,0 +1,223 @@
+package session
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"ospab-panel/pkg/auth"
+)
+
+// DefaultRefreshTTL — максимальный срок жизни сессии без повторного входа
+const DefaultRefreshTTL = 30 * 24 * time.Hour
+
+// ImpersonationTTL — срок сессии администратора от имени пользователя (не продлевается)
+const ImpersonationTTL = time.Hour
+
+var (
+	ErrInvalidRefresh = errors.New("refresh token is invalid or expired")
+	// ErrRefreshReused — предъявлен уже использованный refresh-токен; сессия отозвана
+	ErrRefreshReused = errors.New("refresh token reuse detected, session revoked")
+)
+
+// Service хранит сессии, ротируемые refresh-токены (только хэши) и список отозванных jti
+type Service struct {
+	db         *sql.DB
+	refreshTTL time.Duration
+}
+
+func NewService(db *sql.DB, refreshTTL time.Duration) *Service {
+	if refreshTTL <= 0 {
+		refreshTTL = DefaultRefreshTTL
+	}
+	return &Service{db: db, refreshTTL: refreshTTL}
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Create открывает сессию и возвращает первый refresh-токен
+func (s *Service) Create(userID int, userAgent, ip string, mfa bool) (*Session, string, error) {
+	return s.create(userID, 0, userAgent, ip, mfa, s.refreshTTL)
+}
+
+// CreateImpersonation открывает короткую сессию администратора impersonatorID от имени
+// пользователя. Второй фактор пользователя такой сессией не подтверждается.
+func (s *Service) CreateImpersonation(userID, impersonatorID int, userAgent, ip string) (*Session, string, error) {
+	return s.create(userID, impersonatorID, userAgent, ip, false, ImpersonationTTL)
+}
+
+func (s *Service) create(userID, impersonatorID int, userAgent, ip string, mfa bool, ttl time.Duration) (*Session, string, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, "", err
+	}
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	now := time.Now()
+	sess := &Session{ID: id, UserID: userID, UserAgent: userAgent, IP: ip, CreatedAt: now, LastUsedAt: now, ExpiresAt: now.Add(ttl), MFA: mfa, ImpersonatorID: impersonatorID}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`INSERT INTO sessions (id,user_id,user_agent,ip,mfa,impersonator_id,created_at,last_used_at,expires_at) VALUES (?,?,?,?,?,?,?,?,?)`,
+		sess.ID, userID, sess.UserAgent, ip, mfa, sql.NullInt64{Int64: int64(impersonatorID), Valid: impersonatorID != 0}, now, now, sess.ExpiresAt); err != nil {
+		return nil, "", err
+	}
+	token, err := issueRefresh(tx, sess.ID, sess.ExpiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	return sess, token, nil
+}
+
+func issueRefresh(tx *sql.Tx, sessionID string, expires time.Time) (string, error) {
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(`INSERT INTO refresh_tokens (token_hash,session_id,expires_at,created_at) VALUES (?,?,?,?)`, hash, sessionID, expires, time.Now())
+	return token, err
+}
+
+// Rotate погашает refresh-токен и выдаёт следующий. Повторное предъявление
+// использованного токена означает утечку — сессия отзывается целиком.
+func (s *Service) Rotate(refreshToken, ip string) (*Session, string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+	var (
+		sess    Session
+		used    sql.NullTime
+		expires time.Time
+		revoked sql.NullTime
+		imp     sql.NullInt64
+	)
+	err = tx.QueryRow(`SELECT t.used_at,t.expires_at,s.id,s.user_id,s.user_agent,s.ip,s.mfa,s.impersonator_id,s.created_at,s.last_used_at,s.expires_at,s.revoked_at
+		FROM refresh_tokens t JOIN sessions s ON s.id=t.session_id WHERE t.token_hash=? FOR UPDATE`, auth.HashToken(refreshToken)).
+		Scan(&used, &expires, &sess.ID, &sess.UserID, &sess.UserAgent, &sess.IP, &sess.MFA, &imp, &sess.CreatedAt, &sess.LastUsedAt, &sess.ExpiresAt, &revoked)
+	sess.ImpersonatorID = int(imp.Int64)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", ErrInvalidRefresh
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	if revoked.Valid || !now.Before(expires) || !now.Before(sess.ExpiresAt) {
+		return nil, "", ErrInvalidRefresh
+	}
+	if used.Valid {
+		if _, err := tx.Exec(`UPDATE sessions SET revoked_at=? WHERE id=?`, now, sess.ID); err != nil {
+			return nil, "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, "", err
+		}
+		log.Printf("Session %s of user %d revoked: refresh token reuse from %s", sess.ID, sess.UserID, ip)
+		return nil, "", ErrRefreshReused
+	}
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET used_at=? WHERE token_hash=?`, now, auth.HashToken(refreshToken)); err != nil {
+		return nil, "", err
+	}
+	if _, err := tx.Exec(`UPDATE sessions SET last_used_at=?, ip=? WHERE id=?`, now, ip, sess.ID); err != nil {
+		return nil, "", err
+	}
+	token, err := issueRefresh(tx, sess.ID, sess.ExpiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	sess.LastUsedAt, sess.IP = now, ip
+	return &sess, token, nil
+}
+
+// Revoke завершает сессию пользователя
+func (s *Service) Revoke(userID int, sessionID string) error {
+	res, err := s.db.Exec(`UPDATE sessions SET revoked_at=? WHERE id=? AND user_id=? AND revoked_at IS NULL`, time.Now(), sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// MarkMFA отмечает сессию как подтверждённую вторым фактором (после включения 2FA);
+// новые access-токены сессии получат отметку при следующем refresh
+func (s *Service) MarkMFA(sessionID string) error {
+	_, err := s.db.Exec(`UPDATE sessions SET mfa=1 WHERE id=?`, sessionID)
+	return err
+}
+
+// RevokeAll завершает все сессии пользователя (смена пароля, блокировка)
+func (s *Service) RevokeAll(userID int) error {
+	_, err := s.db.Exec(`UPDATE sessions SET revoked_at=? WHERE user_id=? AND revoked_at IS NULL`, time.Now(), userID)
+	return err
+}
+
+// RevokeAccessToken заносит jti в denylist до истечения токена
+func (s *Service) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	_, err := s.db.Exec(`INSERT IGNORE INTO revoked_tokens (jti,expires_at,created_at) VALUES (?,?,?)`, jti, expiresAt, time.Now())
+	return err
+}
+
+// IsRevoked проверяет access-токен: jti в denylist или сессия завершена
+func (s *Service) IsRevoked(jti, sessionID string) (bool, error) {
+	var revoked bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti=?)
+		OR NOT EXISTS (SELECT 1 FROM sessions WHERE id=? AND revoked_at IS NULL AND expires_at > ?)`, jti, sessionID, time.Now()).Scan(&revoked)
+	return revoked, err
+}
+
+// ListActive возвращает действующие сессии пользователя (последние сверху)
+func (s *Service) ListActive(userID int) ([]*Session, error) {
+	rows, err := s.db.Query(`SELECT id,user_id,user_agent,ip,mfa,impersonator_id,created_at,last_used_at,expires_at FROM sessions
+		WHERE user_id=? AND revoked_at IS NULL AND expires_at > ? ORDER BY last_used_at DESC`, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*Session{}
+	for rows.Next() {
+		var sess Session
+		var imp sql.NullInt64
+		if err := rows.Scan(&sess.ID, &sess.UserID, &sess.UserAgent, &sess.IP, &sess.MFA, &imp, &sess.CreatedAt, &sess.LastUsedAt, &sess.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sess.ImpersonatorID = int(imp.Int64)
+		list = append(list, &sess)
+	}
+	return list, rows.Err()
+}
+
+// PurgeExpired удаляет истёкшие записи denylist, refresh-токенов и сессий
+func (s *Service) PurgeExpired() error {
+	now := time.Now()
+	if _, err := s.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at <= ?`, now); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM refresh_tokens WHERE expires_at <= ?`, now); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE expires_at <= ?`, now)
+	return err
+}
@@ -0,0 +1,128 @@
+package settings
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Ключи настроек панели и значения по умолчанию
+const (
+	// MFARequiredForDelete — действия с правом instances:delete только после входа с 2FA
+	MFARequiredForDelete = "mfa_required_for_delete"
+	// RegistrationEnabled — открытая регистрация через /api/auth/register
+	RegistrationEnabled = "registration_enabled"
+)
+
+var defaults = map[string]string{
+	MFARequiredForDelete: "false",
+	RegistrationEnabled:  "true",
+}
+
+// Ключи с логическими значениями (проверяются при записи)
+var boolKeys = map[string]bool{
+	MFARequiredForDelete: true,
+	RegistrationEnabled:  true,
+}
+
+var (
+	ErrUnknownKey   = errors.New("unknown_setting")
+	ErrInvalidValue = errors.New("invalid_setting_value")
+)
+
+const cacheTTL = 10 * time.Second
+
+// Service — глобальные настройки панели (таблица settings) с коротким кэшем
+type Service struct {
+	db      *sql.DB
+	mu      sync.Mutex
+	cache   map[string]string
+	expires time.Time
+}
+
+func NewService(db *sql.DB) *Service { return &Service{db: db} }
+
+// All возвращает все известные настройки с учётом значений по умолчанию
+func (s *Service) All() (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cache != nil && time.Now().Before(s.expires) {
+		return copyMap(s.cache), nil
+	}
+	values := copyMap(defaults)
+	rows, err := s.db.Query(`SELECT name,value FROM settings`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, err
+		}
+		if _, ok := defaults[k]; ok {
+			values[k] = v
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	s.cache, s.expires = values, time.Now().Add(cacheTTL)
+	return copyMap(values), nil
+}
+
+// Get возвращает значение; при ошибке БД — значение по умолчанию
+func (s *Service) Get(key string) string {
+	all, err := s.All()
+	if err != nil {
+		return defaults[key]
+	}
+	return all[key]
+}
+
+// Bool — логическое значение настройки
+func (s *Service) Bool(key string) bool {
+	v, _ := strconv.ParseBool(s.Get(key))
+	return v
+}
+
+// Update записывает набор настроек (неизвестные ключи отклоняются целиком)
+func (s *Service) Update(values map[string]string) error {
+	for k, v := range values {
+		if _, ok := defaults[k]; !ok {
+			return ErrUnknownKey
+		}
+		if boolKeys[k] {
+			if _, err := strconv.ParseBool(v); err != nil {
+				return ErrInvalidValue
+			}
+		}
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for k, v := range values {
+		if _, err := tx.Exec(`INSERT INTO settings (name,value,updated_at) VALUES (?,?,NOW()) ON DUPLICATE KEY UPDATE value=VALUES(value), updated_at=NOW()`, k, v); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.cache = nil
+	s.mu.Unlock()
+	return nil
+}
+
+func copyMap(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
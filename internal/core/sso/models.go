@@ -0,0 +1,39 @@
+package sso
+
+import "ospab-panel/internal/core/rbac"
+
+// Config — параметры провайдера OpenID Connect
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string   // пусто для публичного клиента (только PKCE)
+	RedirectURL  string   // .../api/auth/oidc/callback
+	Scopes       []string // openid добавляется всегда
+	GroupsClaim  string   // claim со списком групп
+	// RoleMapping — группа IdP → роль панели; порядок задаёт приоритет (первое совпадение)
+	RoleMapping []rbac.GroupRole
+	// DefaultRole — роль при отсутствии подходящей группы; пусто — вход запрещён
+	DefaultRole string
+	// FrontendURL — куда вернуть браузер после входа (к адресу добавляется /login?sso_code=...)
+	FrontendURL string
+}
+
+// RoleFor выбирает роль по группам пользователя; false — вход запрещён
+func (c Config) RoleFor(groups []string) (string, bool) {
+	return rbac.RoleForGroups(c.RoleMapping, groups, c.DefaultRole)
+}
+
+// Identity — проверенные данные пользователя из ID-токена
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Groups        []string
+	MFA           bool // IdP сообщил о многофакторном входе (claim amr)
+}
+
+type ExchangeRequest struct {
+	Code string `json:"code"`
+}
@@ -0,0 +1,268 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"ospab-panel/internal/core/challenge"
+	"ospab-panel/internal/core/rbac"
+	"ospab-panel/internal/core/user"
+)
+
+const (
+	// StateTTL — время на вход у провайдера
+	StateTTL = 10 * time.Minute
+	// ResultTTL — время на обмен одноразового кода на токены панели
+	ResultTTL = time.Minute
+)
+
+var (
+	ErrDisabled     = errors.New("oidc_not_configured")
+	ErrInvalidToken = errors.New("oidc_invalid_id_token")
+	ErrNoRole       = errors.New("oidc_no_role_for_groups")
+)
+
+// Challenges — одноразовые state и коды входа (challenge.Service)
+type Challenges interface {
+	Issue(kind string, userID int, data string, ttl time.Duration) (string, error)
+	Consume(kind, token string) (*challenge.Challenge, error)
+}
+
+// Service — вход через OpenID Connect (authorization code + PKCE).
+// state, PKCE verifier и nonce хранятся в auth_challenges.
+type Service struct {
+	cfg        Config
+	challenges Challenges
+	users      user.External
+	roles      rbac.Roles
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func NewService(challenges Challenges, users user.External, roles rbac.Roles, cfg Config) *Service {
+	return &Service{cfg: cfg, challenges: challenges, users: users, roles: roles}
+}
+
+// Enabled — задан ли провайдер
+func (s *Service) Enabled() bool { return s.cfg.Issuer != "" && s.cfg.ClientID != "" }
+
+// syncRoles — роль назначается по группам при каждом входе (задано сопоставление групп)
+func (s *Service) syncRoles() bool { return len(s.cfg.RoleMapping) > 0 }
+
+// oauth выполняет discovery лениво: панель запускается и при недоступном IdP
+func (s *Service) oauth(ctx context.Context) (*oauth2.Config, *oidc.Provider, error) {
+	if !s.Enabled() {
+		return nil, nil, ErrDisabled
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.provider == nil {
+		p, err := oidc.NewProvider(ctx, s.cfg.Issuer)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.provider = p
+	}
+	scopes := []string{oidc.ScopeOpenID}
+	for _, sc := range s.cfg.Scopes {
+		if sc != "" && sc != oidc.ScopeOpenID {
+			scopes = append(scopes, sc)
+		}
+	}
+	return &oauth2.Config{
+		ClientID:     s.cfg.ClientID,
+		ClientSecret: s.cfg.ClientSecret,
+		RedirectURL:  s.cfg.RedirectURL,
+		Endpoint:     s.provider.Endpoint(),
+		Scopes:       scopes,
+	}, s.provider, nil
+}
+
+type authState struct {
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+}
+
+// AuthURL — адрес страницы входа провайдера для нового запроса
+func (s *Service) AuthURL(ctx context.Context) (string, error) {
+	conf, _, err := s.oauth(ctx)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	st := authState{Verifier: oauth2.GenerateVerifier(), Nonce: hex.EncodeToString(nonce)}
+	data, _ := json.Marshal(st)
+	state, err := s.challenges.Issue(challenge.KindOIDCState, 0, string(data), StateTTL)
+	if err != nil {
+		return "", err
+	}
+	return conf.AuthCodeURL(state, oauth2.S256ChallengeOption(st.Verifier), oidc.Nonce(st.Nonce)), nil
+}
+
+// Callback обменивает code на токены провайдера и проверяет ID-токен
+func (s *Service) Callback(ctx context.Context, code, state string) (*Identity, error) {
+	conf, provider, err := s.oauth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.challenges.Consume(challenge.KindOIDCState, state)
+	if err != nil {
+		return nil, err
+	}
+	var st authState
+	if err := json.Unmarshal([]byte(c.Data), &st); err != nil {
+		return nil, err
+	}
+	tok, err := conf.Exchange(ctx, code, oauth2.VerifierOption(st.Verifier))
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := tok.Extra("id_token").(string)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	idt, err := provider.Verifier(&oidc.Config{ClientID: s.cfg.ClientID}).Verify(ctx, raw)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+	if idt.Nonce != st.Nonce {
+		return nil, ErrInvalidToken
+	}
+	var claims map[string]interface{}
+	if err := idt.Claims(&claims); err != nil {
+		return nil, err
+	}
+	id := &Identity{
+		Issuer:        idt.Issuer,
+		Subject:       idt.Subject,
+		Email:         strings.ToLower(claimString(claims, "email")),
+		EmailVerified: claims["email_verified"] == true,
+		Username:      claimString(claims, "preferred_username"),
+		Groups:        claimList(claims, s.cfg.GroupsClaim),
+	}
+	if id.Username == "" {
+		id.Username, _, _ = strings.Cut(id.Email, "@")
+	}
+	// RFC 8176: mfa, otp, hwk — вход у провайдера был многофакторным
+	for _, m := range claimList(claims, "amr") {
+		if m == "mfa" || m == "otp" || m == "hwk" {
+			id.MFA = true
+		}
+	}
+	return id, nil
+}
+
+// Provision находит или создаёт (just-in-time) пользователя панели и
+// синхронизирует его роль с группами провайдера
+func (s *Service) Provision(id *Identity) (*user.User, error) {
+	role, ok := s.cfg.RoleFor(id.Groups)
+	if !ok {
+		return nil, ErrNoRole
+	}
+	if !s.roles.Exists(role) {
+		return nil, fmt.Errorf("role %q from OIDC mapping does not exist", role)
+	}
+	u, err := s.users.IdentityUser(id.Issuer, id.Subject, id.Email)
+	switch {
+	case err == nil:
+		if s.syncRoles() && u.Role != role {
+			return s.users.SetRole(u.ID, role)
+		}
+		return u, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+	if id.Email == "" {
+		return nil, errors.New("id token has no email claim")
+	}
+	// Существующий локальный пользователь привязывается, только если email подтверждён
+	// и провайдером, и в панели: иначе адрес мог заранее занять кто-то другой
+	u, err = s.users.GetUserByEmail(id.Email)
+	switch {
+	case err == nil && (!id.EmailVerified || !u.EmailVerified):
+		return nil, user.ErrEmailTaken
+	case err == nil:
+		if s.syncRoles() && u.Role != role {
+			if u, err = s.users.SetRole(u.ID, role); err != nil {
+				return nil, err
+			}
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		if u, err = s.users.CreateExternalUser(id.Username, id.Email, role); err != nil {
+			return nil, err
+		}
+		if id.EmailVerified {
+			if err := s.users.MarkEmailVerified(u.ID); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, err
+	}
+	if err := s.users.LinkIdentity(u.ID, id.Issuer, id.Subject, id.Email); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// IssueResult выдаёт одноразовый код, который фронтенд обменяет на токены панели:
+// сами токены не попадают в URL
+func (s *Service) IssueResult(userID int, mfa bool) (string, error) {
+	data := ""
+	if mfa {
+		data = "mfa"
+	}
+	return s.challenges.Issue(challenge.KindOIDCResult, userID, data, ResultTTL)
+}
+
+// Redeem погашает код из IssueResult
+func (s *Service) Redeem(code string) (userID int, mfa bool, err error) {
+	c, err := s.challenges.Consume(challenge.KindOIDCResult, code)
+	if err != nil {
+		return 0, false, err
+	}
+	return c.UserID, c.Data == "mfa", nil
+}
+
+// FrontendRedirect — адрес страницы входа фронтенда с кодом или ошибкой
+func (s *Service) FrontendRedirect(param, value string) string {
+	return strings.TrimRight(s.cfg.FrontendURL, "/") + "/login?" + url.Values{param: {value}}.Encode()
+}
+
+func claimString(claims map[string]interface{}, name string) string {
+	v, _ := claims[name].(string)
+	return strings.TrimSpace(v)
+}
+
+// claimList — claim-массив строк; одиночная строка тоже допускается
+func claimList(claims map[string]interface{}, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, x := range v {
+			if s, ok := x.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
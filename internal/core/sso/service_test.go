@@ -0,0 +1,394 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+
+	"ospab-panel/internal/core/challenge"
+	"ospab-panel/internal/core/rbac"
+	"ospab-panel/internal/core/user"
+	"ospab-panel/internal/core/user/usertest"
+)
+
+const (
+	testClientID     = "panel"
+	testClientSecret = "s3cret"
+)
+
+// testIssuer — провайдер OIDC: discovery, JWKS и token endpoint. Страницу входа
+// заменяет authorize: она выдаёт code для запроса из AuthURL.
+type testIssuer struct {
+	t   *testing.T
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+// grant — code, выданный провайдером, и данные запроса, для которого он выдан
+type grant struct {
+	challenge   string
+	redirectURI string
+	claims      jwt.MapClaims
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testIssuer{t: t, key: key, codes: map[string]grant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                p.srv.URL,
+			"authorization_endpoint":                p.srv.URL + "/authorize",
+			"token_endpoint":                        p.srv.URL + "/token",
+			"jwks_uri":                              p.srv.URL + "/keys",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "alg": "RS256", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", p.token)
+	p.srv = httptest.NewServer(mux)
+	t.Cleanup(p.srv.Close)
+	return p
+}
+
+func (p *testIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if id != testClientID || secret != testClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	p.mu.Lock()
+	g, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+	// RFC 7636: code выдаётся только тому, кто знает verifier своего запроса
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge || r.PostForm.Get("redirect_uri") != g.redirectURI {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "at", "token_type": "Bearer", "expires_in": 60, "id_token": p.sign(g.claims),
+	})
+}
+
+func (p *testIssuer) sign(claims jwt.MapClaims) string {
+	p.mu.Lock()
+	key := p.key
+	p.mu.Unlock()
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok.Header["kid"] = "k1"
+	raw, err := tok.SignedString(key)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return raw
+}
+
+// authorize — пользователь вошёл у провайдера: code для запроса authURL. В ID-токен
+// попадают nonce запроса и claims (поверх стандартных).
+func (p *testIssuer) authorize(authURL string, claims jwt.MapClaims) (code, state string) {
+	p.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("client_id") != testClientID || q.Get("code_challenge_method") != "S256" ||
+		q.Get("code_challenge") == "" || q.Get("nonce") == "" || !strings.Contains(q.Get("scope"), "openid") {
+		p.t.Fatalf("unexpected authorization request %s", authURL)
+	}
+	full := jwt.MapClaims{
+		"iss": p.srv.URL, "aud": testClientID, "sub": "u-1", "nonce": q.Get("nonce"),
+		"iat": time.Now().Unix(), "exp": time.Now().Add(time.Minute).Unix(),
+		"email": "Alice@Example.com", "email_verified": true, "preferred_username": "alice",
+	}
+	for k, v := range claims {
+		full[k] = v
+	}
+	code = "code-" + q.Get("state")[:8]
+	p.mu.Lock()
+	p.codes[code] = grant{challenge: q.Get("code_challenge"), redirectURI: q.Get("redirect_uri"), claims: full}
+	p.mu.Unlock()
+	return code, q.Get("state")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// memChallenges — auth_challenges в памяти
+type memChallenges struct {
+	mu   sync.Mutex
+	list map[string]*challenge.Challenge
+}
+
+func (m *memChallenges) Issue(kind string, userID int, data string, ttl time.Duration) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.list[token] = &challenge.Challenge{Kind: kind, UserID: userID, Data: data, ExpiresAt: time.Now().Add(ttl)}
+	return token, nil
+}
+
+func (m *memChallenges) Consume(kind, token string) (*challenge.Challenge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.list[token]
+	if !ok || c.Kind != kind || time.Now().After(c.ExpiresAt) {
+		return nil, challenge.ErrInvalidChallenge
+	}
+	delete(m.list, token)
+	return c, nil
+}
+
+func newTestService(t *testing.T, p *testIssuer, users user.External) *Service {
+	t.Helper()
+	return NewService(&memChallenges{list: map[string]*challenge.Challenge{}}, users,
+		usertest.Roles{rbac.RoleAdmin: true, rbac.RoleOperator: true}, Config{
+			Issuer:       p.srv.URL,
+			ClientID:     testClientID,
+			ClientSecret: testClientSecret,
+			RedirectURL:  "https://panel.example/api/auth/oidc/callback",
+			Scopes:       []string{"profile", "email"},
+			GroupsClaim:  "groups",
+			RoleMapping:  []rbac.GroupRole{{Group: "panel-admins", Role: rbac.RoleAdmin}, {Group: "ops", Role: rbac.RoleOperator}},
+			FrontendURL:  "https://panel.example/",
+		})
+}
+
+func TestCallback(t *testing.T) {
+	ctx := context.Background()
+	p := newTestIssuer(t)
+	s := newTestService(t, p, usertest.NewUsers())
+
+	authURL, err := s.AuthURL(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := p.authorize(authURL, jwt.MapClaims{"groups": []string{"ops", "staff"}, "amr": []string{"pwd", "otp"}})
+	id, err := s.Callback(ctx, code, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Issuer != p.srv.URL || id.Subject != "u-1" || id.Email != "alice@example.com" || !id.EmailVerified ||
+		id.Username != "alice" || !id.MFA || strings.Join(id.Groups, ",") != "ops,staff" {
+		t.Fatalf("identity = %+v", *id)
+	}
+
+	// state одноразовый: повтор возврата от провайдера отклоняется
+	if _, err := s.Callback(ctx, code, state); !errors.Is(err, challenge.ErrInvalidChallenge) {
+		t.Fatalf("replayed state: %v; want ErrInvalidChallenge", err)
+	}
+}
+
+var errInvalidGrant = errors.New("invalid_grant")
+
+func TestCallbackRejects(t *testing.T) {
+	ctx := context.Background()
+	p := newTestIssuer(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		run  func(s *Service) error
+		want error
+	}{
+		{"unknown state", func(s *Service) error {
+			authURL, _ := s.AuthURL(ctx)
+			code, _ := p.authorize(authURL, nil)
+			_, err := s.Callback(ctx, code, "forged-state")
+			return err
+		}, challenge.ErrInvalidChallenge},
+		{"code of another request", func(s *Service) error {
+			// Подброшенный code от чужого запроса: verifier state не подходит к его challenge
+			victimURL, _ := s.AuthURL(ctx)
+			attackerURL, _ := s.AuthURL(ctx)
+			code, _ := p.authorize(attackerURL, nil)
+			_, state := p.authorize(victimURL, nil)
+			_, err := s.Callback(ctx, code, state)
+			var rerr *oauth2.RetrieveError
+			if errors.As(err, &rerr) && rerr.ErrorCode == "invalid_grant" {
+				return errInvalidGrant
+			}
+			return err
+		}, errInvalidGrant},
+		{"nonce mismatch", func(s *Service) error {
+			authURL, _ := s.AuthURL(ctx)
+			code, state := p.authorize(authURL, jwt.MapClaims{"nonce": "replayed-nonce"})
+			_, err := s.Callback(ctx, code, state)
+			return err
+		}, ErrInvalidToken},
+		{"wrong audience", func(s *Service) error {
+			authURL, _ := s.AuthURL(ctx)
+			code, state := p.authorize(authURL, jwt.MapClaims{"aud": "other-client"})
+			_, err := s.Callback(ctx, code, state)
+			return err
+		}, ErrInvalidToken},
+		{"expired", func(s *Service) error {
+			authURL, _ := s.AuthURL(ctx)
+			code, state := p.authorize(authURL, jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})
+			_, err := s.Callback(ctx, code, state)
+			return err
+		}, ErrInvalidToken},
+		{"foreign signing key", func(s *Service) error {
+			authURL, _ := s.AuthURL(ctx)
+			code, state := p.authorize(authURL, nil)
+			p.mu.Lock()
+			key := p.key
+			p.key = otherKey
+			p.mu.Unlock()
+			defer func() {
+				p.mu.Lock()
+				p.key = key
+				p.mu.Unlock()
+			}()
+			_, err := s.Callback(ctx, code, state)
+			return err
+		}, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run(newTestService(t, p, usertest.NewUsers()))
+			if err == nil {
+				t.Fatal("Callback succeeded")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("Callback = %v; want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvision(t *testing.T) {
+	p := newTestIssuer(t)
+	users := usertest.NewUsers()
+	s := newTestService(t, p, users)
+	id := &Identity{Issuer: p.srv.URL, Subject: "u-1", Email: "alice@example.com", EmailVerified: true, Username: "alice", Groups: []string{"ops"}}
+
+	// Первый вход создаёт пользователя с ролью по группам и привязывает учётку провайдера
+	u, err := s.Provision(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Username != "alice" || u.Role != rbac.RoleOperator || !u.EmailVerified || len(users.ByID) != 1 {
+		t.Fatalf("created user = %+v", *u)
+	}
+	// Повторный вход: тот же пользователь, роль следует за группами
+	id.Groups = []string{"staff", "panel-admins"}
+	again, err := s.Provision(id)
+	if err != nil || again.ID != u.ID || again.Role != rbac.RoleAdmin || len(users.ByID) != 1 {
+		t.Fatalf("second login = %+v, %v", again, err)
+	}
+	// Без подходящей группы и роли по умолчанию вход запрещён, роль не меняется
+	id.Groups = []string{"staff"}
+	if _, err := s.Provision(id); !errors.Is(err, ErrNoRole) {
+		t.Fatalf("no matching group: %v; want ErrNoRole", err)
+	}
+	if users.ByID[u.ID].Role != rbac.RoleAdmin {
+		t.Fatal("role changed on rejected login")
+	}
+
+	// Локальная учётка с тем же email привязывается, только если адрес подтверждён с обеих сторон
+	local, _ := users.CreateExternalUser("bob", "bob@example.com", rbac.RoleViewer)
+	bob := &Identity{Issuer: p.srv.URL, Subject: "u-2", Email: "bob@example.com", EmailVerified: true, Username: "bob", Groups: []string{"ops"}}
+	if _, err := s.Provision(bob); !errors.Is(err, user.ErrEmailTaken) {
+		t.Fatalf("unverified local email: %v; want ErrEmailTaken", err)
+	}
+	_ = users.MarkEmailVerified(local.ID)
+	linked, err := s.Provision(bob)
+	if err != nil || linked.ID != local.ID || linked.Role != rbac.RoleOperator {
+		t.Fatalf("verified local email = %+v, %v", linked, err)
+	}
+
+	s.cfg.RoleMapping = []rbac.GroupRole{{Group: "ops", Role: "missing"}}
+	if _, err := s.Provision(&Identity{Issuer: p.srv.URL, Subject: "u-3", Email: "c@example.com", Groups: []string{"ops"}}); err == nil {
+		t.Fatal("role that does not exist was assigned")
+	}
+}
+
+// TestLogin — полный вход: провайдер → пользователь с ролью → одноразовый код, который
+// обменивается на токены панели
+func TestLogin(t *testing.T) {
+	ctx := context.Background()
+	p := newTestIssuer(t)
+	users := usertest.NewUsers()
+	s := newTestService(t, p, users)
+
+	authURL, err := s.AuthURL(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := p.authorize(authURL, jwt.MapClaims{"groups": "panel-admins", "amr": []string{"hwk"}})
+	id, err := s.Callback(ctx, code, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := s.Provision(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.IssueResult(u.ID, id.MFA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirect := s.FrontendRedirect("sso_code", result)
+	if !strings.HasPrefix(redirect, "https://panel.example/login?sso_code=") {
+		t.Fatalf("redirect = %q", redirect)
+	}
+
+	uid, mfa, err := s.Redeem(result)
+	if err != nil || uid != u.ID || !mfa || users.ByID[uid].Role != rbac.RoleAdmin {
+		t.Fatalf("Redeem = %d, %v, %v; user %+v", uid, mfa, err, users.ByID[uid])
+	}
+	if _, _, err := s.Redeem(result); !errors.Is(err, challenge.ErrInvalidChallenge) {
+		t.Fatalf("second Redeem = %v; want ErrInvalidChallenge", err)
+	}
+}
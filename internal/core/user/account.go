@@ -0,0 +1,256 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+	"net/mail"
+	"strings"
+	"unicode"
+
+	mysql "github.com/go-sql-driver/mysql"
+
+	"ospab-panel/internal/core/org"
+	"ospab-panel/internal/core/rbac"
+)
+
+var (
+	ErrInvalidUsername = errors.New("invalid_username")
+	ErrInvalidEmail    = errors.New("invalid_email")
+	ErrEmailUnchanged  = errors.New("email_unchanged")
+	ErrConfirmation    = errors.New("confirmation_mismatch")
+	ErrLastAdmin       = errors.New("last_admin")
+	ErrSoleOwner       = errors.New("sole_organization_owner")
+	ErrHasServers      = errors.New("user_has_servers")
+)
+
+func normalizeUsername(username string) (string, error) {
+	username = strings.TrimSpace(username)
+	if username == "" || len([]rune(username)) > 64 || strings.IndexFunc(username, unicode.IsSpace) >= 0 {
+		return "", ErrInvalidUsername
+	}
+	return username, nil
+}
+
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(strings.ToLower(email))
+	a, err := mail.ParseAddress(email)
+	if err != nil || a.Address != email || len(email) > 128 {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
+
+// Profile возвращает данные текущего пользователя для /api/me
+func (s *Service) Profile(userID int) (*Profile, error) {
+	u, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &Profile{User: *u, HasPassword: u.PasswordHash != ""}, nil
+}
+
+// UpdateProfile меняет изменяемые поля профиля (сейчас — логин)
+func (s *Service) UpdateProfile(userID int, req *UpdateProfileRequest) (*Profile, error) {
+	if req.Username != nil {
+		username, err := normalizeUsername(*req.Username)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.db.Exec("UPDATE users SET username = ?, updated_at = NOW() WHERE id = ?", username, userID); err != nil {
+			if me, ok := err.(*mysql.MySQLError); ok && me.Number == 1062 {
+				return nil, ErrUsernameTaken
+			}
+			return nil, err
+		}
+	}
+	return s.Profile(userID)
+}
+
+// RequestEmailChange проверяет пароль и выдаёт токен подтверждения нового адреса.
+// Адрес меняется только после перехода по ссылке (VerifyEmail).
+func (s *Service) RequestEmailChange(userID int, email, password string) (u *User, newEmail, token string, err error) {
+	u, err = s.GetUserByID(userID)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if u.PasswordHash == "" {
+		return nil, "", "", ErrExternalAccount
+	}
+	if !s.ValidatePassword(u, password) {
+		return nil, "", "", ErrInvalidPassword
+	}
+	email, err = normalizeEmail(email)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if email == u.Email {
+		return nil, "", "", ErrEmailUnchanged
+	}
+	if _, err := s.GetUserByEmail(email); err == nil {
+		return nil, "", "", ErrEmailTaken
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, "", "", err
+	}
+	token, err = s.issueToken(u.ID, TokenChangeEmail, email, VerifyEmailTTL)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return u, email, token, nil
+}
+
+// applyEmailChange переносит учётку на подтверждённый новый адрес. Ссылки из писем,
+// отправленных на прежний адрес, больше не действуют.
+func (s *Service) applyEmailChange(userID int, email string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE users SET email=?, email_verified=TRUE, updated_at=NOW() WHERE id=?`, email, userID); err != nil {
+		if me, ok := err.(*mysql.MySQLError); ok && me.Number == 1062 {
+			return ErrEmailTaken
+		}
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM user_tokens WHERE user_id=? AND used_at IS NULL`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteAccount удаляет учётную запись. Удаление не должно оставить организацию без
+// владельца или панель без администратора; личные серверы удаляются только с
+// req.DeleteServers. Серверы организаций передаются другому владельцу. Остальные
+// данные пользователя (сессии, ключи, гранты) удаляются каскадно. secretRefs — учётные
+// данные удалённых серверов: их нужно удалить из хранилища секретов (server.Service.DeleteSecrets).
+func (s *Service) DeleteAccount(userID int, req *DeleteAccountRequest) (u *User, secretRefs []string, err error) {
+	u, err = s.GetUserByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.PasswordHash != "" {
+		if !s.ValidatePassword(u, req.Password) {
+			return nil, nil, ErrInvalidPassword
+		}
+	} else if req.Confirm != u.Username {
+		// Внешняя учётка без пароля — подтверждение вводом логина
+		return nil, nil, ErrConfirmation
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+	// Блокировка строки пользователя сериализует удаление с другими изменениями учётки
+	var locked int
+	if err := tx.QueryRow(`SELECT id FROM users WHERE id=? FOR UPDATE`, userID).Scan(&locked); err != nil {
+		return nil, nil, err
+	}
+
+	if u.Role == rbac.RoleAdmin {
+		var admins int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE role=? AND id<>?`, rbac.RoleAdmin, userID).Scan(&admins); err != nil {
+			return nil, nil, err
+		}
+		if admins == 0 {
+			return nil, nil, ErrLastAdmin
+		}
+	}
+
+	// Организации, где пользователь — единственный владелец
+	rows, err := tx.Query(`SELECT m.org_id,
+			(SELECT COUNT(*) FROM organization_members o WHERE o.org_id=m.org_id AND o.user_id<>m.user_id)
+		FROM organization_members m
+		WHERE m.user_id=? AND m.role=? AND NOT EXISTS (
+			SELECT 1 FROM organization_members o WHERE o.org_id=m.org_id AND o.role=? AND o.user_id<>m.user_id)`,
+		userID, org.RoleOwner, org.RoleOwner)
+	if err != nil {
+		return nil, nil, err
+	}
+	var soloOrgs []int
+	for rows.Next() {
+		var orgID, others int
+		if err := rows.Scan(&orgID, &others); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		if others > 0 {
+			rows.Close()
+			return nil, nil, ErrSoleOwner
+		}
+		soloOrgs = append(soloOrgs, orgID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// Личные серверы и серверы организаций, где больше никого нет, удаляются вместе с учёткой
+	var servers int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM servers WHERE user_id=? AND organization_id IS NULL`, userID).Scan(&servers); err != nil {
+		return nil, nil, err
+	}
+	for _, orgID := range soloOrgs {
+		var n int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM servers WHERE organization_id=?`, orgID).Scan(&n); err != nil {
+			return nil, nil, err
+		}
+		servers += n
+	}
+	if servers > 0 && !req.DeleteServers {
+		return nil, nil, ErrHasServers
+	}
+
+	// Серверы организаций остаются в организации: ответственным становится старейший другой владелец
+	if _, err := tx.Exec(`UPDATE servers s SET s.user_id = (
+			SELECT o.user_id FROM organization_members o WHERE o.org_id=s.organization_id AND o.role=? AND o.user_id<>?
+			ORDER BY o.created_at, o.user_id LIMIT 1)
+		WHERE s.user_id=? AND s.organization_id IS NOT NULL AND EXISTS (
+			SELECT 1 FROM organization_members o WHERE o.org_id=s.organization_id AND o.role=? AND o.user_id<>?)`,
+		org.RoleOwner, userID, userID, org.RoleOwner, userID); err != nil {
+		return nil, nil, err
+	}
+	// Личные серверы удалятся каскадно вместе с пользователем
+	if secretRefs, err = serverSecretRefs(tx, `user_id=? AND organization_id IS NULL`, userID); err != nil {
+		return nil, nil, err
+	}
+	for _, orgID := range soloOrgs {
+		refs, err := serverSecretRefs(tx, `organization_id=?`, orgID)
+		if err != nil {
+			return nil, nil, err
+		}
+		secretRefs = append(secretRefs, refs...)
+		if _, err := tx.Exec(`DELETE FROM servers WHERE organization_id=?`, orgID); err != nil {
+			return nil, nil, err
+		}
+		if _, err := tx.Exec(`DELETE FROM organizations WHERE id=?`, orgID); err != nil {
+			return nil, nil, err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM users WHERE id=?`, userID); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return u, secretRefs, nil
+}
+
+// serverSecretRefs — ссылки на учётные данные серверов, выбранных условием where
+func serverSecretRefs(tx *sql.Tx, where string, arg interface{}) ([]string, error) {
+	rows, err := tx.Query(`SELECT username_enc, password_enc FROM servers WHERE `+where, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var refs []string
+	for rows.Next() {
+		var userRef, passRef string
+		if err := rows.Scan(&userRef, &passRef); err != nil {
+			return nil, err
+		}
+		refs = append(refs, userRef, passRef)
+	}
+	return refs, rows.Err()
+}
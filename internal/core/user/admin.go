@@ -0,0 +1,129 @@
+package user
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"ospab-panel/internal/core/rbac"
+)
+
+// PasswordSetupTTL — срок ссылки установки пароля, выданной администратором
+const PasswordSetupTTL = 48 * time.Hour
+
+var ErrAccountDisabled = errors.New("account_disabled")
+
+const maxPerPage = 100
+
+// ListUsers — пользователи по фильтру, по возрастанию id
+func (s *Service) ListUsers(f ListFilter) (*UserPage, error) {
+	if f.PerPage <= 0 || f.PerPage > maxPerPage {
+		f.PerPage = 25
+	}
+	if f.Page <= 0 {
+		f.Page = 1
+	}
+	where := []string{"1=1"}
+	var args []interface{}
+	if q := strings.TrimSpace(f.Query); q != "" {
+		like := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(q)) + "%"
+		where = append(where, "(LOWER(username) LIKE ? OR email LIKE ?)")
+		args = append(args, like, like)
+	}
+	if f.Role != "" {
+		where = append(where, "role = ?")
+		args = append(args, f.Role)
+	}
+	if f.Disabled != nil {
+		where = append(where, "disabled = ?")
+		args = append(args, *f.Disabled)
+	}
+	cond := strings.Join(where, " AND ")
+
+	page := &UserPage{Items: []*Profile{}, Page: f.Page, PerPage: f.PerPage}
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM users WHERE "+cond, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query("SELECT "+userColumns+" FROM users WHERE "+cond+" ORDER BY id LIMIT ? OFFSET ?",
+		append(args, f.PerPage, (f.Page-1)*f.PerPage)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, &Profile{User: *u, HasPassword: u.PasswordHash != ""})
+	}
+	return page, rows.Err()
+}
+
+// AdminCreateUser создаёт пользователя с заданной ролью (существование роли проверяет
+// вызывающий код). Без пароля в req ставится случайный, а второе значение — токен
+// ссылки установки пароля для письма.
+func (s *Service) AdminCreateUser(req *AdminCreateRequest) (*User, string, error) {
+	username, err := normalizeUsername(req.Username)
+	if err != nil {
+		return nil, "", err
+	}
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		return nil, "", err
+	}
+	role := req.Role
+	if role == "" {
+		role = rbac.DefaultRole
+	}
+	password := req.Password
+	if password == "" {
+		if password, err = randomSecret(32); err != nil {
+			return nil, "", err
+		}
+	} else if err := s.policy.Check(password, username, email); err != nil {
+		return nil, "", err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, "", err
+	}
+	u, err := s.insertUser(username, email, hash, role)
+	if err != nil || req.Password != "" {
+		return u, "", err
+	}
+	token, err := s.issueToken(u.ID, TokenResetPassword, u.Email, PasswordSetupTTL)
+	return u, token, err
+}
+
+// SetDisabled отключает или включает учётную запись. Завершение сессий — на вызывающем коде.
+func (s *Service) SetDisabled(userID int, disabled bool) (*User, error) {
+	if _, err := s.db.Exec("UPDATE users SET disabled = ?, updated_at = NOW() WHERE id = ?", disabled, userID); err != nil {
+		return nil, err
+	}
+	return s.GetUserByID(userID)
+}
+
+// ForcePasswordReset заменяет пароль случайным и выдаёт токен ссылки для установки
+// нового. Завершение сессий — на вызывающем коде.
+func (s *Service) ForcePasswordReset(userID int) (*User, string, error) {
+	u, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.PasswordHash == "" {
+		return nil, "", ErrExternalAccount
+	}
+	token, err := s.issueToken(u.ID, TokenResetPassword, u.Email, PasswordSetupTTL)
+	if err != nil {
+		return nil, "", err
+	}
+	password, err := randomSecret(32)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.storePassword(u.ID, password); err != nil {
+		return nil, "", err
+	}
+	return u, token, nil
+}
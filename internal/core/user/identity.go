@@ -0,0 +1,36 @@
+package user
+
+import "time"
+
+// External — операции над пользователями, входящими через внешних провайдеров
+// (SSO, LDAP); реализуется *Service
+type External interface {
+	IdentityUser(issuer, subject, email string) (*User, error)
+	LinkIdentity(userID int, issuer, subject, email string) error
+	GetUserByEmail(email string) (*User, error)
+	CreateExternalUser(username, email, role string) (*User, error)
+	SetRole(userID int, role string) (*User, error)
+	MarkEmailVerified(userID int) error
+}
+
+// IdentityUser — пользователь, привязанный к учётной записи внешнего провайдера
+// (sql.ErrNoRows — привязки нет). Отмечает время входа и актуальный email.
+func (s *Service) IdentityUser(issuer, subject, email string) (*User, error) {
+	var uid int
+	err := s.db.QueryRow(`SELECT user_id FROM user_identities WHERE issuer=? AND subject=?`, issuer, subject).Scan(&uid)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`UPDATE user_identities SET email=?, last_login_at=? WHERE issuer=? AND subject=?`, email, time.Now(), issuer, subject); err != nil {
+		return nil, err
+	}
+	return s.GetUserByID(uid)
+}
+
+// LinkIdentity привязывает учётную запись внешнего провайдера к пользователю панели
+func (s *Service) LinkIdentity(userID int, issuer, subject, email string) error {
+	now := time.Now()
+	_, err := s.db.Exec(`INSERT INTO user_identities (user_id,issuer,subject,email,created_at,last_login_at) VALUES (?,?,?,?,?,?)`,
+		userID, issuer, subject, email, now, now)
+	return err
+}
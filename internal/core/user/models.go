@@ -5,13 +5,16 @@ import (
 )
 
 type User struct {
-	ID           int       `json:"id" db:"id"`
-	Username     string    `json:"username" db:"username"`
-	Email        string    `json:"email" db:"email"`
-	PasswordHash string    `json:"-" db:"password_hash"`
-	PasswordSalt string    `json:"-" db:"password_salt"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	ID            int       `json:"id" db:"id"`
+	Username      string    `json:"username" db:"username"`
+	Email         string    `json:"email" db:"email"`
+	EmailVerified bool      `json:"email_verified" db:"email_verified"`
+	PasswordHash  string    `json:"-" db:"password_hash"`
+	Role          string    `json:"role" db:"role"`
+	TOTPEnabled   bool      `json:"totp_enabled" db:"totp_enabled"`
+	Disabled      bool      `json:"disabled" db:"disabled"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type LoginRequest struct {
@@ -20,6 +23,77 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // секунды до истечения access-токена
+	User         User   `json:"user"`
+	// MFASetupRequired — для роли пользователя 2FA обязательна, но ещё не включена
+	MFASetupRequired bool `json:"mfa_setup_required,omitempty"`
+}
+
+// MFAChallengeResponse — ответ на вход по паролю при включённой 2FA
+type MFAChallengeResponse struct {
+	MFARequired    bool   `json:"mfa_required"`
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresIn      int    `json:"expires_in"`
+}
+
+type MFAVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"` // код TOTP или код восстановления
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// Profile — текущий пользователь для /api/me
+type Profile struct {
+	User
+	// HasPassword — есть локальный пароль (у учёток SSO/LDAP его нет)
+	HasPassword bool `json:"has_password"`
+}
+
+type UpdateProfileRequest struct {
+	Username *string `json:"username,omitempty"`
+}
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// DeleteAccountRequest — подтверждение удаления учётной записи
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+	// Confirm — логин пользователя; требуется вместо пароля у учёток без пароля
+	Confirm string `json:"confirm,omitempty"`
+	// DeleteServers — удалить личные серверы вместе с учёткой
+	DeleteServers bool `json:"delete_servers"`
+}
+
+// ListFilter — поиск и постраничный вывод пользователей для администратора
+type ListFilter struct {
+	Query    string // подстрока логина или email
+	Role     string
+	Disabled *bool
+	Page     int
+	PerPage  int
+}
+
+type UserPage struct {
+	Items   []*Profile `json:"items"`
+	Total   int        `json:"total"`
+	Page    int        `json:"page"`
+	PerPage int        `json:"per_page"`
+}
+
+// AdminCreateRequest — создание пользователя администратором. Без пароля
+// пользователь получает письмо со ссылкой для его установки.
+type AdminCreateRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password,omitempty"`
+	Role     string `json:"role,omitempty"`
 }
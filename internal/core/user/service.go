@@ -5,37 +5,48 @@ import (
 	"database/sql"
 	"encoding/base64"
 	"errors"
+	"fmt"
+	"log"
 	"strings"
 
 	mysql "github.com/go-sql-driver/mysql"
-	"golang.org/x/crypto/bcrypt"
+
+	"ospab-panel/internal/core/password"
+	"ospab-panel/internal/core/rbac"
+	"ospab-panel/internal/infra/crypto"
 )
 
 type Service struct {
-	db *sql.DB
+	db     *sql.DB
+	policy *password.Policy
+	keys   *crypto.Keyring // шифрование секретов TOTP
 }
 
 // Sentinel errors for business logic / presentation layer mapping
 var (
-	ErrUsernameTaken    = errors.New("username_taken")
-	ErrEmailTaken       = errors.New("email_taken")
-	ErrDuplicateValue   = errors.New("duplicate_value")
-	ErrPasswordTooShort = errors.New("password_too_short")
+	ErrUsernameTaken   = errors.New("username_taken")
+	ErrEmailTaken      = errors.New("email_taken")
+	ErrDuplicateValue  = errors.New("duplicate_value")
+	ErrInvalidPassword = errors.New("invalid_current_password")
 )
 
-func NewService(db *sql.DB) *Service {
-	return &Service{db: db}
+func NewService(db *sql.DB, policy *password.Policy, keys *crypto.Keyring) *Service {
+	return &Service{db: db, policy: policy, keys: keys}
 }
 
-func (s *Service) GetUserByUsername(username string) (*User, error) {
+const userColumns = "id, username, email, email_verified, password_hash, role, totp_enabled, disabled, created_at, updated_at"
+
+func scanUser(sc interface{ Scan(...any) error }) (*User, error) {
 	user := &User{}
-	query := "SELECT id, username, email, password_hash, password_salt, created_at, updated_at FROM users WHERE username = ?"
-	err := s.db.QueryRow(query, username).Scan(
+	err := sc.Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
+		&user.EmailVerified,
 		&user.PasswordHash,
-		&user.PasswordSalt,
+		&user.Role,
+		&user.TOTPEnabled,
+		&user.Disabled,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -45,25 +56,24 @@ func (s *Service) GetUserByUsername(username string) (*User, error) {
 	return user, nil
 }
 
+func (s *Service) getUser(where string, arg interface{}) (*User, error) {
+	return scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE "+where+" = ?", arg))
+}
+
+func (s *Service) GetUserByUsername(username string) (*User, error) {
+	return s.getUser("username", username)
+}
+
 func (s *Service) GetUserByID(id int) (*User, error) {
-	user := &User{}
-	query := "SELECT id, username, email, password_hash, password_salt, created_at, updated_at FROM users WHERE id = ?"
-	err := s.db.QueryRow(query, id).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.PasswordHash,
-		&user.PasswordSalt,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return user, nil
+	return s.getUser("id", id)
+}
+
+func (s *Service) GetUserByEmail(email string) (*User, error) {
+	return s.getUser("email", strings.TrimSpace(strings.ToLower(email)))
 }
 
-func generateSalt(size int) (string, error) {
+// randomSecret — случайная строка из size байт (служебные пароли, которые никто не знает)
+func randomSecret(size int) (string, error) {
 	b := make([]byte, size)
 	if _, err := rand.Read(b); err != nil {
 		return "", err
@@ -71,17 +81,36 @@ func generateSalt(size int) (string, error) {
 	return base64.RawStdEncoding.EncodeToString(b), nil
 }
 
-func hashPassword(password, salt string) (string, error) {
-	// Добавляем соль в пароль (простой конкатенационный подход, bcrypt сам добавляет свою соль
-	// но мы усиливаем связку пользовательской солью)
-	combined := password + ":" + salt
-	h, err := bcrypt.GenerateFromPassword([]byte(combined), bcrypt.DefaultCost)
-	return string(h), err
+func hashPassword(plain string) (string, error) {
+	return password.Hash(plain)
 }
 
-func (s *Service) ValidatePassword(u *User, password string) bool {
-	combined := password + ":" + u.PasswordSalt
-	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(combined)) == nil
+// ValidatePassword проверяет пароль. Хэш устаревшей схемы после успешной проверки
+// заменяется на текущий.
+func (s *Service) ValidatePassword(u *User, plain string) bool {
+	ok, rehash, err := password.Verify(plain, u.PasswordHash)
+	if err != nil {
+		log.Printf("Password hash of user %d: %v", u.ID, err)
+		return false
+	}
+	if ok && rehash {
+		s.upgradeHash(u, plain)
+	}
+	return ok
+}
+
+// upgradeHash перезаписывает хэш, только если его не сменили параллельно
+func (s *Service) upgradeHash(u *User, plain string) {
+	hash, err := hashPassword(plain)
+	if err != nil {
+		log.Printf("Rehash password of user %d: %v", u.ID, err)
+		return
+	}
+	if _, err := s.db.Exec("UPDATE users SET password_hash = ? WHERE id = ? AND password_hash = ?", hash, u.ID, u.PasswordHash); err != nil {
+		log.Printf("Rehash password of user %d: %v", u.ID, err)
+		return
+	}
+	u.PasswordHash = hash
 }
 
 func (s *Service) CreateUser(username, email, password string) (*User, error) {
@@ -90,21 +119,46 @@ func (s *Service) CreateUser(username, email, password string) (*User, error) {
 	if username == "" || email == "" || password == "" {
 		return nil, errors.New("invalid empty fields")
 	}
-	if len(password) < 8 { // минимальная длина
-		return nil, ErrPasswordTooShort
+	if err := s.policy.Check(password, username, email); err != nil {
+		return nil, err
 	}
 
-	salt, err := generateSalt(16)
+	hash, err := hashPassword(password)
 	if err != nil {
 		return nil, err
 	}
-	hash, err := hashPassword(password, salt)
+
+	// Первый зарегистрированный пользователь становится администратором. Проверка и
+	// вставка — один оператор: при одновременной регистрации на пустой установке
+	// администратором станет только один.
+	result, err := s.execInsert(`INSERT INTO users (username, email, password_hash, role, created_at, updated_at)
+		SELECT ?, ?, ?, ?, NOW(), NOW() FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM users)`,
+		username, email, hash, rbac.RoleAdmin)
+	if me, ok := err.(*mysql.MySQLError); ok && me.Number == 1213 {
+		// Взаимоблокировка с параллельной вставкой в пустую таблицу: администратором стал другой
+		return s.insertUser(username, email, hash, rbac.DefaultRole)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if n, _ := result.RowsAffected(); n == 1 {
+		return s.insertedUser(result)
+	}
+	return s.insertUser(username, email, hash, rbac.DefaultRole)
+}
 
-	query := "INSERT INTO users (username, email, password_hash, password_salt, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW())"
-	result, err := s.db.Exec(query, username, email, hash, salt)
+func (s *Service) insertUser(username, email, hash, role string) (*User, error) {
+	result, err := s.execInsert("INSERT INTO users (username, email, password_hash, role, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW())",
+		username, email, hash, role)
+	if err != nil {
+		return nil, err
+	}
+	return s.insertedUser(result)
+}
+
+// execInsert выполняет вставку в users; дубли логина и email — ErrUsernameTaken/ErrEmailTaken
+func (s *Service) execInsert(query string, args ...interface{}) (sql.Result, error) {
+	result, err := s.db.Exec(query, args...)
 	if err != nil {
 		// Обработка дублей (email/username)
 		if me, ok := err.(*mysql.MySQLError); ok && me.Number == 1062 {
@@ -119,9 +173,87 @@ func (s *Service) CreateUser(username, email, password string) (*User, error) {
 		}
 		return nil, err
 	}
+	return result, nil
+}
+
+func (s *Service) insertedUser(result sql.Result) (*User, error) {
 	id, err := result.LastInsertId()
 	if err != nil {
 		return nil, err
 	}
 	return s.GetUserByID(int(id))
 }
+
+// CreateExternalUser создаёт пользователя внешнего провайдера (SSO) без пароля:
+// пустой хэш не совпадёт ни с одним паролем. Занятый логин получает суффикс.
+func (s *Service) CreateExternalUser(username, email, role string) (*User, error) {
+	username = strings.TrimSpace(username)
+	email = strings.TrimSpace(strings.ToLower(email))
+	if username == "" || email == "" {
+		return nil, errors.New("invalid empty fields")
+	}
+	if r := []rune(username); len(r) > 60 {
+		username = string(r[:60])
+	}
+	candidate := username
+	for i := 2; ; i++ {
+		u, err := s.insertUser(candidate, email, "", role)
+		if !errors.Is(err, ErrUsernameTaken) || i > 20 {
+			return u, err
+		}
+		candidate = fmt.Sprintf("%s-%d", username, i)
+	}
+}
+
+// SetRole назначает пользователю роль (существование роли проверяет вызывающий код)
+func (s *Service) SetRole(userID int, role string) (*User, error) {
+	res, err := s.db.Exec("UPDATE users SET role = ?, updated_at = NOW() WHERE id = ?", role, userID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := s.GetUserByID(userID); err != nil {
+			return nil, err
+		}
+	}
+	return s.GetUserByID(userID)
+}
+
+// ChangePassword меняет пароль после проверки текущего.
+// Завершение сессий пользователя — на вызывающем коде.
+func (s *Service) ChangePassword(userID int, current, newPassword string) error {
+	u, err := s.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if u.PasswordHash == "" {
+		return ErrExternalAccount
+	}
+	if !s.ValidatePassword(u, current) {
+		return ErrInvalidPassword
+	}
+	return s.setPassword(u, newPassword)
+}
+
+// PasswordRequirements — действующая политика паролей
+func (s *Service) PasswordRequirements() password.Requirements {
+	return s.policy.Requirements()
+}
+
+// setPassword проверяет пароль по политике и сохраняет его
+func (s *Service) setPassword(u *User, password string) error {
+	if err := s.policy.Check(password, u.Username, u.Email); err != nil {
+		return err
+	}
+	return s.storePassword(u.ID, password)
+}
+
+// storePassword сохраняет пароль без проверки политики (случайные служебные пароли)
+func (s *Service) storePassword(userID int, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("UPDATE users SET password_hash = ?, updated_at = NOW() WHERE id = ?", hash, userID)
+	return err
+}
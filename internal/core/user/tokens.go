@@ -0,0 +1,187 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"ospab-panel/pkg/auth"
+)
+
+// Назначение одноразовых токенов из писем
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+	TokenChangeEmail   = "change_email" // подтверждение нового адреса
+)
+
+const (
+	VerifyEmailTTL   = 48 * time.Hour
+	ResetPasswordTTL = time.Hour
+	// tokenResendInterval — не чаще одного письма одного вида в минуту на пользователя
+	tokenResendInterval = time.Minute
+)
+
+var (
+	ErrInvalidToken    = errors.New("invalid_or_expired_token")
+	ErrTokenThrottled  = errors.New("token_recently_sent")
+	ErrExternalAccount = errors.New("external_account")
+)
+
+// issueToken выдаёт токен (в БД — только SHA-256); прежние неиспользованные токены
+// того же назначения аннулируются, действует только последнее письмо
+func (s *Service) issueToken(userID int, purpose, email string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	var recent int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM user_tokens WHERE user_id=? AND purpose=? AND created_at > ?`,
+		userID, purpose, now.Add(-tokenResendInterval)).Scan(&recent); err != nil {
+		return "", err
+	}
+	if recent > 0 {
+		return "", ErrTokenThrottled
+	}
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM user_tokens WHERE user_id=? AND purpose=? AND used_at IS NULL`, userID, purpose); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(`INSERT INTO user_tokens (user_id,purpose,token_hash,email,expires_at,created_at) VALUES (?,?,?,?,?,?)`,
+		userID, purpose, hash, email, now.Add(ttl), now); err != nil {
+		return "", err
+	}
+	return token, tx.Commit()
+}
+
+// consumeToken погашает токен одного из назначений атомарно: повторное предъявление
+// получит ErrInvalidToken
+func (s *Service) consumeToken(token string, purposes ...string) (userID int, email, purpose string, err error) {
+	hash := auth.HashToken(token)
+	now := time.Now()
+	args := []interface{}{now, hash, now}
+	for _, p := range purposes {
+		args = append(args, p)
+	}
+	res, err := s.db.Exec(`UPDATE user_tokens SET used_at=? WHERE token_hash=? AND used_at IS NULL AND expires_at > ?
+		AND purpose IN (?`+strings.Repeat(",?", len(purposes)-1)+`)`, args...)
+	if err != nil {
+		return 0, "", "", err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, "", "", ErrInvalidToken
+	}
+	err = s.db.QueryRow(`SELECT user_id,email,purpose FROM user_tokens WHERE token_hash=?`, hash).Scan(&userID, &email, &purpose)
+	return userID, email, purpose, err
+}
+
+// IssueEmailVerification — токен подтверждения текущего email пользователя
+func (s *Service) IssueEmailVerification(u *User) (string, error) {
+	return s.issueToken(u.ID, TokenVerifyEmail, u.Email, VerifyEmailTTL)
+}
+
+// VerifyEmail подтверждает адрес, на который было отправлено письмо: текущий или
+// новый при смене email. Если email с тех пор сменился, токен недействителен.
+func (s *Service) VerifyEmail(token string) (*User, error) {
+	userID, email, purpose, err := s.consumeToken(token, TokenVerifyEmail, TokenChangeEmail)
+	if err != nil {
+		return nil, err
+	}
+	if purpose == TokenChangeEmail {
+		if err := s.applyEmailChange(userID, email); err != nil {
+			return nil, err
+		}
+		return s.GetUserByID(userID)
+	}
+	res, err := s.db.Exec(`UPDATE users SET email_verified=TRUE, updated_at=NOW() WHERE id=? AND email=?`, userID, email)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// Либо адрес сменился, либо уже был подтверждён
+		u, err := s.GetUserByID(userID)
+		if err != nil {
+			return nil, err
+		}
+		if !u.EmailVerified || !strings.EqualFold(u.Email, email) {
+			return nil, ErrInvalidToken
+		}
+		return u, nil
+	}
+	return s.GetUserByID(userID)
+}
+
+// IssuePasswordReset — пользователь и токен сброса пароля для email.
+// sql.ErrNoRows — адрес не зарегистрирован; ErrExternalAccount — вход только через SSO/LDAP;
+// ErrAccountDisabled — учётная запись отключена администратором.
+func (s *Service) IssuePasswordReset(email string) (*User, string, error) {
+	u, err := s.GetUserByEmail(email)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.PasswordHash == "" {
+		return nil, "", ErrExternalAccount
+	}
+	if u.Disabled {
+		return nil, "", ErrAccountDisabled
+	}
+	token, err := s.issueToken(u.ID, TokenResetPassword, u.Email, ResetPasswordTTL)
+	if err != nil {
+		return nil, "", err
+	}
+	return u, token, nil
+}
+
+// ResetPassword задаёт новый пароль по токену из письма. Сброс доказывает владение
+// адресом, поэтому email отмечается подтверждённым. Завершение сессий — на вызывающем коде.
+func (s *Service) ResetPassword(token, newPassword string) (*User, error) {
+	// Пароль проверяется до погашения токена: ошибка ввода не сжигает письмо
+	var owner int
+	err := s.db.QueryRow(`SELECT user_id FROM user_tokens WHERE token_hash=? AND purpose=? AND used_at IS NULL AND expires_at > ?`,
+		auth.HashToken(token), TokenResetPassword, time.Now()).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	} else if err != nil {
+		return nil, err
+	}
+	u, err := s.GetUserByID(owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.policy.Check(newPassword, u.Username, u.Email); err != nil {
+		return nil, err
+	}
+	userID, email, _, err := s.consumeToken(token, TokenResetPassword)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storePassword(userID, newPassword); err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`UPDATE users SET email_verified=TRUE WHERE id=? AND email=?`, userID, email); err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`DELETE FROM user_tokens WHERE user_id=? AND purpose=? AND used_at IS NULL`, userID, TokenResetPassword); err != nil {
+		return nil, err
+	}
+	return s.GetUserByID(userID)
+}
+
+// PurgeExpiredTokens удаляет истёкшие и использованные токены
+func (s *Service) PurgeExpiredTokens() error {
+	now := time.Now()
+	_, err := s.db.Exec(`DELETE FROM user_tokens WHERE expires_at < ? OR (used_at IS NOT NULL AND used_at < ?)`, now, now.Add(-24*time.Hour))
+	return err
+}
+
+// MarkEmailVerified — адрес подтверждён внешним источником (SSO с email_verified, каталог LDAP)
+func (s *Service) MarkEmailVerified(userID int) error {
+	_, err := s.db.Exec(`UPDATE users SET email_verified=TRUE WHERE id=?`, userID)
+	return err
+}
@@ -0,0 +1,236 @@
+package user
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ospab-panel/internal/infra/crypto"
+	"ospab-panel/pkg/auth"
+)
+
+// TOTPIssuer — имя панели в приложении-аутентификаторе
+const TOTPIssuer = "OSPAB Panel"
+
+// RecoveryCodeCount — число одноразовых кодов восстановления
+const RecoveryCodeCount = 10
+
+var (
+	ErrTOTPAlreadyEnabled = errors.New("totp_already_enabled")
+	ErrTOTPNotEnrolled    = errors.New("totp_not_enrolled")
+	ErrInvalidCode        = errors.New("invalid_code")
+)
+
+// TOTPEnrollment — данные для подключения аутентификатора
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"` // для QR-кода
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type DisableTOTPRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"` // код TOTP или код восстановления
+}
+
+// totpAAD привязывает секрет к пользователю: перенесённый в чужую запись секрет
+// (например, свой — администратору) не расшифруется
+func totpAAD(userID int) []byte {
+	return crypto.AAD("users", "totp_secret_enc", userID)
+}
+
+// EnrollTOTP создаёт новый секрет (2FA включается только после EnableTOTP)
+func (s *Service) EnrollTOTP(userID int) (*TOTPEnrollment, error) {
+	u, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.TOTPEnabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	enc, err := s.keys.Encrypt(secret, totpAAD(userID))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec("UPDATE users SET totp_secret_enc = ?, totp_last_step = 0, updated_at = NOW() WHERE id = ?", enc, userID); err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{Secret: secret, URI: auth.TOTPURI(TOTPIssuer, u.Username, secret)}, nil
+}
+
+// EnableTOTP подтверждает подключение кодом из приложения и выдаёт коды восстановления
+func (s *Service) EnableTOTP(userID int, code string) ([]string, error) {
+	var enabled bool
+	var enc sql.NullString
+	if err := s.db.QueryRow("SELECT totp_enabled, totp_secret_enc FROM users WHERE id = ?", userID).Scan(&enabled, &enc); err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if !enc.Valid || enc.String == "" {
+		return nil, ErrTOTPNotEnrolled
+	}
+	secret, err := s.keys.Decrypt(enc.String, totpAAD(userID))
+	if err != nil {
+		return nil, err
+	}
+	step, ok := auth.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	if _, err := s.db.Exec("UPDATE users SET totp_enabled = 1, totp_last_step = ?, updated_at = NOW() WHERE id = ?", step, userID); err != nil {
+		return nil, err
+	}
+	return s.RegenerateRecoveryCodes(userID)
+}
+
+// DisableTOTP отключает 2FA и удаляет коды восстановления
+func (s *Service) DisableTOTP(userID int) error {
+	if _, err := s.db.Exec("UPDATE users SET totp_enabled = 0, totp_secret_enc = NULL, totp_last_step = 0, updated_at = NOW() WHERE id = ?", userID); err != nil {
+		return err
+	}
+	_, err := s.db.Exec("DELETE FROM user_recovery_codes WHERE user_id = ?", userID)
+	return err
+}
+
+// VerifySecondFactor принимает код TOTP или код восстановления.
+// Один и тот же код TOTP повторно не принимается, код восстановления погашается.
+func (s *Service) VerifySecondFactor(userID int, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) != auth.TOTPDigits {
+		return s.useRecoveryCode(userID, code)
+	}
+	var enc sql.NullString
+	var enabled bool
+	if err := s.db.QueryRow("SELECT totp_enabled, totp_secret_enc FROM users WHERE id = ?", userID).Scan(&enabled, &enc); err != nil {
+		return err
+	}
+	if !enabled || !enc.Valid {
+		return ErrTOTPNotEnrolled
+	}
+	secret, err := s.keys.Decrypt(enc.String, totpAAD(userID))
+	if err != nil {
+		return err
+	}
+	step, ok := auth.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidCode
+	}
+	res, err := s.db.Exec("UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?", step, userID, step)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidCode // код уже использован
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes заменяет коды восстановления новыми (показываются один раз)
+func (s *Service) RegenerateRecoveryCodes(userID int) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		// 80 бит: хэш без соли не перебрать даже по копии БД
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		c := strings.ToLower(base32.StdEncoding.EncodeToString(b)) // 16 символов
+		code := c[:4] + "-" + c[4:8] + "-" + c[8:12] + "-" + c[12:]
+		if _, err := tx.Exec("INSERT INTO user_recovery_codes (user_id, code_hash, created_at) VALUES (?, ?, NOW())", userID, auth.HashToken(normalizeRecoveryCode(code))); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RecoveryCodesLeft — число неиспользованных кодов восстановления
+func (s *Service) RecoveryCodesLeft(userID int) (int, error) {
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = ? AND used_at IS NULL", userID).Scan(&n)
+	return n, err
+}
+
+func (s *Service) useRecoveryCode(userID int, code string) error {
+	res, err := s.db.Exec("UPDATE user_recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+		time.Now(), userID, auth.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// RotateTOTPSecrets перешифровывает секреты TOTP активным ключом с привязкой к пользователю. Возвращает число
+// перешифрованных секретов; ошибки по отдельным пользователям не прерывают обход.
+func (s *Service) RotateTOTPSecrets() (int, error) {
+	rows, err := s.db.Query("SELECT id, totp_secret_enc FROM users WHERE totp_secret_enc IS NOT NULL AND totp_secret_enc <> ''")
+	if err != nil {
+		return 0, err
+	}
+	encrypted := map[int]string{}
+	for rows.Next() {
+		var id int
+		var enc string
+		if err := rows.Scan(&id, &enc); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if s.keys.NeedsRotation(enc) {
+			encrypted[id] = enc
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	var errs []error
+	for id, enc := range encrypted {
+		next, err := s.keys.Reencrypt(enc, totpAAD(id))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", id, err))
+			continue
+		}
+		res, err := s.db.Exec("UPDATE users SET totp_secret_enc = ? WHERE id = ? AND totp_secret_enc = ?", next, id, enc)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", id, err))
+			continue
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			rotated++
+		}
+	}
+	return rotated, errors.Join(errs...)
+}
@@ -0,0 +1,63 @@
+// Package usertest — хранилища пользователей и ролей в памяти для тестов
+// внешних провайдеров входа
+package usertest
+
+import (
+	"database/sql"
+
+	"ospab-panel/internal/core/user"
+)
+
+// Users — users и user_identities в памяти (user.External)
+type Users struct {
+	ByID map[int]*user.User
+	// Identities — "issuer|subject" → ID пользователя
+	Identities map[string]int
+}
+
+func NewUsers() *Users {
+	return &Users{ByID: map[int]*user.User{}, Identities: map[string]int{}}
+}
+
+func (m *Users) IdentityUser(issuer, subject, _ string) (*user.User, error) {
+	id, ok := m.Identities[issuer+"|"+subject]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return m.ByID[id], nil
+}
+
+func (m *Users) LinkIdentity(userID int, issuer, subject, _ string) error {
+	m.Identities[issuer+"|"+subject] = userID
+	return nil
+}
+
+func (m *Users) GetUserByEmail(email string) (*user.User, error) {
+	for _, u := range m.ByID {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *Users) CreateExternalUser(username, email, role string) (*user.User, error) {
+	u := &user.User{ID: len(m.ByID) + 1, Username: username, Email: email, Role: role}
+	m.ByID[u.ID] = u
+	return u, nil
+}
+
+func (m *Users) SetRole(userID int, role string) (*user.User, error) {
+	m.ByID[userID].Role = role
+	return m.ByID[userID], nil
+}
+
+func (m *Users) MarkEmailVerified(userID int) error {
+	m.ByID[userID].EmailVerified = true
+	return nil
+}
+
+// Roles — существующие роли (rbac.Roles)
+type Roles map[string]bool
+
+func (r Roles) Exists(role string) bool { return r[role] }
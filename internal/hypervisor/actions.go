@@ -0,0 +1,42 @@
+package hypervisor
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Словарь действий над инстансами. Каждый бэкенд сообщает, какие из них он
+// поддерживает для данного типа инстанса, через SupportedActions.
+const (
+	ActionStart     = "start"
+	ActionStop      = "stop"     // жёсткое выключение питания
+	ActionShutdown  = "shutdown" // штатное завершение (ACPI / init в контейнере)
+	ActionRestart   = "restart"
+	ActionReset     = "reset" // жёсткий сброс без завершения ОС
+	ActionSuspend   = "suspend"
+	ActionResume    = "resume"
+	ActionHibernate = "hibernate" // сохранение памяти на диск и выключение
+	ActionSnapshot  = "snapshot"
+	ActionRollback  = "rollback" // откат к снимку
+	ActionReinstall = "reinstall"
+	ActionDelete    = "delete"
+)
+
+// ShutdownOptions параметры штатного выключения
+type ShutdownOptions struct {
+	Timeout time.Duration // сколько ждать завершения ОС; 0 — значение гипервизора по умолчанию
+	Force   bool          // по истечении Timeout выключить принудительно
+}
+
+var ErrActionNotSupported = errors.New("action not supported")
+
+// CheckAction возвращает ErrActionNotSupported, если бэкенд не умеет action для instanceType
+func CheckAction(c HypervisorClient, instanceType, action string) error {
+	for _, a := range c.SupportedActions(instanceType) {
+		if a == action {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q for %s on %s", ErrActionNotSupported, action, instanceType, c.GetType())
+}
@@ -8,15 +8,16 @@ import (
 
 // Instance представляет VM или LXC контейнер
 type Instance struct {
-	ID     string `json:"id"`
-	Name   string `json:"name"`
-	Type   string `json:"type"` // vm или lxc
-	Status string `json:"status"`
-	CPU    int    `json:"cpu"`
-	RAM    int    `json:"ram"`
-	Disk   int    `json:"disk"`
-	OS     string `json:"os"`
-	Node   string `json:"node"`
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Type   string   `json:"type"` // vm или lxc
+	Status string   `json:"status"`
+	CPU    int      `json:"cpu"`
+	RAM    int      `json:"ram"`
+	Disk   int      `json:"disk"`
+	OS     string   `json:"os"`
+	Node   string   `json:"node"`
+	Tags   []string `json:"tags"`
 }
 
 // Server данные для подключения гипервизора
@@ -46,11 +47,25 @@ type HypervisorClient interface {
 
 	StartInstance(ctx context.Context, instanceType, instanceID string) error
 	StopInstance(ctx context.Context, instanceType, instanceID string) error
+	ShutdownInstance(ctx context.Context, instanceType, instanceID string, opts ShutdownOptions) error
 	RestartInstance(ctx context.Context, instanceType, instanceID string) error
+	ResetInstance(ctx context.Context, instanceType, instanceID string) error
+	SuspendInstance(ctx context.Context, instanceType, instanceID string) error
+	ResumeInstance(ctx context.Context, instanceType, instanceID string) error
+	HibernateInstance(ctx context.Context, instanceType, instanceID string) error
 	GetInstanceStatus(ctx context.Context, instanceType, instanceID string) (string, error)
 	GetInstanceConfig(ctx context.Context, instanceType, instanceID string) (map[string]interface{}, error)
 	DeleteInstance(ctx context.Context, instanceType, instanceID string) error
 	CreateSnapshot(ctx context.Context, instanceType, instanceID, name string) error
+	RollbackSnapshot(ctx context.Context, instanceType, instanceID, name string) error
+	ReinstallInstance(ctx context.Context, instanceType, instanceID string) error
+
+	// Защита от удаления на стороне гипервизора
+	GetProtection(ctx context.Context, instanceType, instanceID string) (bool, error)
+	SetProtection(ctx context.Context, instanceType, instanceID string, protected bool) error
+
+	// SupportedActions перечисляет Action* константы, доступные для типа инстанса
+	SupportedActions(instanceType string) []string
 
 	GetType() string
 	IsConnected() bool
@@ -77,8 +92,21 @@ func (f *HypervisorFactory) CreateClient(t string) (HypervisorClient, error) {
 	case "xen":
 		return nil, errors.New("xen client not implemented yet")
 	default:
-		return nil, fmt.Errorf("unsupported hypervisor type: %s", t)
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedHypervisor, t)
+	}
+}
+
+// Connect создаёт клиента по типу сервера и сразу подключается.
+// Ошибка подключения оборачивается в ErrConnectionFailed.
+func (f *HypervisorFactory) Connect(ctx context.Context, server *Server) (HypervisorClient, error) {
+	client, err := f.CreateClient(server.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Connect(ctx, server); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConnectionFailed, err)
 	}
+	return client, nil
 }
 
 var (
@@ -158,7 +158,11 @@ func (p *ProxmoxClient) getInstancesFromNode(ctx context.Context, node, kind str
 		cpuPct := int(toFloat(m["cpu"]) * 100)
 		mem := bytesToMB(m["mem"], m["maxmem"]) // используем текущую память
 		disk := bytesToGB(m["disk"])
-		res = append(res, &Instance{ID: strconv.Itoa(vmid), Name: name, Type: map[string]string{"qemu": "vm", "lxc": "lxc"}[kind], Status: status, CPU: cpuPct, RAM: mem, Disk: disk, Node: nodeName})
+		tags, _ := m["tags"].(string)
+		if nodeName == "" {
+			nodeName = node
+		}
+		res = append(res, &Instance{ID: strconv.Itoa(vmid), Name: name, Type: map[string]string{"qemu": "vm", "lxc": "lxc"}[kind], Status: status, CPU: cpuPct, RAM: mem, Disk: disk, Node: nodeName, Tags: splitTags(tags)})
 	}
 	return res, nil
 }
@@ -204,7 +208,7 @@ func (p *ProxmoxClient) GetInstances(ctx context.Context) ([]*Instance, error) {
 }
 
 // Действия
-func (p *ProxmoxClient) performAction(ctx context.Context, instanceType, instanceID, action string) error {
+func (p *ProxmoxClient) performAction(ctx context.Context, instanceType, instanceID, action string, params url.Values) error {
 	node, err := p.findNodeForInstance(ctx, instanceType, instanceID)
 	if err != nil {
 		return err
@@ -215,7 +219,8 @@ func (p *ProxmoxClient) performAction(ctx context.Context, instanceType, instanc
 	} else {
 		path = fmt.Sprintf("/nodes/%s/lxc/%s/status/%s", node, instanceID, action)
 	}
-	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, strings.NewReader(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	p.setAuthHeaders(req)
 	resp, err := p.client.Do(req)
 	if err != nil {
@@ -228,14 +233,55 @@ func (p *ProxmoxClient) performAction(ctx context.Context, instanceType, instanc
 	return nil
 }
 
+// Для LXC нет сброса и гибернации (suspend/resume в Proxmox — через CRIU)
+var (
+	proxmoxVMActions  = []string{ActionStart, ActionStop, ActionShutdown, ActionRestart, ActionReset, ActionSuspend, ActionResume, ActionHibernate, ActionSnapshot, ActionRollback, ActionDelete}
+	proxmoxLXCActions = []string{ActionStart, ActionStop, ActionShutdown, ActionRestart, ActionSuspend, ActionResume, ActionSnapshot, ActionRollback, ActionDelete}
+)
+
+func (p *ProxmoxClient) SupportedActions(instanceType string) []string {
+	if instanceType == "lxc" {
+		return proxmoxLXCActions
+	}
+	return proxmoxVMActions
+}
+
 func (p *ProxmoxClient) StartInstance(ctx context.Context, t, id string) error {
-	return p.performAction(ctx, t, id, "start")
+	return p.performAction(ctx, t, id, "start", nil)
 }
 func (p *ProxmoxClient) StopInstance(ctx context.Context, t, id string) error {
-	return p.performAction(ctx, t, id, "stop")
+	return p.performAction(ctx, t, id, "stop", nil)
+}
+func (p *ProxmoxClient) ShutdownInstance(ctx context.Context, t, id string, opts ShutdownOptions) error {
+	params := url.Values{}
+	if opts.Timeout > 0 {
+		params.Set("timeout", strconv.Itoa(int(opts.Timeout.Seconds())))
+	}
+	if opts.Force {
+		params.Set("forceStop", "1")
+	}
+	return p.performAction(ctx, t, id, "shutdown", params)
 }
 func (p *ProxmoxClient) RestartInstance(ctx context.Context, t, id string) error {
-	return p.performAction(ctx, t, id, "reboot")
+	return p.performAction(ctx, t, id, "reboot", nil)
+}
+func (p *ProxmoxClient) ResetInstance(ctx context.Context, t, id string) error {
+	if err := CheckAction(p, t, ActionReset); err != nil {
+		return err
+	}
+	return p.performAction(ctx, t, id, "reset", nil)
+}
+func (p *ProxmoxClient) SuspendInstance(ctx context.Context, t, id string) error {
+	return p.performAction(ctx, t, id, "suspend", nil)
+}
+func (p *ProxmoxClient) ResumeInstance(ctx context.Context, t, id string) error {
+	return p.performAction(ctx, t, id, "resume", nil)
+}
+func (p *ProxmoxClient) HibernateInstance(ctx context.Context, t, id string) error {
+	if err := CheckAction(p, t, ActionHibernate); err != nil {
+		return err
+	}
+	return p.performAction(ctx, t, id, "suspend", url.Values{"todisk": {"1"}})
 }
 
 func (p *ProxmoxClient) GetInstanceStatus(ctx context.Context, t, id string) (string, error) {
@@ -348,6 +394,78 @@ func (p *ProxmoxClient) CreateSnapshot(ctx context.Context, t, id, name string)
 	return nil
 }
 
+func (p *ProxmoxClient) RollbackSnapshot(ctx context.Context, t, id, name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: snapshot name required", ErrActionFailed)
+	}
+	node, err := p.findNodeForInstance(ctx, t, id)
+	if err != nil {
+		return err
+	}
+	var path string
+	if t == "vm" {
+		path = fmt.Sprintf("/nodes/%s/qemu/%s/snapshot/%s/rollback", node, id, url.PathEscape(name))
+	} else {
+		path = fmt.Sprintf("/nodes/%s/lxc/%s/snapshot/%s/rollback", node, id, url.PathEscape(name))
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, nil)
+	p.setAuthHeaders(req)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ErrActionFailed
+	}
+	return nil
+}
+
+// Переустановка в Proxmox делается восстановлением из бэкапа/шаблона — через панель не поддерживается
+func (p *ProxmoxClient) ReinstallInstance(ctx context.Context, t, id string) error {
+	return CheckAction(p, t, ActionReinstall)
+}
+
+// Опция protection запрещает удаление VM/CT и её дисков на стороне Proxmox
+func (p *ProxmoxClient) GetProtection(ctx context.Context, t, id string) (bool, error) {
+	cfg, err := p.GetInstanceConfig(ctx, t, id)
+	if err != nil {
+		return false, err
+	}
+	return toInt(cfg["protection"]) == 1 || cfg["protection"] == "1", nil
+}
+
+func (p *ProxmoxClient) SetProtection(ctx context.Context, t, id string, protected bool) error {
+	node, err := p.findNodeForInstance(ctx, t, id)
+	if err != nil {
+		return err
+	}
+	var path string
+	if t == "vm" {
+		path = fmt.Sprintf("/nodes/%s/qemu/%s/config", node, id)
+	} else {
+		path = fmt.Sprintf("/nodes/%s/lxc/%s/config", node, id)
+	}
+	data := url.Values{}
+	if protected {
+		data.Set("protection", "1")
+	} else {
+		data.Set("protection", "0")
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPut, p.baseURL+path, strings.NewReader(data.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	p.setAuthHeaders(req)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ErrActionFailed
+	}
+	return nil
+}
+
 // Поиск ноды по инстансу
 func (p *ProxmoxClient) findNodeForInstance(ctx context.Context, t, id string) (string, error) {
 	nodes, err := p.getNodes(ctx)
@@ -403,4 +521,13 @@ func toFloat(v any) float64 {
 func bytesToMB(cur any, _ any) int { // упрощено
 	return int(toFloat(cur) / 1024.0 / 1024.0)
 }
+
+// Proxmox хранит теги строкой через ";" (в старых версиях — через ",")
+func splitTags(s string) []string {
+	tags := []string{}
+	for _, t := range strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == ',' || r == ' ' }) {
+		tags = append(tags, t)
+	}
+	return tags
+}
 func bytesToGB(cur any) int { return int(toFloat(cur) / 1024.0 / 1024.0 / 1024.0) }
@@ -6,11 +6,28 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
 )
 
+// AAD — связанные данные шифротекста: таблица, столбец и id записи. Шифротекст,
+// перенесённый в другую запись или столбец, не расшифруется.
+func AAD(table, column string, id int) []byte {
+	return []byte(fmt.Sprintf("ospab-panel:%s.%s:%d", table, column, id))
+}
+
 // EncryptString шифрует строку с использованием AES-GCM и возвращает base64
 func EncryptString(key []byte, plaintext string) (string, error) {
+	return EncryptStringAAD(key, plaintext, nil)
+}
+
+// DecryptString расшифровывает base64 строку
+func DecryptString(key []byte, b64 string) (string, error) {
+	return DecryptStringAAD(key, b64, nil)
+}
+
+// EncryptStringAAD шифрует строку AES-GCM со связанными данными aad
+func EncryptStringAAD(key []byte, plaintext string, aad []byte) (string, error) {
 	if len(key) != 32 { // 256-bit
 		return "", errors.New("key must be 32 bytes (AES-256)")
 	}
@@ -26,12 +43,12 @@ func EncryptString(key []byte, plaintext string) (string, error) {
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		return "", err
 	}
-	cipherText := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	cipherText := gcm.Seal(nonce, nonce, []byte(plaintext), aad)
 	return base64.RawStdEncoding.EncodeToString(cipherText), nil
 }
 
-// DecryptString расшифровывает base64 строку
-func DecryptString(key []byte, b64 string) (string, error) {
+// DecryptStringAAD расшифровывает строку; aad должны совпасть с переданными при шифровании
+func DecryptStringAAD(key []byte, b64 string, aad []byte) (string, error) {
 	if len(key) != 32 {
 		return "", errors.New("key must be 32 bytes (AES-256)")
 	}
@@ -51,7 +68,7 @@ func DecryptString(key []byte, b64 string) (string, error) {
 		return "", errors.New("ciphertext too short")
 	}
 	nonce, ct := raw[:gcm.NonceSize()], raw[gcm.NonceSize():]
-	data, err := gcm.Open(nil, nonce, ct, nil)
+	data, err := gcm.Open(nil, nonce, ct, aad)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,222 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Шифротекст хранится как "<id ключа>:aad:<base64>" — он привязан к записи связанными
+// данными (см. AAD). Прежние форматы без привязки: "<id ключа>:<base64>" и base64 без
+// префикса, записанный до появления версий ключей (ключ LegacyKeyID из SERVER_SECRET_KEY).
+const LegacyKeyID = "0"
+
+const boundMarker = "aad:"
+
+// devKey — ключ разработки, известный всем; допускается только в DEV_MODE
+const devKey = "dev-insecure-key-dev-insecure-key-32!!"
+
+var (
+	ErrNoKey        = errors.New("secret_key_missing")
+	ErrWeakKey      = errors.New("secret_key_weak")
+	ErrInvalidKey   = errors.New("secret_key_invalid")
+	ErrUnknownKeyID = errors.New("unknown_key_id")
+	ErrUnbound      = errors.New("ciphertext_not_bound")
+)
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,16}$`)
+
+// Keyring — ключи шифрования секретов: активный шифрует, остальные только расшифровывают
+// значения, записанные до смены ключа.
+type Keyring struct {
+	active       string
+	keys         map[string][]byte
+	requireBound bool
+}
+
+// NewKeyring собирает связку из 32-байтных ключей; active должен быть среди них
+func NewKeyring(active string, keys map[string][]byte) (*Keyring, error) {
+	for id, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("%w: ключ %q не 32 байта", ErrInvalidKey, id)
+		}
+	}
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("%w: нет активного ключа %q", ErrNoKey, active)
+	}
+	return &Keyring{active: active, keys: keys}, nil
+}
+
+// KeyringFromEnv читает ключи из окружения:
+//   - SERVER_SECRET_KEYS — "id:base64,..." (32 байта каждый);
+//   - SERVER_SECRET_KEY_ACTIVE — id активного ключа, по умолчанию первый из списка;
+//   - SERVER_SECRET_KEY — прежний ключ-строка (id "0") для значений без префикса; без
+//     SERVER_SECRET_KEYS он же остаётся активным.
+//
+// Без ключа или со слабым активным ключом запуск возможен только в режиме разработки,
+// тогда используется общеизвестный ключ разработки.
+func KeyringFromEnv(devMode bool) (*Keyring, error) {
+	keys := map[string][]byte{}
+	active := os.Getenv("SERVER_SECRET_KEY_ACTIVE")
+	for _, item := range strings.Split(os.Getenv("SERVER_SECRET_KEYS"), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(item, ":")
+		if !ok || !keyIDPattern.MatchString(id) || id == LegacyKeyID {
+			return nil, fmt.Errorf("%w: ожидается id:base64, id — латиница, цифры, _ и - (кроме %q)", ErrInvalidKey, LegacyKeyID)
+		}
+		if _, dup := keys[id]; dup {
+			return nil, fmt.Errorf("%w: ключ %q указан дважды", ErrInvalidKey, id)
+		}
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%w: ключ %q: %v", ErrInvalidKey, id, err)
+		}
+		keys[id] = key
+		if active == "" {
+			active = id
+		}
+	}
+
+	legacy := os.Getenv("SERVER_SECRET_KEY")
+	if legacy != "" {
+		keys[LegacyKeyID] = legacyKey(legacy)
+	}
+	if active == "" && legacy != "" {
+		active = LegacyKeyID
+	}
+	if active == "" {
+		if !devMode {
+			return nil, fmt.Errorf("%w: задайте SERVER_SECRET_KEYS", ErrNoKey)
+		}
+		log.Println("Warning: SERVER_SECRET_KEYS is not set, using insecure development key")
+		keys[LegacyKeyID], active = legacyKey(devKey), LegacyKeyID
+	}
+
+	weak := false
+	if active == LegacyKeyID {
+		weak = weakLegacyKey(legacy)
+	} else if key, ok := keys[active]; ok {
+		weak = weakKey(key)
+	}
+	if weak && !devMode {
+		return nil, fmt.Errorf("%w: активный ключ %q", ErrWeakKey, active)
+	}
+	return NewKeyring(active, keys)
+}
+
+// ActiveID — id ключа, которым шифруются новые значения
+func (k *Keyring) ActiveID() string {
+	return k.active
+}
+
+// RequireBound запрещает расшифровку значений без привязки к записи — после того как
+// "server rotate-keys" перешифровал все значения
+func (k *Keyring) RequireBound(require bool) {
+	k.requireBound = require
+}
+
+// Encrypt шифрует строку активным ключом с привязкой к aad (nil — без привязки)
+func (k *Keyring) Encrypt(plaintext string, aad []byte) (string, error) {
+	ct, err := EncryptStringAAD(k.keys[k.active], plaintext, aad)
+	if err != nil {
+		return "", err
+	}
+	if aad == nil {
+		return k.active + ":" + ct, nil
+	}
+	return k.active + ":" + boundMarker + ct, nil
+}
+
+// Decrypt расшифровывает значение ключом из его префикса. Значение с привязкой
+// расшифруется только с теми же aad.
+func (k *Keyring) Decrypt(value string, aad []byte) (string, error) {
+	id, ct := splitKeyID(value)
+	key, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKeyID, id)
+	}
+	if bound, ok := strings.CutPrefix(ct, boundMarker); ok {
+		return DecryptStringAAD(key, bound, aad)
+	}
+	if aad != nil && k.requireBound {
+		return "", ErrUnbound
+	}
+	return DecryptString(key, ct)
+}
+
+// Bound — значение зашифровано с привязкой к записи
+func (k *Keyring) Bound(value string) bool {
+	_, ct := splitKeyID(value)
+	return strings.HasPrefix(ct, boundMarker)
+}
+
+// NeedsRotation — значение зашифровано не активным ключом или не привязано к записи
+func (k *Keyring) NeedsRotation(value string) bool {
+	id, _ := splitKeyID(value)
+	return id != k.active || !k.Bound(value)
+}
+
+// Reencrypt расшифровывает значение и шифрует его активным ключом с привязкой к aad
+func (k *Keyring) Reencrypt(value string, aad []byte) (string, error) {
+	plain, err := k.Decrypt(value, aad)
+	if err != nil {
+		return "", err
+	}
+	return k.Encrypt(plain, aad)
+}
+
+// splitKeyID отделяет id ключа; в base64 двоеточия не бывает
+func splitKeyID(value string) (id, ct string) {
+	if id, ct, ok := strings.Cut(value, ":"); ok {
+		return id, ct
+	}
+	return LegacyKeyID, value
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		key, err = base64.RawStdEncoding.DecodeString(encoded)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("нужно 32 байта, получено %d", len(key))
+	}
+	return key, nil
+}
+
+// legacyKey — прежнее получение ключа из строки: первые 32 байта, короткая строка
+// дополняется нулями. Оставлено для расшифровки старых значений.
+func legacyKey(k string) []byte {
+	if len(k) < 32 {
+		k = (k + strings.Repeat("0", 32))[:32]
+	}
+	return []byte(k[:32])
+}
+
+// weakKey — у случайного 32-байтного ключа почти все байты различны
+func weakKey(key []byte) bool {
+	return distinctBytes(key) < 16
+}
+
+func weakLegacyKey(k string) bool {
+	return len(k) < 32 || strings.HasPrefix(devKey, k[:32]) || distinctBytes([]byte(k)) < 12
+}
+
+func distinctBytes(b []byte) int {
+	seen := map[byte]bool{}
+	for _, c := range b {
+		seen[c] = true
+	}
+	return len(seen)
+}
@@ -0,0 +1,108 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func testKeyring(t *testing.T) *Keyring {
+	t.Helper()
+	k, err := NewKeyring("k2", map[string][]byte{
+		LegacyKeyID: legacyKey("legacy-secret-key-legacy-secret-key"),
+		"k2":        bytes.Repeat([]byte{7}, 32),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestKeyringBinding(t *testing.T) {
+	k := testKeyring(t)
+	value, err := k.Encrypt("root", AAD("servers", "username_enc", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k.Bound(value) || k.NeedsRotation(value) {
+		t.Fatalf("fresh value %q: bound=%v needsRotation=%v", value, k.Bound(value), k.NeedsRotation(value))
+	}
+
+	tests := []struct {
+		name string
+		aad  []byte
+		ok   bool
+	}{
+		{"same row and column", AAD("servers", "username_enc", 1), true},
+		{"other row", AAD("servers", "username_enc", 2), false},
+		{"other column", AAD("servers", "password_enc", 1), false},
+		{"other table", AAD("users", "username_enc", 1), false},
+		{"no aad", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain, err := k.Decrypt(value, tt.aad)
+			if tt.ok {
+				if err != nil || plain != "root" {
+					t.Fatalf("Decrypt = %q, %v; want root", plain, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Decrypt = %q; want error", plain)
+			}
+		})
+	}
+}
+
+func TestKeyringLegacy(t *testing.T) {
+	k := testKeyring(t)
+	aad := AAD("servers", "password_enc", 5)
+	unprefixed, err := EncryptString(legacyKey("legacy-secret-key-legacy-secret-key"), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unbound, err := EncryptString(k.keys["k2"], "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"without key id", unprefixed},
+		{"legacy key id", LegacyKeyID + ":" + unprefixed},
+		{"active key without binding", "k2:" + unbound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if plain, err := k.Decrypt(tt.value, aad); err != nil || plain != "secret" {
+				t.Fatalf("Decrypt = %q, %v; want secret", plain, err)
+			}
+			if k.Bound(tt.value) || !k.NeedsRotation(tt.value) {
+				t.Fatalf("bound=%v needsRotation=%v; want unbound value to need rotation", k.Bound(tt.value), k.NeedsRotation(tt.value))
+			}
+
+			rotated, err := k.Reencrypt(tt.value, aad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !k.Bound(rotated) || k.NeedsRotation(rotated) {
+				t.Fatalf("Reencrypt = %q: bound=%v needsRotation=%v", rotated, k.Bound(rotated), k.NeedsRotation(rotated))
+			}
+			if plain, err := k.Decrypt(rotated, aad); err != nil || plain != "secret" {
+				t.Fatalf("Decrypt(rotated) = %q, %v; want secret", plain, err)
+			}
+			if _, err := k.Decrypt(rotated, AAD("servers", "password_enc", 6)); err == nil {
+				t.Fatal("rotated value decrypts for another row")
+			}
+
+			k.RequireBound(true)
+			defer k.RequireBound(false)
+			if _, err := k.Decrypt(tt.value, aad); !errors.Is(err, ErrUnbound) {
+				t.Fatalf("Decrypt with RequireBound = %v; want ErrUnbound", err)
+			}
+		})
+	}
+}
@@ -53,14 +53,13 @@ func (r *Repository) initTables() error {
 	if os.Getenv("PRISMA_MANAGED") == "1" {
 		return nil
 	}
-	// Новая схема пользователей с bcrypt + солью
+	// Схема пользователей; password_hash — в формате PHC (argon2id)
 	usersTable := `
     CREATE TABLE IF NOT EXISTS users (
         id INT AUTO_INCREMENT PRIMARY KEY,
         username VARCHAR(64) UNIQUE NOT NULL,
         email VARCHAR(128) UNIQUE NOT NULL,
         password_hash VARCHAR(255) NOT NULL,
-        password_salt VARCHAR(64) NOT NULL,
         created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
         updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
     ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
@@ -89,10 +88,426 @@ func (r *Repository) initTables() error {
 		return fmt.Errorf("failed to create servers table: %w", err)
 	}
 
+	// Инвентарь инстансов (кэш фоновой синхронизации)
+	instancesTable := `
+    CREATE TABLE IF NOT EXISTS instances (
+        id INT AUTO_INCREMENT PRIMARY KEY,
+        server_id INT NOT NULL,
+        instance_id VARCHAR(64) NOT NULL,
+        type VARCHAR(8) NOT NULL,
+        name VARCHAR(255) NOT NULL DEFAULT '',
+        status VARCHAR(32) NOT NULL DEFAULT '',
+        cpu INT NOT NULL DEFAULT 0,
+        ram INT NOT NULL DEFAULT 0,
+        disk INT NOT NULL DEFAULT 0,
+        os VARCHAR(64) NOT NULL DEFAULT '',
+        node VARCHAR(128) NOT NULL DEFAULT '',
+        tags VARCHAR(512) NOT NULL DEFAULT '',
+        protected TINYINT(1) NOT NULL DEFAULT 0,
+        first_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        status_changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        disappeared_at TIMESTAMP NULL,
+        UNIQUE KEY (server_id, type, instance_id),
+        FOREIGN KEY (server_id) REFERENCES servers(id) ON DELETE CASCADE
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(instancesTable); err != nil {
+		return fmt.Errorf("failed to create instances table: %w", err)
+	}
+
+	instanceEventsTable := `
+    CREATE TABLE IF NOT EXISTS instance_events (
+        id BIGINT AUTO_INCREMENT PRIMARY KEY,
+        server_id INT NOT NULL,
+        instance_id VARCHAR(64) NOT NULL,
+        type VARCHAR(8) NOT NULL,
+        event VARCHAR(32) NOT NULL,
+        old_status VARCHAR(32) NOT NULL DEFAULT '',
+        new_status VARCHAR(32) NOT NULL DEFAULT '',
+        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        INDEX (server_id, type, instance_id),
+        FOREIGN KEY (server_id) REFERENCES servers(id) ON DELETE CASCADE
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(instanceEventsTable); err != nil {
+		return fmt.Errorf("failed to create instance_events table: %w", err)
+	}
+
+	inventorySyncTable := `
+    CREATE TABLE IF NOT EXISTS inventory_sync (
+        server_id INT PRIMARY KEY,
+        last_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        last_success_at TIMESTAMP NULL,
+        last_error TEXT NULL,
+        FOREIGN KEY (server_id) REFERENCES servers(id) ON DELETE CASCADE
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(inventorySyncTable); err != nil {
+		return fmt.Errorf("failed to create inventory_sync table: %w", err)
+	}
+
+	// Колонки, появившиеся после первой версии инвентаря (best effort)
+	_, _ = r.db.Exec("ALTER TABLE instances ADD COLUMN tags VARCHAR(512) NOT NULL DEFAULT '' AFTER node")
+	_, _ = r.db.Exec("ALTER TABLE instances ADD COLUMN protected TINYINT(1) NOT NULL DEFAULT 0 AFTER tags")
+
+	// Одноразовые токены подтверждения разрушительных действий
+	confirmationsTable := `
+    CREATE TABLE IF NOT EXISTS action_confirmations (
+        token_hash CHAR(64) PRIMARY KEY,
+        user_id INT NOT NULL,
+        server_id INT NOT NULL,
+        instance_type VARCHAR(8) NOT NULL,
+        instance_id VARCHAR(64) NOT NULL,
+        action VARCHAR(32) NOT NULL,
+        expires_at TIMESTAMP NOT NULL,
+        used_at TIMESTAMP NULL,
+        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        INDEX (expires_at),
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+        FOREIGN KEY (server_id) REFERENCES servers(id) ON DELETE CASCADE
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(confirmationsTable); err != nil {
+		return fmt.Errorf("failed to create action_confirmations table: %w", err)
+	}
+
+	// Роли: колонка users.role и пользовательские роли
+	rolesTable := `
+    CREATE TABLE IF NOT EXISTS roles (
+        name VARCHAR(32) PRIMARY KEY,
+        description VARCHAR(255) NOT NULL DEFAULT '',
+        permissions TEXT NOT NULL,
+        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(rolesTable); err != nil {
+		return fmt.Errorf("failed to create roles table: %w", err)
+	}
+	if _, err := r.db.Exec("ALTER TABLE users ADD COLUMN role VARCHAR(32) NOT NULL DEFAULT 'operator' AFTER password_hash"); err == nil {
+		// Колонка только что добавлена — назначаем администратором первого пользователя
+		_, _ = r.db.Exec("UPDATE users SET role='admin' ORDER BY id LIMIT 1")
+	}
+
+	// Организации, участники и приглашения
+	organizationsTable := `
+    CREATE TABLE IF NOT EXISTS organizations (
+        id INT AUTO_INCREMENT PRIMARY KEY,
+        name VARCHAR(128) NOT NULL,
+        created_by INT NOT NULL,
+        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(organizationsTable); err != nil {
+		return fmt.Errorf("failed to create organizations table: %w", err)
+	}
+	membersTable := `
+    CREATE TABLE IF NOT EXISTS organization_members (
+        org_id INT NOT NULL,
+        user_id INT NOT NULL,
+        role VARCHAR(16) NOT NULL,
+        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (org_id, user_id),
+        INDEX (user_id),
+        FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(membersTable); err != nil {
+		return fmt.Errorf("failed to create organization_members table: %w", err)
+	}
+	invitesTable := `
+    CREATE TABLE IF NOT EXISTS organization_invites (
+        id INT AUTO_INCREMENT PRIMARY KEY,
+        org_id INT NOT NULL,
+        email VARCHAR(128) NOT NULL,
+        role VARCHAR(16) NOT NULL,
+        token_hash CHAR(64) NOT NULL UNIQUE,
+        invited_by INT NOT NULL,
+        expires_at TIMESTAMP NOT NULL,
+        accepted_at TIMESTAMP NULL,
+        accepted_by INT NULL,
+        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        INDEX (org_id),
+        FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(invitesTable); err != nil {
+		return fmt.Errorf("failed to create organization_invites table: %w", err)
+	}
+	_, _ = r.db.Exec("ALTER TABLE servers ADD COLUMN organization_id INT NULL AFTER user_id")
+	_, _ = r.db.Exec("ALTER TABLE servers ADD CONSTRAINT servers_organization_id_fkey FOREIGN KEY (organization_id) REFERENCES organizations(id)")
+
+	// Гранты на отдельные инстансы
+	grantsTable := `
+    CREATE TABLE IF NOT EXISTS instance_grants (
+        id INT AUTO_INCREMENT PRIMARY KEY,
+        server_id INT NOT NULL,
+        instance_type VARCHAR(8) NOT NULL,
+        instance_id VARCHAR(64) NOT NULL,
+        user_id INT NULL,
+        org_id INT NULL,
+        actions VARCHAR(255) NOT NULL,
+        created_by INT NOT NULL,
+        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        INDEX (server_id, instance_type, instance_id),
+        INDEX (user_id),
+        INDEX (org_id),
+        FOREIGN KEY (server_id) REFERENCES servers(id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+        FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(grantsTable); err != nil {
+		return fmt.Errorf("failed to create instance_grants table: %w", err)
+	}
+
+	// Сессии, refresh-токены и denylist access-токенов
+	sessionsTable := `
+    CREATE TABLE IF NOT EXISTS sessions (
+        id CHAR(32) PRIMARY KEY,
+        user_id INT NOT NULL,
+        user_agent VARCHAR(255) NOT NULL DEFAULT '',
+        ip VARCHAR(64) NOT NULL DEFAULT '',
+        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        last_used_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        expires_at TIMESTAMP NOT NULL,
+        revoked_at TIMESTAMP NULL,
+        INDEX (user_id),
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(sessionsTable); err != nil {
+		return fmt.Errorf("failed to create sessions table: %w", err)
+	}
+	refreshTable := `
+    CREATE TABLE IF NOT EXISTS refresh_tokens (
+        token_hash CHAR(64) PRIMARY KEY,
+        session_id CHAR(32) NOT NULL,
+        expires_at TIMESTAMP NOT NULL,
+        used_at TIMESTAMP NULL,
+        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        INDEX (session_id),
+        FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(refreshTable); err != nil {
+		return fmt.Errorf("failed to create refresh_tokens table: %w", err)
+	}
+	revokedTable := `
+    CREATE TABLE IF NOT EXISTS revoked_tokens (
+        jti CHAR(32) PRIMARY KEY,
+        expires_at TIMESTAMP NOT NULL,
+        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        INDEX (expires_at)
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(revokedTable); err != nil {
+		return fmt.Errorf("failed to create revoked_tokens table: %w", err)
+	}
+
+	// Двухфакторная аутентификация, вызовы входа и настройки панели
+	_, _ = r.db.Exec("ALTER TABLE users ADD COLUMN totp_secret_enc TEXT NULL AFTER role")
+	_, _ = r.db.Exec("ALTER TABLE users ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT false AFTER totp_secret_enc")
+	_, _ = r.db.Exec("ALTER TABLE users ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0 AFTER totp_enabled")
+	_, _ = r.db.Exec("ALTER TABLE sessions ADD COLUMN mfa BOOLEAN NOT NULL DEFAULT false AFTER ip")
+	recoveryTable := `
+    CREATE TABLE IF NOT EXISTS user_recovery_codes (
+        id INT AUTO_INCREMENT PRIMARY KEY,
+        user_id INT NOT NULL,
+        code_hash CHAR(64) NOT NULL,
+        used_at TIMESTAMP NULL,
+        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        INDEX (user_id),
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(recoveryTable); err != nil {
+		return fmt.Errorf("failed to create user_recovery_codes table: %w", err)
+	}
+	challengesTable := `
+    CREATE TABLE IF NOT EXISTS auth_challenges (
+        token_hash CHAR(64) PRIMARY KEY,
+        kind VARCHAR(16) NOT NULL,
+        user_id INT NULL,
+        data TEXT NOT NULL,
+        attempts INT NOT NULL DEFAULT 0,
+        expires_at TIMESTAMP NOT NULL,
+        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        INDEX (expires_at),
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(challengesTable); err != nil {
+		return fmt.Errorf("failed to create auth_challenges table: %w", err)
+	}
+	settingsTable := `
+    CREATE TABLE IF NOT EXISTS settings (
+        name VARCHAR(64) PRIMARY KEY,
+        value TEXT NOT NULL,
+        updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(settingsTable); err != nil {
+		return fmt.Errorf("failed to create settings table: %w", err)
+	}
+
+	// Ключи WebAuthn (FIDO2, passkeys)
+	webauthnTable := `
+    CREATE TABLE IF NOT EXISTS webauthn_credentials (
+        id INT AUTO_INCREMENT PRIMARY KEY,
+        user_id INT NOT NULL,
+        name VARCHAR(64) NOT NULL,
+        credential_id VARBINARY(1023) NOT NULL UNIQUE,
+        public_key BLOB NOT NULL,
+        attestation_type VARCHAR(32) NOT NULL DEFAULT '',
+        transports VARCHAR(128) NOT NULL DEFAULT '',
+        aaguid VARBINARY(16) NULL,
+        flags TINYINT UNSIGNED NOT NULL DEFAULT 0,
+        sign_count INT UNSIGNED NOT NULL DEFAULT 0,
+        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        last_used_at TIMESTAMP NULL,
+        INDEX (user_id),
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(webauthnTable); err != nil {
+		return fmt.Errorf("failed to create webauthn_credentials table: %w", err)
+	}
+
+	// Учётные записи внешних провайдеров (OIDC, LDAP)
+	identitiesTable := `
+    CREATE TABLE IF NOT EXISTS user_identities (
+        id INT AUTO_INCREMENT PRIMARY KEY,
+        user_id INT NOT NULL,
+        issuer VARCHAR(255) NOT NULL,
+        subject VARCHAR(255) NOT NULL,
+        email VARCHAR(128) NOT NULL DEFAULT '',
+        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        last_login_at TIMESTAMP NULL,
+        UNIQUE KEY (issuer, subject),
+        INDEX (user_id),
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(identitiesTable); err != nil {
+		return fmt.Errorf("failed to create user_identities table: %w", err)
+	}
+
+	// Персональные API-ключи (хранится только SHA-256)
+	apiKeysTable := `
+    CREATE TABLE IF NOT EXISTS api_keys (
+        id INT AUTO_INCREMENT PRIMARY KEY,
+        user_id INT NOT NULL,
+        name VARCHAR(100) NOT NULL,
+        prefix VARCHAR(16) NOT NULL,
+        key_hash CHAR(64) NOT NULL UNIQUE,
+        scope VARCHAR(16) NOT NULL,
+        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        last_used_at TIMESTAMP NULL,
+        expires_at TIMESTAMP NULL,
+        INDEX (user_id),
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(apiKeysTable); err != nil {
+		return fmt.Errorf("failed to create api_keys table: %w", err)
+	}
+
+	// Счётчики неудачных входов по логину и IP (общие для реплик)
+	loginFailuresTable := `
+    CREATE TABLE IF NOT EXISTS login_failures (
+        kind VARCHAR(8) NOT NULL,
+        subject VARCHAR(255) NOT NULL,
+        failures INT NOT NULL DEFAULT 0,
+        locked BOOLEAN NOT NULL DEFAULT FALSE,
+        last_failure_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        blocked_until TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (kind, subject),
+        INDEX (blocked_until)
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(loginFailuresTable); err != nil {
+		return fmt.Errorf("failed to create login_failures table: %w", err)
+	}
+
+	// Подтверждение email и одноразовые токены из писем (подтверждение, сброс пароля)
+	_, _ = r.db.Exec("ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT false AFTER email")
+	userTokensTable := `
+    CREATE TABLE IF NOT EXISTS user_tokens (
+        id INT AUTO_INCREMENT PRIMARY KEY,
+        user_id INT NOT NULL,
+        purpose VARCHAR(32) NOT NULL,
+        token_hash CHAR(64) NOT NULL UNIQUE,
+        email VARCHAR(128) NOT NULL,
+        expires_at TIMESTAMP NOT NULL,
+        used_at TIMESTAMP NULL,
+        created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        INDEX (user_id, purpose),
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(userTokensTable); err != nil {
+		return fmt.Errorf("failed to create user_tokens table: %w", err)
+	}
+
+	// Отключение учёток администратором и сессии входа от имени пользователя
+	_, _ = r.db.Exec("ALTER TABLE users ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT false AFTER totp_last_step")
+	_, _ = r.db.Exec("ALTER TABLE sessions ADD COLUMN impersonator_id INT NULL AFTER mfa")
+
+	// Журнал аудита: только добавление записей, UPDATE и DELETE запрещены триггерами
+	auditLogTable := `
+    CREATE TABLE IF NOT EXISTS audit_log (
+        id BIGINT AUTO_INCREMENT PRIMARY KEY,
+        created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+        actor_id INT NULL,
+        actor_name VARCHAR(64) NOT NULL DEFAULT '',
+        impersonator_id INT NULL,
+        api_key_id INT NULL,
+        ip VARCHAR(64) NOT NULL DEFAULT '',
+        user_agent VARCHAR(255) NOT NULL DEFAULT '',
+        action VARCHAR(96) NOT NULL,
+        method VARCHAR(8) NOT NULL DEFAULT '',
+        path VARCHAR(255) NOT NULL DEFAULT '',
+        server_id INT NULL,
+        instance_id VARCHAR(128) NOT NULL DEFAULT '',
+        target VARCHAR(128) NOT NULL DEFAULT '',
+        params TEXT NULL,
+        result VARCHAR(16) NOT NULL,
+        status INT NOT NULL DEFAULT 0,
+        error VARCHAR(255) NOT NULL DEFAULT '',
+        duration_ms BIGINT NOT NULL DEFAULT 0,
+        INDEX (created_at),
+        INDEX (actor_id, id),
+        INDEX (impersonator_id, id),
+        INDEX (server_id, id),
+        INDEX (action, id)
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(auditLogTable); err != nil {
+		return fmt.Errorf("failed to create audit_log table: %w", err)
+	}
+	_, _ = r.db.Exec(`CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log FOR EACH ROW
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only'`)
+	_, _ = r.db.Exec(`CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log FOR EACH ROW
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only'`)
+
+	// Цепочка хэшей журнала аудита: голова цепочки и подписанные контрольные точки
+	_, _ = r.db.Exec("ALTER TABLE audit_log ADD COLUMN prev_hash CHAR(64) NOT NULL DEFAULT '' AFTER duration_ms")
+	_, _ = r.db.Exec("ALTER TABLE audit_log ADD COLUMN hash CHAR(64) NOT NULL DEFAULT '' AFTER prev_hash")
+	auditChainTable := `
+    CREATE TABLE IF NOT EXISTS audit_chain (
+        id TINYINT PRIMARY KEY,
+        last_id BIGINT NOT NULL,
+        last_hash CHAR(64) NOT NULL
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(auditChainTable); err != nil {
+		return fmt.Errorf("failed to create audit_chain table: %w", err)
+	}
+	auditCheckpointsTable := `
+    CREATE TABLE IF NOT EXISTS audit_checkpoints (
+        id BIGINT AUTO_INCREMENT PRIMARY KEY,
+        created_at TIMESTAMP(6) NOT NULL,
+        entry_id BIGINT NOT NULL,
+        entry_hash CHAR(64) NOT NULL,
+        algorithm VARCHAR(16) NOT NULL,
+        key_id VARCHAR(64) NOT NULL,
+        signature VARCHAR(128) NOT NULL,
+        INDEX (entry_id)
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	if _, err := r.db.Exec(auditCheckpointsTable); err != nil {
+		return fmt.Errorf("failed to create audit_checkpoints table: %w", err)
+	}
+	_, _ = r.db.Exec(`CREATE TRIGGER audit_checkpoints_no_update BEFORE UPDATE ON audit_checkpoints FOR EACH ROW
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_checkpoints is append-only'`)
+	_, _ = r.db.Exec(`CREATE TRIGGER audit_checkpoints_no_delete BEFORE DELETE ON audit_checkpoints FOR EACH ROW
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_checkpoints is append-only'`)
+
 	// Если старое поле password осталось (миграция не выполнена) — попытаться переименовать (best effort)
 	_, _ = r.db.Exec("ALTER TABLE users CHANGE COLUMN password password_hash VARCHAR(255)")
-	// Если не хватает столбца password_salt — добавить
-	_, _ = r.db.Exec("ALTER TABLE users ADD COLUMN password_salt VARCHAR(64) NOT NULL AFTER password_hash")
+	// Соль bcrypt-хэшей старого формата переносится в сам хэш, столбец password_salt удаляется.
+	// Такие хэши заменяются на argon2id при следующем входе пользователя.
+	_, _ = r.db.Exec("UPDATE users SET password_hash = CONCAT('$bcrypt-salted$', password_salt, password_hash) WHERE password_hash LIKE '$2%'")
+	_, _ = r.db.Exec("ALTER TABLE users DROP COLUMN password_salt")
 
 	return nil
 }
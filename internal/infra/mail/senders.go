@@ -0,0 +1,127 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// smtpSender — отправка через SMTP-релей
+type smtpSender struct {
+	cfg  Config
+	from string // адрес конверта
+}
+
+func newSMTP(cfg Config) (*smtpSender, error) {
+	if cfg.SMTPHost == "" {
+		return nil, errors.New("SMTP host is required")
+	}
+	if cfg.SMTPPort == 0 {
+		cfg.SMTPPort = 587
+	}
+	if cfg.SMTPSecurity == "" {
+		cfg.SMTPSecurity = "starttls"
+	}
+	if cfg.SMTPSecurity != "starttls" && cfg.SMTPSecurity != "tls" && cfg.SMTPSecurity != "none" {
+		return nil, fmt.Errorf("unknown SMTP security mode %q", cfg.SMTPSecurity)
+	}
+	a, _ := mail.ParseAddress(cfg.From)
+	return &smtpSender{cfg: cfg, from: a.Address}, nil
+}
+
+func (s *smtpSender) Send(ctx context.Context, msg *Message) error {
+	data, err := format(s.cfg.From, msg)
+	if err != nil {
+		return err
+	}
+	to, _ := mail.ParseAddress(msg.To)
+
+	addr := net.JoinHostPort(s.cfg.SMTPHost, strconv.Itoa(s.cfg.SMTPPort))
+	tc := &tls.Config{ServerName: s.cfg.SMTPHost}
+	dialer := &net.Dialer{Timeout: s.cfg.Timeout}
+	var conn net.Conn
+	if s.cfg.SMTPSecurity == "tls" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tc}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(s.cfg.Timeout))
+	c, err := smtp.NewClient(conn, s.cfg.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if s.cfg.SMTPSecurity == "starttls" {
+		if err := c.StartTLS(tc); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if s.cfg.SMTPUsername != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.SMTPUsername, s.cfg.SMTPPassword, s.cfg.SMTPHost)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(s.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// fileSender складывает письма в каталог как .eml (для разработки и тестов)
+type fileSender struct {
+	from string
+	dir  string
+}
+
+func newFile(cfg Config) (*fileSender, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = "mail"
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &fileSender{from: cfg.From, dir: cfg.Dir}, nil
+}
+
+func (s *fileSender) Send(_ context.Context, msg *Message) error {
+	data, err := format(s.from, msg)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%d.eml", time.Now().Format("20060102-150405"), time.Now().UnixNano()%1e9)
+	return os.WriteFile(filepath.Join(s.dir, name), data, 0o600)
+}
+
+// logSender выводит письма в журнал сервера (по умолчанию, если почта не настроена)
+type logSender struct{}
+
+func (s *logSender) Send(_ context.Context, msg *Message) error {
+	log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,76 @@
+package secrets
+
+import (
+	"context"
+	"crypto/rand"
+	"strings"
+
+	"ospab-panel/internal/infra/crypto"
+)
+
+// KMS шифрует ключи данных ключом, который хранится во внешней системе
+type KMS interface {
+	Wrap(ctx context.Context, dek []byte) (string, error)
+	Unwrap(ctx context.Context, wrapped string) ([]byte, error)
+}
+
+// envelope — конвертное шифрование: каждое значение шифруется своим ключом данных
+// (AES-256-GCM, aad — связанные данные), а ключ данных — ключом KMS. В БД хранится
+// $envelope$<обёрнутый ключ>$<шифротекст>$aad; без KMS значение не расшифровать.
+// У значений без привязки нет суффикса $aad.
+type envelope struct {
+	kms KMS
+}
+
+func NewEnvelope(kms KMS) Store {
+	return &envelope{kms: kms}
+}
+
+func (s *envelope) Put(ctx context.Context, value string, aad []byte) (string, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	wrapped, err := s.kms.Wrap(ctx, dek)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(wrapped, "$") {
+		return "", ErrInvalidRef
+	}
+	ct, err := crypto.EncryptStringAAD(dek, value, aad)
+	if err != nil {
+		return "", err
+	}
+	ref := "$" + DriverEnvelope + "$" + wrapped + "$" + ct
+	if aad != nil {
+		ref += "$aad"
+	}
+	return ref, nil
+}
+
+func (s *envelope) Get(ctx context.Context, ref string, aad []byte) (string, error) {
+	parts := strings.Split(ref, "$")
+	if !s.Bound(ref) {
+		aad = nil
+	} else {
+		parts = parts[:4]
+	}
+	if len(parts) != 4 || parts[1] != DriverEnvelope {
+		return "", ErrInvalidRef
+	}
+	dek, err := s.kms.Unwrap(ctx, parts[2])
+	if err != nil {
+		return "", err
+	}
+	return crypto.DecryptStringAAD(dek, parts[3], aad)
+}
+
+func (*envelope) Bound(ref string) bool {
+	return strings.HasSuffix(ref, "$aad") && strings.Count(ref, "$") == 4
+}
+
+// Delete: шифротекст хранится в самой записи, удалять нечего
+func (*envelope) Delete(context.Context, string) error {
+	return nil
+}
@@ -0,0 +1,221 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"ospab-panel/internal/infra/crypto"
+)
+
+// Store хранит секреты. Put возвращает ссылку, которая сохраняется в БД вместо значения;
+// по ней Get возвращает значение. Ссылка неизменна: новое значение — новая ссылка.
+// aad привязывает секрет к записи (crypto.AAD): ссылка, перенесённая в другую запись,
+// не откроется. Bound — ссылка создана с привязкой; прежние ссылки без неё читаются
+// без проверки, пока не включён RequireBound.
+type Store interface {
+	Put(ctx context.Context, value string, aad []byte) (ref string, err error)
+	Get(ctx context.Context, ref string, aad []byte) (string, error)
+	Delete(ctx context.Context, ref string) error
+	Bound(ref string) bool
+}
+
+// Хранилище определяется по ссылке: "$vault$..." — Vault KV v2, "$envelope$..." —
+// конвертное шифрование, остальное — шифротекст локального ключа (crypto.Keyring).
+const (
+	DriverLocal    = "local"
+	DriverVault    = "vault"
+	DriverEnvelope = "envelope"
+)
+
+var (
+	ErrNotConfigured = errors.New("secret_store_not_configured")
+	ErrInvalidRef    = errors.New("invalid_secret_ref")
+	ErrBinding       = errors.New("secret_binding_mismatch")
+)
+
+// Config — выбор хранилища для новых секретов и параметры Vault
+type Config struct {
+	Driver            string // local | vault | envelope
+	VaultAddr         string
+	VaultToken        string
+	VaultNamespace    string
+	VaultKVMount      string // KV v2, по умолчанию secret
+	VaultKVPrefix     string // каталог секретов панели, по умолчанию ospab-panel
+	VaultTransitMount string // Transit для конвертного шифрования, по умолчанию transit
+	VaultTransitKey   string
+	CacheTTL          time.Duration // кэш значений внешних хранилищ; 0 — без кэша
+	RequireBound      bool          // не читать секреты без привязки к записи
+	Timeout           time.Duration
+}
+
+// Router пишет новые секреты в выбранное хранилище, а читает и удаляет в том, которому
+// принадлежит ссылка. Так после смены хранилища старые значения остаются доступны,
+// пока их не перенесёт "server rotate-keys".
+type Router struct {
+	driver       string
+	keys         *crypto.Keyring
+	stores       map[string]Store
+	ttl          time.Duration
+	requireBound bool
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+type cached struct {
+	value   string
+	expires time.Time
+}
+
+// New создаёт хранилище по cfg.Driver (пусто — local). Vault подключается, если задан
+// адрес, даже при локальном хранилище — чтобы читать ранее перенесённые туда секреты.
+func New(cfg Config, keys *crypto.Keyring) (*Router, error) {
+	if cfg.Driver == "" {
+		cfg.Driver = DriverLocal
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.VaultKVMount == "" {
+		cfg.VaultKVMount = "secret"
+	}
+	if cfg.VaultKVPrefix == "" {
+		cfg.VaultKVPrefix = "ospab-panel"
+	}
+	if cfg.VaultTransitMount == "" {
+		cfg.VaultTransitMount = "transit"
+	}
+	r := &Router{driver: cfg.Driver, keys: keys, ttl: cfg.CacheTTL, requireBound: cfg.RequireBound, cache: map[string]cached{},
+		stores: map[string]Store{DriverLocal: NewLocal(keys)}}
+	if cfg.VaultAddr != "" {
+		client := &VaultClient{Addr: strings.TrimRight(cfg.VaultAddr, "/"), Token: cfg.VaultToken, Namespace: cfg.VaultNamespace,
+			HTTP: &http.Client{Timeout: cfg.Timeout}}
+		r.stores[DriverVault] = NewVaultKV(client, cfg.VaultKVMount, cfg.VaultKVPrefix)
+		if cfg.VaultTransitKey != "" {
+			r.stores[DriverEnvelope] = NewEnvelope(NewVaultTransit(client, cfg.VaultTransitMount, cfg.VaultTransitKey))
+		}
+	}
+	switch cfg.Driver {
+	case DriverLocal, DriverVault, DriverEnvelope:
+	default:
+		return nil, fmt.Errorf("unknown secret store driver %q", cfg.Driver)
+	}
+	if r.stores[cfg.Driver] == nil {
+		return nil, fmt.Errorf("%w: %s requires VAULT_ADDR (and VAULT_TRANSIT_KEY for envelope)", ErrNotConfigured, cfg.Driver)
+	}
+	return r, nil
+}
+
+// Driver — хранилище новых секретов
+func (r *Router) Driver() string {
+	return r.driver
+}
+
+func (r *Router) Put(ctx context.Context, value string, aad []byte) (string, error) {
+	return r.stores[r.driver].Put(ctx, value, aad)
+}
+
+func (r *Router) Get(ctx context.Context, ref string, aad []byte) (string, error) {
+	driver := refDriver(ref)
+	store := r.stores[driver]
+	if store == nil {
+		return "", fmt.Errorf("%w: %s", ErrNotConfigured, driver)
+	}
+	if r.requireBound && !store.Bound(ref) {
+		return "", crypto.ErrUnbound
+	}
+	if driver == DriverLocal || r.ttl <= 0 {
+		return store.Get(ctx, ref, aad)
+	}
+	// Значение закэшировано вместе с привязкой, иначе перенос ссылки обошёл бы проверку
+	key := ref + "\x00" + string(aad)
+	now := time.Now()
+	r.mu.Lock()
+	c, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && now.Before(c.expires) {
+		return c.value, nil
+	}
+	value, err := store.Get(ctx, ref, aad)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	for k, c := range r.cache {
+		if !now.Before(c.expires) {
+			delete(r.cache, k)
+		}
+	}
+	r.cache[key] = cached{value: value, expires: now.Add(r.ttl)}
+	r.mu.Unlock()
+	return value, nil
+}
+
+func (r *Router) Delete(ctx context.Context, ref string) error {
+	r.mu.Lock()
+	for k := range r.cache {
+		if strings.HasPrefix(k, ref+"\x00") {
+			delete(r.cache, k)
+		}
+	}
+	r.mu.Unlock()
+	store := r.stores[refDriver(ref)]
+	if store == nil {
+		return fmt.Errorf("%w: %s", ErrNotConfigured, refDriver(ref))
+	}
+	return store.Delete(ctx, ref)
+}
+
+func (r *Router) Bound(ref string) bool {
+	store := r.stores[refDriver(ref)]
+	return store != nil && store.Bound(ref)
+}
+
+// Outdated — секрет лежит не в текущем хранилище, не привязан к записи или зашифрован
+// не активным ключом
+func (r *Router) Outdated(ref string) bool {
+	driver := refDriver(ref)
+	return driver != r.driver || !r.Bound(ref) || driver == DriverLocal && r.keys.NeedsRotation(ref)
+}
+
+func refDriver(ref string) string {
+	switch {
+	case strings.HasPrefix(ref, "$"+DriverVault+"$"):
+		return DriverVault
+	case strings.HasPrefix(ref, "$"+DriverEnvelope+"$"):
+		return DriverEnvelope
+	default:
+		return DriverLocal
+	}
+}
+
+// local — прежнее поведение: значение шифруется ключом панели и хранится в БД
+type local struct {
+	keys *crypto.Keyring
+}
+
+func NewLocal(keys *crypto.Keyring) Store {
+	return local{keys: keys}
+}
+
+func (s local) Put(_ context.Context, value string, aad []byte) (string, error) {
+	return s.keys.Encrypt(value, aad)
+}
+
+func (s local) Get(_ context.Context, ref string, aad []byte) (string, error) {
+	return s.keys.Decrypt(ref, aad)
+}
+
+func (s local) Bound(ref string) bool {
+	return s.keys.Bound(ref)
+}
+
+// Delete: шифротекст хранится в самой записи, удалять нечего
+func (local) Delete(context.Context, string) error {
+	return nil
+}
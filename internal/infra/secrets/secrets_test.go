@@ -0,0 +1,149 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"ospab-panel/internal/infra/crypto"
+)
+
+func testRouter(t *testing.T, driver string, ttl time.Duration) (*Router, *fakeVault) {
+	t.Helper()
+	keys, err := crypto.NewKeyring("k1", map[string][]byte{"k1": []byte(strings.Repeat("k", 32))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, client := newFakeVault(t)
+	r, err := New(Config{Driver: driver, VaultAddr: client.Addr, VaultToken: testToken, VaultTransitKey: "panel", CacheTTL: ttl}, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, v
+}
+
+func TestRouterOutdated(t *testing.T) {
+	ctx := context.Background()
+	r, _ := testRouter(t, DriverVault, 0)
+	aad := crypto.AAD("servers", "password_enc", 1)
+	local := NewLocal(r.keys)
+
+	bound, err := r.Put(ctx, "v", aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unbound, err := r.Put(ctx, "v", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	localBound, err := local.Put(ctx, "v", aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey, err := crypto.EncryptStringAAD([]byte(strings.Repeat("o", 32)), "v", aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := r.stores[DriverEnvelope].Put(ctx, "v", aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		ref      string
+		outdated bool
+	}{
+		{"current store, bound", bound, false},
+		{"current store, unbound", unbound, true},
+		{"other store", localBound, true},
+		{"other store, envelope", envelope, true},
+		{"inactive local key", "k0:aad:" + oldKey, true},
+	}
+	for _, tt := range tests {
+		if got := r.Outdated(tt.ref); got != tt.outdated {
+			t.Errorf("%s: Outdated(%q) = %v; want %v", tt.name, tt.ref, got, tt.outdated)
+		}
+	}
+
+	// После смены хранилища на local значения из Vault подлежат переносу
+	lr, _ := testRouter(t, DriverLocal, 0)
+	if lr.Outdated(localBound) {
+		t.Error("local router: bound value with active key is outdated")
+	}
+	if !lr.Outdated(bound) {
+		t.Error("local router: vault value is not outdated")
+	}
+}
+
+func TestRouterCache(t *testing.T) {
+	ctx := context.Background()
+	r, v := testRouter(t, DriverVault, time.Minute)
+	aad := crypto.AAD("servers", "password_enc", 1)
+
+	ref, err := r.Put(ctx, "v", aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := r.Get(ctx, ref, aad); err != nil || value != "v" {
+		t.Fatalf("Get = %q, %v", value, err)
+	}
+	before := v.count()
+	if value, err := r.Get(ctx, ref, aad); err != nil || value != "v" {
+		t.Fatalf("cached Get = %q, %v", value, err)
+	}
+	if v.count() != before {
+		t.Fatal("second Get went to Vault instead of the cache")
+	}
+
+	// Кэш учитывает привязку: чужие aad проверяются хранилищем
+	if _, err := r.Get(ctx, ref, crypto.AAD("servers", "password_enc", 2)); !errors.Is(err, ErrBinding) {
+		t.Fatalf("Get with other aad = %v; want ErrBinding", err)
+	}
+
+	if err := r.Delete(ctx, ref); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Get(ctx, ref, aad); err == nil {
+		t.Fatal("Get after Delete returned cached value")
+	}
+
+	// Локальные значения не кэшируются: шифротекст и так хранится в записи
+	localRef, err := r.stores[DriverLocal].Put(ctx, "l", aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Get(ctx, localRef, aad); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.cache) != 0 {
+		t.Fatalf("cache holds %d entries; want none", len(r.cache))
+	}
+}
+
+func TestRouterRequireBound(t *testing.T) {
+	ctx := context.Background()
+	keys, err := crypto.NewKeyring("k1", map[string][]byte{"k1": []byte(strings.Repeat("k", 32))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := New(Config{RequireBound: true}, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unbound, err := r.Put(ctx, "v", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Get(ctx, unbound, crypto.AAD("servers", "password_enc", 1)); !errors.Is(err, crypto.ErrUnbound) {
+		t.Fatalf("Get unbound = %v; want ErrUnbound", err)
+	}
+	if _, err := r.Get(ctx, "$vault$secret$p$1", nil); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("Get vault ref without Vault = %v; want ErrNotConfigured", err)
+	}
+	if _, err := New(Config{Driver: DriverEnvelope}, keys); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("New(envelope) without Vault = %v; want ErrNotConfigured", err)
+	}
+}
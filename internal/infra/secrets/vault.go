@@ -0,0 +1,235 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// VaultClient — минимальный клиент HTTP API HashiCorp Vault (аутентификация токеном)
+type VaultClient struct {
+	Addr      string
+	Token     string
+	Namespace string
+	HTTP      *http.Client
+}
+
+// VaultError — ответ Vault с кодом ошибки
+type VaultError struct {
+	Status int
+	Errors []string
+}
+
+func (e *VaultError) Error() string {
+	return fmt.Sprintf("vault: HTTP %d: %s", e.Status, strings.Join(e.Errors, "; "))
+}
+
+// do выполняет запрос к /v1/<path>; in и out — JSON, out может быть nil
+func (c *VaultClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.Addr+"/v1/"+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", c.Token)
+	if c.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.Namespace)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		verr := &VaultError{Status: resp.StatusCode}
+		_ = json.Unmarshal(data, &struct {
+			Errors *[]string `json:"errors"`
+		}{&verr.Errors})
+		return verr
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// vaultKV — секреты в Vault KV v2: каждое значение — отдельный секрет
+// <prefix>/<случайный id> с полями value и aad (привязка к записи, base64).
+// Ссылка: $vault$<mount>$<путь>$<версия>$aad; у ссылок без привязки нет суффикса $aad.
+type vaultKV struct {
+	client *VaultClient
+	mount  string
+	prefix string
+}
+
+func NewVaultKV(client *VaultClient, mount, prefix string) Store {
+	return &vaultKV{client: client, mount: strings.Trim(mount, "/"), prefix: strings.Trim(prefix, "/")}
+}
+
+func (s *vaultKV) Put(ctx context.Context, value string, aad []byte) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	path := s.prefix + "/" + hex.EncodeToString(id)
+	var resp struct {
+		Data struct {
+			Version int `json:"version"`
+		} `json:"data"`
+	}
+	// cas=0 — запись только нового секрета
+	data := map[string]string{"value": value}
+	if aad != nil {
+		data["aad"] = base64.StdEncoding.EncodeToString(aad)
+	}
+	in := map[string]interface{}{"data": data, "options": map[string]int{"cas": 0}}
+	if err := s.client.do(ctx, http.MethodPost, s.mount+"/data/"+escapePath(path), in, &resp); err != nil {
+		return "", err
+	}
+	ref := "$" + DriverVault + "$" + s.mount + "$" + path + "$" + strconv.Itoa(resp.Data.Version)
+	if aad != nil {
+		ref += "$aad"
+	}
+	return ref, nil
+}
+
+func (s *vaultKV) Get(ctx context.Context, ref string, aad []byte) (string, error) {
+	mount, path, version, err := parseVaultRef(ref)
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	query := ""
+	if version > 0 {
+		query = "?version=" + strconv.Itoa(version)
+	}
+	if err := s.client.do(ctx, http.MethodGet, mount+"/data/"+escapePath(path)+query, nil, &resp); err != nil {
+		return "", err
+	}
+	value, ok := resp.Data.Data["value"].(string)
+	if !ok {
+		return "", fmt.Errorf("vault: %s: нет строкового поля value", path)
+	}
+	// Привязка проверяется и по самому секрету: удаление суффикса $aad из ссылки её не снимет
+	if bound, ok := resp.Data.Data["aad"].(string); ok || s.Bound(ref) {
+		if subtle.ConstantTimeCompare([]byte(bound), []byte(base64.StdEncoding.EncodeToString(aad))) != 1 {
+			return "", fmt.Errorf("%w: %s", ErrBinding, path)
+		}
+	}
+	return value, nil
+}
+
+func (s *vaultKV) Bound(ref string) bool {
+	return strings.HasSuffix(ref, "$aad") && strings.Count(ref, "$") == 5
+}
+
+// Delete удаляет секрет со всеми версиями
+func (s *vaultKV) Delete(ctx context.Context, ref string) error {
+	mount, path, _, err := parseVaultRef(ref)
+	if err != nil {
+		return err
+	}
+	err = s.client.do(ctx, http.MethodDelete, mount+"/metadata/"+escapePath(path), nil, nil)
+	var verr *VaultError
+	if errors.As(err, &verr) && verr.Status == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+func parseVaultRef(ref string) (mount, path string, version int, err error) {
+	parts := strings.Split(ref, "$")
+	if len(parts) == 6 && parts[5] == "aad" {
+		parts = parts[:5]
+	}
+	if len(parts) != 5 || parts[1] != DriverVault || parts[2] == "" || parts[3] == "" {
+		return "", "", 0, ErrInvalidRef
+	}
+	version, err = strconv.Atoi(parts[4])
+	if err != nil {
+		return "", "", 0, ErrInvalidRef
+	}
+	return parts[2], parts[3], version, nil
+}
+
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// vaultTransit — KMS на Vault Transit: ключ данных шифруется ключом key, который не
+// покидает Vault. Обёрнутый ключ: <key>:<шифротекст Transit>.
+type vaultTransit struct {
+	client *VaultClient
+	mount  string
+	key    string
+}
+
+func NewVaultTransit(client *VaultClient, mount, key string) KMS {
+	return &vaultTransit{client: client, mount: strings.Trim(mount, "/"), key: key}
+}
+
+func (k *vaultTransit) Wrap(ctx context.Context, dek []byte) (string, error) {
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	in := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dek)}
+	if err := k.client.do(ctx, http.MethodPost, k.mount+"/encrypt/"+url.PathEscape(k.key), in, &resp); err != nil {
+		return "", err
+	}
+	if resp.Data.Ciphertext == "" {
+		return "", errors.New("vault transit: пустой шифротекст")
+	}
+	return k.key + ":" + resp.Data.Ciphertext, nil
+}
+
+func (k *vaultTransit) Unwrap(ctx context.Context, wrapped string) ([]byte, error) {
+	key, ciphertext, ok := strings.Cut(wrapped, ":")
+	if !ok || key == "" {
+		return nil, ErrInvalidRef
+	}
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	in := map[string]string{"ciphertext": ciphertext}
+	if err := k.client.do(ctx, http.MethodPost, k.mount+"/decrypt/"+url.PathEscape(key), in, &resp); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+}
@@ -0,0 +1,234 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"ospab-panel/internal/infra/crypto"
+)
+
+const testToken = "s.test-token"
+
+// fakeVault — KV v2 и Transit в объёме, который использует панель
+type fakeVault struct {
+	mu       sync.Mutex
+	kv       map[string][]map[string]interface{} // путь → версии
+	requests int
+	lastCAS  interface{}
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *VaultClient) {
+	v := &fakeVault{kv: map[string][]map[string]interface{}{}}
+	srv := httptest.NewServer(v)
+	t.Cleanup(srv.Close)
+	return v, &VaultClient{Addr: srv.URL, Token: testToken, HTTP: srv.Client()}
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.requests++
+	if r.Header.Get("X-Vault-Token") != testToken {
+		reply(w, http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	var in map[string]interface{}
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&in)
+	}
+	switch {
+	case strings.HasPrefix(path, "secret/data/") && r.Method == http.MethodPost:
+		key := strings.TrimPrefix(path, "secret/data/")
+		opts, _ := in["options"].(map[string]interface{})
+		v.lastCAS = opts["cas"]
+		if opts["cas"] == 0.0 && len(v.kv[key]) > 0 {
+			reply(w, http.StatusBadRequest, map[string]interface{}{"errors": []string{"check-and-set parameter did not match the current version"}})
+			return
+		}
+		data, _ := in["data"].(map[string]interface{})
+		v.kv[key] = append(v.kv[key], data)
+		reply(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"version": len(v.kv[key])}})
+	case strings.HasPrefix(path, "secret/data/") && r.Method == http.MethodGet:
+		versions := v.kv[strings.TrimPrefix(path, "secret/data/")]
+		n, _ := strconv.Atoi(r.URL.Query().Get("version"))
+		if n == 0 {
+			n = len(versions)
+		}
+		if n < 1 || n > len(versions) {
+			reply(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+			return
+		}
+		reply(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"data": versions[n-1]}})
+	case strings.HasPrefix(path, "secret/metadata/") && r.Method == http.MethodDelete:
+		key := strings.TrimPrefix(path, "secret/metadata/")
+		if _, ok := v.kv[key]; !ok {
+			reply(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+			return
+		}
+		delete(v.kv, key)
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(path, "transit/encrypt/"):
+		// Шифротекст Transit непрозрачен для панели; здесь — обратимая подстановка
+		plain, _ := in["plaintext"].(string)
+		reply(w, http.StatusOK, map[string]interface{}{"data": map[string]string{"ciphertext": "vault:v1:" + plain}})
+	case strings.HasPrefix(path, "transit/decrypt/"):
+		ct, _ := in["ciphertext"].(string)
+		plain, ok := strings.CutPrefix(ct, "vault:v1:")
+		if !ok {
+			reply(w, http.StatusBadRequest, map[string]interface{}{"errors": []string{"invalid ciphertext"}})
+			return
+		}
+		reply(w, http.StatusOK, map[string]interface{}{"data": map[string]string{"plaintext": plain}})
+	default:
+		reply(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+	}
+}
+
+func (v *fakeVault) count() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.requests
+}
+
+func reply(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func TestVaultKV(t *testing.T) {
+	ctx := context.Background()
+	v, client := newFakeVault(t)
+	store := NewVaultKV(client, "/secret/", "ospab-panel")
+
+	ref, err := store.Put(ctx, "p@ss", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.lastCAS != 0.0 {
+		t.Fatalf("Put options.cas = %v; want 0", v.lastCAS)
+	}
+	parts := strings.Split(ref, "$")
+	if len(parts) != 5 || parts[1] != DriverVault || parts[2] != "secret" || !strings.HasPrefix(parts[3], "ospab-panel/") || parts[4] != "1" {
+		t.Fatalf("Put ref = %q; want $vault$secret$ospab-panel/<id>$1", ref)
+	}
+	if store.Bound(ref) {
+		t.Fatalf("Bound(%q) = true for value without aad", ref)
+	}
+	if value, err := store.Get(ctx, ref, nil); err != nil || value != "p@ss" {
+		t.Fatalf("Get = %q, %v", value, err)
+	}
+
+	if err := store.Delete(ctx, ref); err != nil {
+		t.Fatal(err)
+	}
+	var verr *VaultError
+	if _, err := store.Get(ctx, ref, nil); !errors.As(err, &verr) || verr.Status != http.StatusNotFound {
+		t.Fatalf("Get after Delete = %v; want 404", err)
+	}
+	// Повторное удаление (секрет уже удалён) — не ошибка
+	if err := store.Delete(ctx, ref); err != nil {
+		t.Fatalf("Delete of missing secret = %v", err)
+	}
+
+	for _, bad := range []string{"$vault$secret$$1", "$vault$secret$p$x", "$vault$secret$p", "$envelope$a$b"} {
+		if _, err := store.Get(ctx, bad, nil); !errors.Is(err, ErrInvalidRef) {
+			t.Errorf("Get(%q) = %v; want ErrInvalidRef", bad, err)
+		}
+	}
+
+	client.Token = "wrong"
+	if _, err := store.Put(ctx, "x", nil); !errors.As(err, &verr) || verr.Status != http.StatusForbidden || verr.Errors[0] != "permission denied" {
+		t.Fatalf("Put with wrong token = %v; want 403", err)
+	}
+}
+
+func TestVaultKVBinding(t *testing.T) {
+	ctx := context.Background()
+	_, client := newFakeVault(t)
+	store := NewVaultKV(client, "secret", "ospab-panel")
+	aad := crypto.AAD("servers", "password_enc", 1)
+
+	ref, err := store.Put(ctx, "p@ss", aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(ref, "$1$aad") || !store.Bound(ref) {
+		t.Fatalf("Put ref = %q; want bound ref", ref)
+	}
+	stripped := strings.TrimSuffix(ref, "$aad")
+
+	tests := []struct {
+		name string
+		ref  string
+		aad  []byte
+		ok   bool
+	}{
+		{"same record", ref, aad, true},
+		{"other row", ref, crypto.AAD("servers", "password_enc", 2), false},
+		{"other column", ref, crypto.AAD("servers", "username_enc", 1), false},
+		{"no aad", ref, nil, false},
+		{"suffix removed, other row", stripped, crypto.AAD("servers", "password_enc", 2), false},
+		{"suffix removed, no aad", stripped, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := store.Get(ctx, tt.ref, tt.aad)
+			if tt.ok {
+				if err != nil || value != "p@ss" {
+					t.Fatalf("Get = %q, %v", value, err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrBinding) {
+				t.Fatalf("Get = %q, %v; want ErrBinding", value, err)
+			}
+		})
+	}
+}
+
+func TestVaultTransit(t *testing.T) {
+	ctx := context.Background()
+	_, client := newFakeVault(t)
+	kms := NewVaultTransit(client, "transit", "panel")
+
+	dek := []byte("0123456789abcdef0123456789abcdef")
+	wrapped, err := kms.Wrap(ctx, dek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(wrapped, "panel:vault:v1:") {
+		t.Fatalf("Wrap = %q; want panel:<ciphertext>", wrapped)
+	}
+	got, err := kms.Unwrap(ctx, wrapped)
+	if err != nil || string(got) != string(dek) {
+		t.Fatalf("Unwrap = %q, %v", got, err)
+	}
+	if _, err := kms.Unwrap(ctx, "no-key-separator"); !errors.Is(err, ErrInvalidRef) {
+		t.Fatalf("Unwrap without key = %v; want ErrInvalidRef", err)
+	}
+
+	store := NewEnvelope(kms)
+	aad := crypto.AAD("servers", "username_enc", 3)
+	ref, err := store.Put(ctx, "root", aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := store.Get(ctx, ref, aad); err != nil || value != "root" {
+		t.Fatalf("envelope Get = %q, %v", value, err)
+	}
+	if _, err := store.Get(ctx, ref, crypto.AAD("servers", "username_enc", 4)); err == nil {
+		t.Fatal("envelope value decrypts for another row")
+	}
+	if _, err := store.Get(ctx, strings.TrimSuffix(ref, "$aad"), nil); err == nil {
+		t.Fatal("envelope value decrypts with $aad suffix removed")
+	}
+}
@@ -1,35 +1,65 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// DefaultAccessTTL — срок жизни access-токена; продлевается через refresh-токен
+const DefaultAccessTTL = 15 * time.Minute
+
 type Claims struct {
-	UserID   int    `json:"user_id"`
-	Username string `json:"username"`
+	UserID    int    `json:"user_id"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	MFA       bool   `json:"mfa,omitempty"` // вход подтверждён вторым фактором
+	// ImpersonatorID — администратор, вошедший от имени пользователя (RFC 8693 "act")
+	ImpersonatorID int `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
 type JWTManager struct {
 	secretKey string
+	accessTTL time.Duration
 }
 
-func NewJWTManager(secretKey string) *JWTManager {
+func NewJWTManager(secretKey string, accessTTL time.Duration) *JWTManager {
+	if accessTTL <= 0 {
+		accessTTL = DefaultAccessTTL
+	}
 	return &JWTManager{
 		secretKey: secretKey,
+		accessTTL: accessTTL,
 	}
 }
 
-func (j *JWTManager) GenerateToken(userID int, username string) (string, error) {
+// AccessTTL возвращает срок жизни выдаваемых access-токенов
+func (j *JWTManager) AccessTTL() time.Duration { return j.accessTTL }
+
+// GenerateToken выдаёт access-токен сессии sessionID с уникальным jti (для отзыва).
+// impersonatorID — администратор сессии-имперсонации, иначе 0.
+func (j *JWTManager) GenerateToken(userID int, username, role, sessionID string, mfa bool, impersonatorID int) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	now := time.Now()
 	claims := Claims{
-		UserID:   userID,
-		Username: username,
+		UserID:         userID,
+		Username:       username,
+		Role:           role,
+		SessionID:      sessionID,
+		MFA:            mfa,
+		ImpersonatorID: impersonatorID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        hex.EncodeToString(jti),
+			ExpiresAt: jwt.NewNumericDate(now.Add(j.accessTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
 			Subject:   fmt.Sprintf("%d", userID),
 		},
 	}
@@ -55,4 +85,4 @@ func (j *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 	}
 
 	return nil, fmt.Errorf("invalid token")
-}
\ No newline at end of file
+}
@@ -0,0 +1,55 @@
+-- CreateTable
+CREATE TABLE `instances` (
+    `id` INTEGER NOT NULL AUTO_INCREMENT,
+    `server_id` INTEGER NOT NULL,
+    `instance_id` VARCHAR(64) NOT NULL,
+    `type` VARCHAR(8) NOT NULL,
+    `name` VARCHAR(255) NOT NULL DEFAULT '',
+    `status` VARCHAR(32) NOT NULL DEFAULT '',
+    `cpu` INTEGER NOT NULL DEFAULT 0,
+    `ram` INTEGER NOT NULL DEFAULT 0,
+    `disk` INTEGER NOT NULL DEFAULT 0,
+    `os` VARCHAR(64) NOT NULL DEFAULT '',
+    `node` VARCHAR(128) NOT NULL DEFAULT '',
+    `first_seen_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    `last_seen_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    `status_changed_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    `disappeared_at` TIMESTAMP(6) NULL,
+
+    UNIQUE INDEX `instances_server_id_type_instance_id_key`(`server_id`, `type`, `instance_id`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- CreateTable
+CREATE TABLE `instance_events` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT,
+    `server_id` INTEGER NOT NULL,
+    `instance_id` VARCHAR(64) NOT NULL,
+    `type` VARCHAR(8) NOT NULL,
+    `event` VARCHAR(32) NOT NULL,
+    `old_status` VARCHAR(32) NOT NULL DEFAULT '',
+    `new_status` VARCHAR(32) NOT NULL DEFAULT '',
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+    INDEX `instance_events_server_id_type_instance_id_idx`(`server_id`, `type`, `instance_id`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- CreateTable
+CREATE TABLE `inventory_sync` (
+    `server_id` INTEGER NOT NULL,
+    `last_attempt_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    `last_success_at` TIMESTAMP(6) NULL,
+    `last_error` TEXT NULL,
+
+    PRIMARY KEY (`server_id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- AddForeignKey
+ALTER TABLE `instances` ADD CONSTRAINT `instances_server_id_fkey` FOREIGN KEY (`server_id`) REFERENCES `servers`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE `instance_events` ADD CONSTRAINT `instance_events_server_id_fkey` FOREIGN KEY (`server_id`) REFERENCES `servers`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE `inventory_sync` ADD CONSTRAINT `inventory_sync_server_id_fkey` FOREIGN KEY (`server_id`) REFERENCES `servers`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE `instances` ADD COLUMN `tags` VARCHAR(512) NOT NULL DEFAULT '';
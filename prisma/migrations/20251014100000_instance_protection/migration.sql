@@ -0,0 +1,24 @@
+-- AlterTable
+ALTER TABLE `instances` ADD COLUMN `protected` BOOLEAN NOT NULL DEFAULT false;
+
+-- CreateTable
+CREATE TABLE `action_confirmations` (
+    `token_hash` CHAR(64) NOT NULL,
+    `user_id` INTEGER NOT NULL,
+    `server_id` INTEGER NOT NULL,
+    `instance_type` VARCHAR(8) NOT NULL,
+    `instance_id` VARCHAR(64) NOT NULL,
+    `action` VARCHAR(32) NOT NULL,
+    `expires_at` TIMESTAMP(6) NOT NULL,
+    `used_at` TIMESTAMP(6) NULL,
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+    INDEX `action_confirmations_expires_at_idx`(`expires_at`),
+    PRIMARY KEY (`token_hash`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- AddForeignKey
+ALTER TABLE `action_confirmations` ADD CONSTRAINT `action_confirmations_user_id_fkey` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE `action_confirmations` ADD CONSTRAINT `action_confirmations_server_id_fkey` FOREIGN KEY (`server_id`) REFERENCES `servers`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,15 @@
+-- AlterTable
+ALTER TABLE `users` ADD COLUMN `role` VARCHAR(32) NOT NULL DEFAULT 'operator';
+
+-- CreateTable
+CREATE TABLE `roles` (
+    `name` VARCHAR(32) NOT NULL,
+    `description` VARCHAR(255) NOT NULL DEFAULT '',
+    `permissions` TEXT NOT NULL,
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+    PRIMARY KEY (`name`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- Первый зарегистрированный пользователь становится администратором
+UPDATE `users` SET `role` = 'admin' ORDER BY `id` LIMIT 1;
@@ -0,0 +1,53 @@
+-- CreateTable
+CREATE TABLE `organizations` (
+    `id` INTEGER NOT NULL AUTO_INCREMENT,
+    `name` VARCHAR(128) NOT NULL,
+    `created_by` INTEGER NOT NULL,
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- CreateTable
+CREATE TABLE `organization_members` (
+    `org_id` INTEGER NOT NULL,
+    `user_id` INTEGER NOT NULL,
+    `role` VARCHAR(16) NOT NULL,
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+    INDEX `organization_members_user_id_idx`(`user_id`),
+    PRIMARY KEY (`org_id`, `user_id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- CreateTable
+CREATE TABLE `organization_invites` (
+    `id` INTEGER NOT NULL AUTO_INCREMENT,
+    `org_id` INTEGER NOT NULL,
+    `email` VARCHAR(128) NOT NULL,
+    `role` VARCHAR(16) NOT NULL,
+    `token_hash` CHAR(64) NOT NULL,
+    `invited_by` INTEGER NOT NULL,
+    `expires_at` TIMESTAMP(6) NOT NULL,
+    `accepted_at` TIMESTAMP(6) NULL,
+    `accepted_by` INTEGER NULL,
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+    UNIQUE INDEX `organization_invites_token_hash_key`(`token_hash`),
+    INDEX `organization_invites_org_id_idx`(`org_id`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- AlterTable
+ALTER TABLE `servers` ADD COLUMN `organization_id` INTEGER NULL;
+
+-- AddForeignKey
+ALTER TABLE `organization_members` ADD CONSTRAINT `organization_members_org_id_fkey` FOREIGN KEY (`org_id`) REFERENCES `organizations`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE `organization_members` ADD CONSTRAINT `organization_members_user_id_fkey` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE `organization_invites` ADD CONSTRAINT `organization_invites_org_id_fkey` FOREIGN KEY (`org_id`) REFERENCES `organizations`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE `servers` ADD CONSTRAINT `servers_organization_id_fkey` FOREIGN KEY (`organization_id`) REFERENCES `organizations`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE;
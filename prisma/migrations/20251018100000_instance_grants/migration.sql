@@ -0,0 +1,26 @@
+-- CreateTable
+CREATE TABLE `instance_grants` (
+    `id` INTEGER NOT NULL AUTO_INCREMENT,
+    `server_id` INTEGER NOT NULL,
+    `instance_type` VARCHAR(8) NOT NULL,
+    `instance_id` VARCHAR(64) NOT NULL,
+    `user_id` INTEGER NULL,
+    `org_id` INTEGER NULL,
+    `actions` VARCHAR(255) NOT NULL,
+    `created_by` INTEGER NOT NULL,
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+    INDEX `instance_grants_server_id_instance_type_instance_id_idx`(`server_id`, `instance_type`, `instance_id`),
+    INDEX `instance_grants_user_id_idx`(`user_id`),
+    INDEX `instance_grants_org_id_idx`(`org_id`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- AddForeignKey
+ALTER TABLE `instance_grants` ADD CONSTRAINT `instance_grants_server_id_fkey` FOREIGN KEY (`server_id`) REFERENCES `servers`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE `instance_grants` ADD CONSTRAINT `instance_grants_user_id_fkey` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE `instance_grants` ADD CONSTRAINT `instance_grants_org_id_fkey` FOREIGN KEY (`org_id`) REFERENCES `organizations`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,42 @@
+-- CreateTable
+CREATE TABLE `sessions` (
+    `id` CHAR(32) NOT NULL,
+    `user_id` INTEGER NOT NULL,
+    `user_agent` VARCHAR(255) NOT NULL DEFAULT '',
+    `ip` VARCHAR(64) NOT NULL DEFAULT '',
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    `last_used_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    `expires_at` TIMESTAMP(6) NOT NULL,
+    `revoked_at` TIMESTAMP(6) NULL,
+
+    INDEX `sessions_user_id_idx`(`user_id`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- CreateTable
+CREATE TABLE `refresh_tokens` (
+    `token_hash` CHAR(64) NOT NULL,
+    `session_id` CHAR(32) NOT NULL,
+    `expires_at` TIMESTAMP(6) NOT NULL,
+    `used_at` TIMESTAMP(6) NULL,
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+    INDEX `refresh_tokens_session_id_idx`(`session_id`),
+    PRIMARY KEY (`token_hash`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- CreateTable
+CREATE TABLE `revoked_tokens` (
+    `jti` CHAR(32) NOT NULL,
+    `expires_at` TIMESTAMP(6) NOT NULL,
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+    INDEX `revoked_tokens_expires_at_idx`(`expires_at`),
+    PRIMARY KEY (`jti`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- AddForeignKey
+ALTER TABLE `sessions` ADD CONSTRAINT `sessions_user_id_fkey` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE `refresh_tokens` ADD CONSTRAINT `refresh_tokens_session_id_fkey` FOREIGN KEY (`session_id`) REFERENCES `sessions`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,48 @@
+-- AlterTable
+ALTER TABLE `users` ADD COLUMN `totp_secret_enc` TEXT NULL,
+    ADD COLUMN `totp_enabled` BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN `totp_last_step` BIGINT NOT NULL DEFAULT 0;
+
+-- AlterTable
+ALTER TABLE `sessions` ADD COLUMN `mfa` BOOLEAN NOT NULL DEFAULT false;
+
+-- CreateTable
+CREATE TABLE `user_recovery_codes` (
+    `id` INTEGER NOT NULL AUTO_INCREMENT,
+    `user_id` INTEGER NOT NULL,
+    `code_hash` CHAR(64) NOT NULL,
+    `used_at` TIMESTAMP(6) NULL,
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+    INDEX `user_recovery_codes_user_id_idx`(`user_id`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- CreateTable
+CREATE TABLE `auth_challenges` (
+    `token_hash` CHAR(64) NOT NULL,
+    `kind` VARCHAR(16) NOT NULL,
+    `user_id` INTEGER NULL,
+    `data` TEXT NOT NULL,
+    `attempts` INTEGER NOT NULL DEFAULT 0,
+    `expires_at` TIMESTAMP(6) NOT NULL,
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+    INDEX `auth_challenges_expires_at_idx`(`expires_at`),
+    PRIMARY KEY (`token_hash`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- CreateTable
+CREATE TABLE `settings` (
+    `name` VARCHAR(64) NOT NULL,
+    `value` TEXT NOT NULL,
+    `updated_at` TIMESTAMP(6) NOT NULL,
+
+    PRIMARY KEY (`name`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- AddForeignKey
+ALTER TABLE `user_recovery_codes` ADD CONSTRAINT `user_recovery_codes_user_id_fkey` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE `auth_challenges` ADD CONSTRAINT `auth_challenges_user_id_fkey` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,22 @@
+-- CreateTable
+CREATE TABLE `webauthn_credentials` (
+    `id` INTEGER NOT NULL AUTO_INCREMENT,
+    `user_id` INTEGER NOT NULL,
+    `name` VARCHAR(64) NOT NULL,
+    `credential_id` VARBINARY(1023) NOT NULL,
+    `public_key` BLOB NOT NULL,
+    `attestation_type` VARCHAR(32) NOT NULL DEFAULT '',
+    `transports` VARCHAR(128) NOT NULL DEFAULT '',
+    `aaguid` VARBINARY(16) NULL,
+    `flags` TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    `sign_count` INTEGER UNSIGNED NOT NULL DEFAULT 0,
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    `last_used_at` TIMESTAMP(6) NULL,
+
+    UNIQUE INDEX `webauthn_credentials_credential_id_key`(`credential_id`),
+    INDEX `webauthn_credentials_user_id_idx`(`user_id`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- AddForeignKey
+ALTER TABLE `webauthn_credentials` ADD CONSTRAINT `webauthn_credentials_user_id_fkey` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,17 @@
+-- CreateTable
+CREATE TABLE `user_identities` (
+    `id` INTEGER NOT NULL AUTO_INCREMENT,
+    `user_id` INTEGER NOT NULL,
+    `issuer` VARCHAR(255) NOT NULL,
+    `subject` VARCHAR(255) NOT NULL,
+    `email` VARCHAR(128) NOT NULL DEFAULT '',
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    `last_login_at` TIMESTAMP(6) NULL,
+
+    INDEX `user_identities_user_id_idx`(`user_id`),
+    UNIQUE INDEX `user_identities_issuer_subject_key`(`issuer`, `subject`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- AddForeignKey
+ALTER TABLE `user_identities` ADD CONSTRAINT `user_identities_user_id_fkey` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,19 @@
+-- CreateTable
+CREATE TABLE `api_keys` (
+    `id` INTEGER NOT NULL AUTO_INCREMENT,
+    `user_id` INTEGER NOT NULL,
+    `name` VARCHAR(100) NOT NULL,
+    `prefix` VARCHAR(16) NOT NULL,
+    `key_hash` CHAR(64) NOT NULL,
+    `scope` VARCHAR(16) NOT NULL,
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    `last_used_at` TIMESTAMP(6) NULL,
+    `expires_at` TIMESTAMP(6) NULL,
+
+    UNIQUE INDEX `api_keys_key_hash_key`(`key_hash`),
+    INDEX `api_keys_user_id_idx`(`user_id`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- AddForeignKey
+ALTER TABLE `api_keys` ADD CONSTRAINT `api_keys_user_id_fkey` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,12 @@
+-- CreateTable
+CREATE TABLE `login_failures` (
+    `kind` VARCHAR(8) NOT NULL,
+    `subject` VARCHAR(255) NOT NULL,
+    `failures` INTEGER NOT NULL DEFAULT 0,
+    `locked` BOOLEAN NOT NULL DEFAULT false,
+    `last_failure_at` TIMESTAMP(6) NOT NULL,
+    `blocked_until` TIMESTAMP(6) NOT NULL,
+
+    INDEX `login_failures_blocked_until_idx`(`blocked_until`),
+    PRIMARY KEY (`kind`, `subject`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
@@ -0,0 +1,21 @@
+-- AlterTable
+ALTER TABLE `users` ADD COLUMN `email_verified` BOOLEAN NOT NULL DEFAULT false;
+
+-- CreateTable
+CREATE TABLE `user_tokens` (
+    `id` INTEGER NOT NULL AUTO_INCREMENT,
+    `user_id` INTEGER NOT NULL,
+    `purpose` VARCHAR(32) NOT NULL,
+    `token_hash` CHAR(64) NOT NULL,
+    `email` VARCHAR(128) NOT NULL,
+    `expires_at` TIMESTAMP(6) NOT NULL,
+    `used_at` TIMESTAMP(6) NULL,
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+    UNIQUE INDEX `user_tokens_token_hash_key`(`token_hash`),
+    INDEX `user_tokens_user_id_purpose_idx`(`user_id`, `purpose`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- AddForeignKey
+ALTER TABLE `user_tokens` ADD CONSTRAINT `user_tokens_user_id_fkey` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
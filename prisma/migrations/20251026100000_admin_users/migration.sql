@@ -0,0 +1,5 @@
+-- AlterTable
+ALTER TABLE `users` ADD COLUMN `disabled` BOOLEAN NOT NULL DEFAULT false;
+
+-- AlterTable
+ALTER TABLE `sessions` ADD COLUMN `impersonator_id` INTEGER NULL;
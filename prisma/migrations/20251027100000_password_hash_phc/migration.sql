@@ -0,0 +1,5 @@
+-- Соль bcrypt-хэшей переносится в хэш (формат $bcrypt-salted$<соль>$2a$...)
+UPDATE `users` SET `password_hash` = CONCAT('$bcrypt-salted$', `password_salt`, `password_hash`) WHERE `password_hash` LIKE '$2%';
+
+-- AlterTable
+ALTER TABLE `users` DROP COLUMN `password_salt`;
@@ -0,0 +1,36 @@
+-- CreateTable
+CREATE TABLE `audit_log` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT,
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    `actor_id` INTEGER NULL,
+    `actor_name` VARCHAR(64) NOT NULL DEFAULT '',
+    `impersonator_id` INTEGER NULL,
+    `api_key_id` INTEGER NULL,
+    `ip` VARCHAR(64) NOT NULL DEFAULT '',
+    `user_agent` VARCHAR(255) NOT NULL DEFAULT '',
+    `action` VARCHAR(96) NOT NULL,
+    `method` VARCHAR(8) NOT NULL DEFAULT '',
+    `path` VARCHAR(255) NOT NULL DEFAULT '',
+    `server_id` INTEGER NULL,
+    `instance_id` VARCHAR(128) NOT NULL DEFAULT '',
+    `target` VARCHAR(128) NOT NULL DEFAULT '',
+    `params` TEXT NULL,
+    `result` VARCHAR(16) NOT NULL,
+    `status` INTEGER NOT NULL DEFAULT 0,
+    `error` VARCHAR(255) NOT NULL DEFAULT '',
+    `duration_ms` BIGINT NOT NULL DEFAULT 0,
+
+    INDEX `audit_log_created_at_idx`(`created_at`),
+    INDEX `audit_log_actor_id_id_idx`(`actor_id`, `id`),
+    INDEX `audit_log_impersonator_id_id_idx`(`impersonator_id`, `id`),
+    INDEX `audit_log_server_id_id_idx`(`server_id`, `id`),
+    INDEX `audit_log_action_id_idx`(`action`, `id`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- Журнал только дополняется
+CREATE TRIGGER `audit_log_no_update` BEFORE UPDATE ON `audit_log` FOR EACH ROW
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
+
+CREATE TRIGGER `audit_log_no_delete` BEFORE DELETE ON `audit_log` FOR EACH ROW
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
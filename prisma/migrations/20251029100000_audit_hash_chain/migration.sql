@@ -0,0 +1,37 @@
+-- AlterTable
+ALTER TABLE `audit_log` ADD COLUMN `prev_hash` CHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN `hash` CHAR(64) NOT NULL DEFAULT '';
+
+-- CreateTable
+CREATE TABLE `audit_chain` (
+    `id` TINYINT NOT NULL,
+    `last_id` BIGINT NOT NULL,
+    `last_hash` CHAR(64) NOT NULL,
+
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- CreateTable
+CREATE TABLE `audit_checkpoints` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT,
+    `created_at` TIMESTAMP(6) NOT NULL,
+    `entry_id` BIGINT NOT NULL,
+    `entry_hash` CHAR(64) NOT NULL,
+    `algorithm` VARCHAR(16) NOT NULL,
+    `key_id` VARCHAR(64) NOT NULL,
+    `signature` VARCHAR(128) NOT NULL,
+
+    INDEX `audit_checkpoints_entry_id_idx`(`entry_id`),
+    PRIMARY KEY (`id`)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- Цепочка начинается после уже сделанных записей
+INSERT INTO `audit_chain` (`id`, `last_id`, `last_hash`)
+    SELECT 1, COALESCE(MAX(`id`), 0), REPEAT('0', 64) FROM `audit_log`;
+
+-- Контрольные точки только дополняются
+CREATE TRIGGER `audit_checkpoints_no_update` BEFORE UPDATE ON `audit_checkpoints` FOR EACH ROW
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_checkpoints is append-only';
+
+CREATE TRIGGER `audit_checkpoints_no_delete` BEFORE DELETE ON `audit_checkpoints` FOR EACH ROW
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_checkpoints is append-only';
@@ -25,7 +25,6 @@ CREATE TABLE IF NOT EXISTS users (
 	username VARCHAR(64) NOT NULL UNIQUE,
 	email VARCHAR(128) NOT NULL UNIQUE,
 	password_hash VARCHAR(255) NOT NULL,
-	password_salt VARCHAR(64) NOT NULL,
 	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -6,6 +6,8 @@ import DashboardPage from './pages/DashboardPage';
 import ServersPage from './pages/ServersPage';
 import InstancesPage from './pages/InstancesPage';
 import ProfilePage from './pages/ProfilePage';
+import PasswordResetPage from './pages/PasswordResetPage';
+import VerifyEmailPage from './pages/VerifyEmailPage';
 import Layout from './components/Layout';
 
 const App: React.FC = () => {
@@ -13,6 +15,9 @@ const App: React.FC = () => {
     <Routes>
   <Route path="/login" element={<LoginPage />} />
   <Route path="/register" element={<RegisterPage />} />
+  <Route path="/forgot" element={<PasswordResetPage />} />
+  <Route path="/reset" element={<PasswordResetPage />} />
+  <Route path="/verify" element={<VerifyEmailPage />} />
       <Route path="/" element={<Layout />}>        
         <Route index element={<DashboardPage />} />
         <Route path="servers" element={<ServersPage />} />
@@ -1,6 +1,6 @@
 import React from 'react';
 import { NavLink, Link, useNavigate } from 'react-router-dom';
-import { clearToken, getToken, getUser } from '../lib/auth';
+import { logout as endSession, getToken, getUser } from '../lib/auth';
 
 const navLinkClass = ({isActive}:{isActive:boolean}) =>
   'block px-3 py-2 rounded-md text-sm font-medium transition-colors ' +
@@ -10,7 +10,7 @@ const Layout: React.FC<React.PropsWithChildren> = ({children}) => {
   const nav = useNavigate();
   const token = getToken();
   const user = getUser();
-  const logout = () => { clearToken(); nav('/login'); };
+  const logout = () => { endSession(); nav('/login'); };
   if(!token) return (
     <div className="min-h-screen flex items-center justify-center bg-slate-100 p-6">
       <div className="card w-full max-w-sm">
@@ -1,7 +1,48 @@
 export const TOKEN_KEY = 'ospab_token';
+export const REFRESH_KEY = 'ospab_refresh';
 export const USER_KEY = 'ospab_user';
 
-export function saveAuth(token: string, user: any){ localStorage.setItem(TOKEN_KEY, token); localStorage.setItem(USER_KEY, JSON.stringify(user)); }
+export function saveAuth(token: string, user: any, refreshToken?: string){ localStorage.setItem(TOKEN_KEY, token); localStorage.setItem(USER_KEY, JSON.stringify(user)); if(refreshToken) localStorage.setItem(REFRESH_KEY, refreshToken); }
 export function getToken(){ return localStorage.getItem(TOKEN_KEY); }
 export function getUser(){ const raw = localStorage.getItem(USER_KEY); return raw? JSON.parse(raw): null; }
-export function clearToken(){ localStorage.removeItem(TOKEN_KEY); localStorage.removeItem(USER_KEY); }
+export function clearToken(){ localStorage.removeItem(TOKEN_KEY); localStorage.removeItem(REFRESH_KEY); localStorage.removeItem(USER_KEY); }
+
+// Завершение сессии на сервере (refresh-токен и текущий access-токен отзываются)
+export async function logout(){
+  const token = getToken();
+  clearToken();
+  if(token) await fetch('/api/auth/logout',{method:'POST',headers:{'Authorization':`Bearer ${token}`}}).catch(()=>{});
+}
+
+let refreshing: Promise<string|null> | null = null;
+
+// Один refresh на все параллельные запросы: refresh-токен одноразовый
+function refreshToken(nativeFetch: typeof fetch): Promise<string|null> {
+  if(!refreshing){
+    refreshing = (async()=>{
+      const rt = localStorage.getItem(REFRESH_KEY);
+      if(!rt) return null;
+      const res = await nativeFetch('/api/auth/refresh',{method:'POST',headers:{'Content-Type':'application/json'},body:JSON.stringify({refresh_token:rt})});
+      if(!res.ok){ clearToken(); return null; }
+      const data = await res.json();
+      saveAuth(data.token, data.user, data.refresh_token);
+      return data.token as string;
+    })().finally(()=>{ refreshing = null; });
+  }
+  return refreshing;
+}
+
+// Access-токен короткоживущий: при 401 запрос к API повторяется после refresh
+export function installAuthRefresh(){
+  const nativeFetch = window.fetch.bind(window);
+  window.fetch = async (input: RequestInfo | URL, init?: RequestInit) => {
+    const res = await nativeFetch(input, init);
+    const url = typeof input === 'string' ? input : input instanceof URL ? input.href : input.url;
+    const headers = new Headers(init?.headers);
+    if(res.status !== 401 || url.includes('/api/auth/') || !headers.has('Authorization')) return res;
+    const token = await refreshToken(nativeFetch);
+    if(!token) return res;
+    headers.set('Authorization', `Bearer ${token}`);
+    return nativeFetch(input, {...init, headers});
+  };
+}
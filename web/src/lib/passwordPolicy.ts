@@ -0,0 +1,28 @@
+import React from 'react';
+
+const API_BASE = (import.meta as any).env.VITE_API_URL || '';
+
+export interface PasswordPolicy { min_length: number; max_length: number; min_classes: number; forbid_user_info: boolean; breached_check: boolean; }
+
+const fallback: PasswordPolicy = { min_length: 8, max_length: 128, min_classes: 1, forbid_user_info: true, breached_check: true };
+
+let cached: Promise<PasswordPolicy> | null = null;
+
+// Требования к паролю с сервера (GET /api/auth/password-policy); окончательную проверку делает сервер
+export function usePasswordPolicy(): PasswordPolicy {
+  const [policy,setPolicy] = React.useState<PasswordPolicy>(fallback);
+  React.useEffect(()=>{
+    if(!cached) cached = fetch(API_BASE + '/api/auth/password-policy').then(r=>r.ok ? r.json() : fallback).catch(()=>{ cached = null; return fallback; });
+    cached.then(setPolicy);
+  },[]);
+  return policy;
+}
+
+// Подсказка под полем пароля
+export function describePasswordPolicy(p: PasswordPolicy): string {
+  const parts = [`Минимум ${p.min_length} символов`];
+  if(p.min_classes > 1) parts.push(`символы ${p.min_classes} видов из: строчные и заглавные буквы, цифры, прочие`);
+  if(p.forbid_user_info) parts.push('без логина и email');
+  if(p.breached_check) parts.push('не из утечек данных');
+  return parts.join(', ') + '.';
+}
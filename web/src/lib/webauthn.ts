@@ -0,0 +1,53 @@
+import { getToken } from './auth';
+
+const API_BASE = (import.meta as any).env.VITE_API_URL || '';
+
+// WebAuthn передаёт бинарные поля как base64url
+function fromB64url(s: string): ArrayBuffer {
+  const b64 = s.replace(/-/g,'+').replace(/_/g,'/') + '==='.slice((s.length + 3) % 4);
+  return Uint8Array.from(atob(b64), c => c.charCodeAt(0)).buffer;
+}
+function toB64url(buf: ArrayBuffer | null): string | undefined {
+  if(!buf) return undefined;
+  return btoa(String.fromCharCode(...new Uint8Array(buf))).replace(/\+/g,'-').replace(/\//g,'_').replace(/=+$/,'');
+}
+
+async function post(path: string, body?: any, auth = false){
+  const headers: Record<string,string> = {'Content-Type':'application/json'};
+  if(auth) headers['Authorization'] = `Bearer ${getToken()}`;
+  const res = await fetch(API_BASE + path,{method:'POST',headers,body: body === undefined ? undefined : JSON.stringify(body)});
+  const data = await res.json().catch(()=>null);
+  if(!res.ok) throw new Error(data?.message || 'Ошибка WebAuthn');
+  return data;
+}
+
+export const webauthnSupported = () => typeof window !== 'undefined' && !!window.PublicKeyCredential;
+
+// Регистрация ключа текущим пользователем
+export async function registerKey(name: string){
+  const { challenge_token, options } = await post('/api/auth/webauthn/register/begin', undefined, true);
+  const pk = options.publicKey;
+  pk.challenge = fromB64url(pk.challenge);
+  pk.user.id = fromB64url(pk.user.id);
+  (pk.excludeCredentials || []).forEach((c: any) => { c.id = fromB64url(c.id); });
+  const cred = await navigator.credentials.create({ publicKey: pk }) as PublicKeyCredential;
+  const r = cred.response as AuthenticatorAttestationResponse;
+  return post(`/api/auth/webauthn/register/finish?challenge_token=${encodeURIComponent(challenge_token)}&name=${encodeURIComponent(name)}`, {
+    id: cred.id, rawId: toB64url(cred.rawId), type: cred.type,
+    response: { clientDataJSON: toB64url(r.clientDataJSON), attestationObject: toB64url(r.attestationObject), transports: r.getTransports?.() },
+  }, true);
+}
+
+// Вход по ключу; ответ — как у /api/auth/login (токены или mfa_required)
+export async function loginWithKey(username?: string){
+  const { challenge_token, options } = await post('/api/auth/webauthn/login/begin', { username: username || '' });
+  const pk = options.publicKey;
+  pk.challenge = fromB64url(pk.challenge);
+  (pk.allowCredentials || []).forEach((c: any) => { c.id = fromB64url(c.id); });
+  const cred = await navigator.credentials.get({ publicKey: pk }) as PublicKeyCredential;
+  const r = cred.response as AuthenticatorAssertionResponse;
+  return post(`/api/auth/webauthn/login/finish?challenge_token=${encodeURIComponent(challenge_token)}`, {
+    id: cred.id, rawId: toB64url(cred.rawId), type: cred.type,
+    response: { clientDataJSON: toB64url(r.clientDataJSON), authenticatorData: toB64url(r.authenticatorData), signature: toB64url(r.signature), userHandle: toB64url(r.userHandle) },
+  });
+}
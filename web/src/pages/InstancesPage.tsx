@@ -19,10 +19,10 @@ const InstancesPage: React.FC = () => {
       .catch(()=>{});
   },[token]);
 
-  const loadInstances = async () => {
+  const loadInstances = async (refresh=false) => {
     if(!selected) return; setLoading(true); setError('');
     try {
-      const r = await fetch(`/api/servers/${selected}/instances`, {headers:{'Authorization':`Bearer ${token}`}});
+      const r = await fetch(`/api/servers/${selected}/instances${refresh? '?refresh=true':''}`, {headers:{'Authorization':`Bearer ${token}`}});
       if(!r.ok) throw new Error('Не удалось загрузить');
       const d = await r.json();
       setItems(Array.isArray(d)? d : (d.instances||[]));
@@ -34,7 +34,7 @@ const InstancesPage: React.FC = () => {
   const action = async (id:string, act:string) => {
     try {
       await fetch(`/api/servers/${selected}/instances/${id}/${act}`, {method:'POST',headers:{'Authorization':`Bearer ${token}`}});
-      loadInstances();
+      loadInstances(true);
     } catch{}
   };
 
@@ -47,7 +47,7 @@ const InstancesPage: React.FC = () => {
             <option value="">Выберите сервер</option>
             {servers.map(s=> <option key={s.id} value={s.id}>{s.name} ({s.type})</option>)}
           </select>
-          <button onClick={loadInstances} disabled={!selected||loading} className="btn-secondary">Обновить</button>
+          <button onClick={()=>loadInstances(true)} disabled={!selected||loading} className="btn-secondary">Обновить</button>
         </div>
       </div>
       {!selected && <div className="text-sm text-slate-500">Сначала выберите сервер для просмотра инстансов.</div>}
@@ -1,6 +1,7 @@
 import React from 'react';
 import { useNavigate, Link } from 'react-router-dom';
 import { saveAuth, getToken } from '../lib/auth';
+import { loginWithKey, webauthnSupported } from '../lib/webauthn';
 
 const API_BASE = (import.meta as any).env.VITE_API_URL || '';
 
@@ -9,23 +10,62 @@ const LoginPage: React.FC = () => {
   const [loading,setLoading] = React.useState(false);
   const [error,setError] = React.useState('');
   const [showPassword,setShowPassword] = React.useState(false);
+  const [challenge,setChallenge] = React.useState(''); // второй шаг входа при включённой 2FA
+
+  const [ssoEnabled,setSsoEnabled] = React.useState(false);
+  const [registration,setRegistration] = React.useState(true);
+  const resetDone = new URLSearchParams(window.location.search).get('reset') === '1'; // возврат со страницы сброса пароля
+
+  // Обычный вход: результат /api/auth/login, 2fa/verify, WebAuthn или обмена SSO-кода
+  const finish = (data: any) => {
+    if(data.mfa_required){ setChallenge(data.challenge_token); return; }
+    saveAuth(data.token, data.user, data.refresh_token);
+    nav('/');
+  };
 
   React.useEffect(()=>{ if(getToken()) nav('/'); },[nav]);
 
+  // Возврат от провайдера SSO: ?sso_code=... обменивается на токены, ?sso_error=... показывается
+  React.useEffect(()=>{
+    fetch(API_BASE + '/api/auth/oidc').then(r=>r.ok ? r.json() : null).then(d=>setSsoEnabled(!!d?.enabled)).catch(()=>{});
+    fetch(API_BASE + '/api/auth/registration').then(r=>r.ok ? r.json() : null).then(d=>{ if(d) setRegistration(!!d.enabled); }).catch(()=>{});
+    const params = new URLSearchParams(window.location.search);
+    const code = params.get('sso_code'), ssoError = params.get('sso_error');
+    if(!code && !ssoError) return;
+    window.history.replaceState(null, '', window.location.pathname);
+    if(ssoError){ setError(ssoError); return; }
+    setLoading(true);
+    fetch(API_BASE + '/api/auth/oidc/exchange',{method:'POST',headers:{'Content-Type':'application/json'},body: JSON.stringify({code})})
+      .then(async res => { const data = await res.json(); if(!res.ok) throw new Error(data?.message || 'Ошибка входа через SSO'); finish(data); })
+      .catch(err => setError(err.message))
+      .finally(()=>setLoading(false));
+  // eslint-disable-next-line react-hooks/exhaustive-deps
+  },[]);
+
   const submit = async (e: React.FormEvent) => {
     e.preventDefault();
     setError(''); setLoading(true);
     const fd = new FormData(e.target as HTMLFormElement);
     try {
-      const res = await fetch(API_BASE + '/api/auth/login',{method:'POST',headers:{'Content-Type':'application/json'},body: JSON.stringify({username:fd.get('username'),password:fd.get('password')})});
+      const res = challenge
+        ? await fetch(API_BASE + '/api/auth/2fa/verify',{method:'POST',headers:{'Content-Type':'application/json'},body: JSON.stringify({challenge_token:challenge,code:fd.get('code')})})
+        : await fetch(API_BASE + '/api/auth/login',{method:'POST',headers:{'Content-Type':'application/json'},body: JSON.stringify({username:fd.get('username'),password:fd.get('password')})});
       if(!res.ok){
         let msg = 'Ошибка авторизации';
         try { const j = await res.json(); if(j?.message) msg = j.message; } catch {}
+        if(challenge && res.status === 401 && msg.includes('войдите заново')) setChallenge('');
         throw new Error(msg);
       }
-      const data = await res.json();
-      saveAuth(data.token, data.user);
-      nav('/');
+      finish(await res.json());
+    } catch(err:any){ setError(err.message); } finally { setLoading(false); }
+  };
+
+  // Вход по ключу безопасности / passkey: логин необязателен
+  const keyLogin = async (e: React.MouseEvent<HTMLButtonElement>) => {
+    setError(''); setLoading(true);
+    const username = new FormData(e.currentTarget.form as HTMLFormElement).get('username') as string;
+    try {
+      finish(await loginWithKey(username));
     } catch(err:any){ setError(err.message); } finally { setLoading(false); }
   };
 
@@ -37,10 +77,21 @@ const LoginPage: React.FC = () => {
           <p className="text-sm text-slate-500 mt-2">Добро пожаловать. Войдите в аккаунт.</p>
         </div>
         <form onSubmit={submit} className="card">
+          {challenge ? (
+          <div className="card-body space-y-5">
+            <div>
+              <label className="block text-xs font-medium text-slate-600 mb-1">Код из приложения-аутентификатора</label>
+              <input name="code" className="input" placeholder="123456 или код восстановления" autoComplete="one-time-code" required autoFocus />
+            </div>
+            {error && <div className="text-xs rounded-md bg-red-50 border border-red-200 px-3 py-2 text-red-600">{error}</div>}
+            <button disabled={loading} className="btn w-full justify-center">{loading? '...' : 'Подтвердить'}</button>
+            <p className="text-xs text-slate-500 text-center"><button type="button" onClick={()=>{ setChallenge(''); setError(''); }} className="text-brand-600 hover:underline">Войти заново</button></p>
+          </div>
+          ) : (
           <div className="card-body space-y-5">
             <div>
               <label className="block text-xs font-medium text-slate-600 mb-1">Логин</label>
-              <input name="username" className="input" placeholder="Ваш логин" required autoFocus />
+              <input name="username" className="input" placeholder="Ваш логин" autoComplete="username webauthn" autoFocus />
             </div>
             <div>
               <label className="flex items-center justify-between text-xs font-medium text-slate-600 mb-1">
@@ -49,12 +100,17 @@ const LoginPage: React.FC = () => {
                   {showPassword? 'Скрыть':'Показать'}
                 </button>
               </label>
-              <input name="password" type={showPassword? 'text':'password'} className="input" placeholder="••••••••" required />
+              <input name="password" type={showPassword? 'text':'password'} className="input" placeholder="••••••••" />
             </div>
+            {resetDone && !error && <div className="text-xs rounded-md bg-green-50 border border-green-200 px-3 py-2 text-green-700">Пароль изменён. Войдите с новым паролем.</div>}
             {error && <div className="text-xs rounded-md bg-red-50 border border-red-200 px-3 py-2 text-red-600">{error}</div>}
             <button disabled={loading} className="btn w-full justify-center">{loading? '...' : 'Войти'}</button>
-            <p className="text-xs text-slate-500 text-center">Нет аккаунта? <Link to="/register" className="text-brand-600 hover:underline">Создать</Link></p>
+            {webauthnSupported() && <button type="button" disabled={loading} onClick={keyLogin} className="btn-secondary w-full justify-center">Войти по ключу безопасности</button>}
+            {ssoEnabled && <a href={API_BASE + '/api/auth/oidc/login'} className="btn-secondary w-full justify-center">Войти через SSO</a>}
+            <p className="text-xs text-slate-500 text-center"><Link to="/forgot" className="text-brand-600 hover:underline">Забыли пароль?</Link></p>
+            {registration && <p className="text-xs text-slate-500 text-center">Нет аккаунта? <Link to="/register" className="text-brand-600 hover:underline">Создать</Link></p>}
           </div>
+          )}
         </form>
         <p className="mt-8 text-center text-[11px] text-slate-400">© {new Date().getFullYear()} OSPAB</p>
       </div>
@@ -0,0 +1,79 @@
+import React from 'react';
+import { Link, useNavigate } from 'react-router-dom';
+import { usePasswordPolicy, describePasswordPolicy } from '../lib/passwordPolicy';
+
+const API_BASE = (import.meta as any).env.VITE_API_URL || '';
+
+// /forgot — запрос письма со ссылкой; /reset?token=... — ввод нового пароля по ссылке из письма
+const PasswordResetPage: React.FC = () => {
+  const nav = useNavigate();
+  const token = new URLSearchParams(window.location.search).get('token') || '';
+  const [loading,setLoading] = React.useState(false);
+  const [error,setError] = React.useState('');
+  const [notice,setNotice] = React.useState('');
+  const policy = usePasswordPolicy();
+
+  const post = async (path: string, body: any) => {
+    const res = await fetch(API_BASE + path,{method:'POST',headers:{'Content-Type':'application/json'},body: JSON.stringify(body)});
+    if(!res.ok){
+      let msg = 'Ошибка запроса';
+      try { const j = await res.json(); if(j?.message) msg = j.message; } catch {}
+      throw new Error(msg);
+    }
+    return res.status === 204 ? null : res.json();
+  };
+
+  const submit = async (e: React.FormEvent) => {
+    e.preventDefault(); setError(''); setLoading(true);
+    const fd = new FormData(e.target as HTMLFormElement);
+    try {
+      if(token){
+        if(fd.get('password') !== fd.get('confirm')) throw new Error('Пароли не совпадают');
+        await post('/api/auth/reset',{token,new_password:fd.get('password')});
+        nav('/login?reset=1');
+      } else {
+        const data = await post('/api/auth/forgot',{email:fd.get('email')});
+        setNotice(data?.message || 'Письмо отправлено');
+      }
+    } catch(err:any){ setError(err.message); } finally { setLoading(false); }
+  };
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gradient-to-br from-slate-100 to-slate-200 p-4">
+      <div className="w-full max-w-md">
+        <div className="mb-8 text-center">
+          <h1 className="text-3xl font-bold tracking-tight text-slate-800">{token? 'Новый пароль' : 'Сброс пароля'}</h1>
+          <p className="text-sm text-slate-500 mt-2">{token? 'Задайте новый пароль — все сессии будут завершены.' : 'Укажите email, привязанный к аккаунту.'}</p>
+        </div>
+        <form onSubmit={submit} className="card">
+          <div className="card-body space-y-5">
+            {token ? (
+              <>
+                <div>
+                  <label className="block text-xs font-medium text-slate-600 mb-1">Новый пароль</label>
+                  <input name="password" type="password" className="input" minLength={policy.min_length} maxLength={policy.max_length} required autoFocus />
+                  <p className="text-[11px] text-slate-500 mt-1">{describePasswordPolicy(policy)}</p>
+                </div>
+                <div>
+                  <label className="block text-xs font-medium text-slate-600 mb-1">Повторите пароль</label>
+                  <input name="confirm" type="password" className="input" minLength={policy.min_length} maxLength={policy.max_length} required />
+                </div>
+              </>
+            ) : (
+              <div>
+                <label className="block text-xs font-medium text-slate-600 mb-1">Email</label>
+                <input name="email" type="email" className="input" required autoFocus />
+              </div>
+            )}
+            {notice && <div className="text-xs rounded-md bg-green-50 border border-green-200 px-3 py-2 text-green-700">{notice}</div>}
+            {error && <div className="text-xs rounded-md bg-red-50 border border-red-200 px-3 py-2 text-red-600">{error}</div>}
+            <button disabled={loading || !!notice} className="btn w-full justify-center">{loading? '...' : token? 'Сохранить' : 'Отправить ссылку'}</button>
+            <p className="text-xs text-slate-500 text-center"><Link to="/login" className="text-brand-600 hover:underline">Вернуться ко входу</Link></p>
+          </div>
+        </form>
+      </div>
+    </div>
+  );
+};
+
+export default PasswordResetPage;
@@ -1,14 +1,310 @@
 import React from 'react';
-import { getUser } from '../lib/auth';
+import { useNavigate } from 'react-router-dom';
+import { getUser, getToken, saveAuth, clearToken, USER_KEY } from '../lib/auth';
+import { registerKey, webauthnSupported } from '../lib/webauthn';
+import { usePasswordPolicy, describePasswordPolicy } from '../lib/passwordPolicy';
+
+type SecurityKey = { id: number; name: string; backup_eligible: boolean; created_at: string; last_used_at?: string };
+
+// Ключи безопасности и passkeys для входа без пароля
+const SecurityKeys: React.FC = () => {
+  const [keys,setKeys] = React.useState<SecurityKey[]>([]);
+  const [error,setError] = React.useState('');
+  const headers = () => ({'Authorization':`Bearer ${getToken()}`});
+  const load = React.useCallback(async()=>{
+    const res = await fetch('/api/auth/webauthn/credentials',{headers:headers()});
+    if(res.ok) setKeys(await res.json());
+  },[]);
+  React.useEffect(()=>{ load(); },[load]);
+
+  const add = async () => {
+    setError('');
+    const name = window.prompt('Название ключа','Ключ безопасности');
+    if(name === null) return;
+    try { await registerKey(name); await load(); } catch(err:any){ setError(err.message); }
+  };
+  const remove = async (id: number) => {
+    if(!window.confirm('Удалить ключ?')) return;
+    await fetch(`/api/auth/webauthn/credentials/${id}`,{method:'DELETE',headers:headers()});
+    await load();
+  };
+
+  return (
+    <div className="card">
+      <div className="card-header flex items-center justify-between">
+        <span className="font-medium">Ключи безопасности</span>
+        {webauthnSupported() && <button className="btn-secondary" onClick={add}>Добавить</button>}
+      </div>
+      <div className="card-body text-sm space-y-2">
+        {keys.length === 0 && <p className="text-slate-500">Ключей нет. Добавьте FIDO2-ключ или passkey, чтобы входить без пароля.</p>}
+        {keys.map(k => (
+          <div key={k.id} className="flex items-center justify-between">
+            <span>{k.name}{k.backup_eligible && <span className="ml-2 text-xs text-slate-400">passkey</span>}</span>
+            <button className="text-xs text-red-600 hover:underline" onClick={()=>remove(k.id)}>Удалить</button>
+          </div>
+        ))}
+        {error && <div className="text-xs rounded-md bg-red-50 border border-red-200 px-3 py-2 text-red-600">{error}</div>}
+      </div>
+    </div>
+  );
+};
+
+type ApiKey = { id: number; name: string; prefix: string; scope: string; created_at: string; last_used_at?: string; expires_at?: string };
+
+const scopeLabels: Record<string,string> = { read: 'Только чтение', power: 'Питание и снапшоты', admin: 'Все права роли' };
+const fmtDate = (s?: string) => s ? new Date(s).toLocaleString() : '—';
+
+// Персональные API-ключи для скриптов и CI
+const ApiKeys: React.FC = () => {
+  const [keys,setKeys] = React.useState<ApiKey[]>([]);
+  const [form,setForm] = React.useState({name:'', scope:'read', days:'90'});
+  const [created,setCreated] = React.useState('');
+  const [error,setError] = React.useState('');
+  const headers = () => ({'Authorization':`Bearer ${getToken()}`,'Content-Type':'application/json'});
+  const load = React.useCallback(async()=>{
+    const res = await fetch('/api/me/api-keys',{headers:headers()});
+    if(res.ok) setKeys(await res.json());
+  },[]);
+  React.useEffect(()=>{ load(); },[load]);
+
+  const create = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError(''); setCreated('');
+    const days = Number(form.days);
+    const expires_at = days > 0 ? new Date(Date.now() + days*86400000).toISOString() : undefined;
+    const res = await fetch('/api/me/api-keys',{method:'POST',headers:headers(),body:JSON.stringify({name:form.name,scope:form.scope,expires_at})});
+    const data = await res.json().catch(()=>null);
+    if(!res.ok){ setError(data?.message || 'Не удалось создать ключ'); return; }
+    setCreated(data.key);
+    setForm({...form,name:''});
+    await load();
+  };
+  const rename = async (k: ApiKey) => {
+    const name = window.prompt('Название ключа',k.name);
+    if(!name) return;
+    await fetch(`/api/me/api-keys/${k.id}`,{method:'PATCH',headers:headers(),body:JSON.stringify({name})});
+    await load();
+  };
+  const revoke = async (id: number) => {
+    if(!window.confirm('Отозвать ключ? Скрипты, использующие его, перестанут работать.')) return;
+    await fetch(`/api/me/api-keys/${id}`,{method:'DELETE',headers:headers()});
+    await load();
+  };
+
+  return (
+    <div className="card">
+      <div className="card-header"><span className="font-medium">API-ключи</span></div>
+      <div className="card-body text-sm space-y-3">
+        <form onSubmit={create} className="flex flex-wrap gap-2">
+          <input className="input flex-1" placeholder="Название, например CI deploy" value={form.name} onChange={e=>setForm({...form,name:e.target.value})} required />
+          <select className="input w-auto" value={form.scope} onChange={e=>setForm({...form,scope:e.target.value})}>
+            {Object.entries(scopeLabels).map(([v,l]) => <option key={v} value={v}>{l}</option>)}
+          </select>
+          <select className="input w-auto" value={form.days} onChange={e=>setForm({...form,days:e.target.value})}>
+            <option value="30">30 дней</option>
+            <option value="90">90 дней</option>
+            <option value="365">1 год</option>
+            <option value="0">Бессрочно</option>
+          </select>
+          <button className="btn">Создать</button>
+        </form>
+        {created && (
+          <div className="rounded-md bg-green-50 border border-green-200 px-3 py-2 space-y-1">
+            <div className="text-xs text-green-700">Скопируйте ключ сейчас — он больше не будет показан:</div>
+            <code className="block font-mono text-xs break-all">{created}</code>
+          </div>
+        )}
+        {keys.length === 0 && <p className="text-slate-500">Ключей нет. Ключ передаётся в заголовке <code>Authorization: Bearer ospab_...</code> или <code>X-API-Key</code>.</p>}
+        {keys.map(k => (
+          <div key={k.id} className="flex items-center justify-between gap-2">
+            <div>
+              <div>{k.name} <span className="font-mono text-xs text-slate-400">{k.prefix}…</span></div>
+              <div className="text-xs text-slate-500">{scopeLabels[k.scope] || k.scope} · использован: {fmtDate(k.last_used_at)} · истекает: {k.expires_at ? fmtDate(k.expires_at) : 'никогда'}</div>
+            </div>
+            <div className="flex gap-3 shrink-0">
+              <button className="text-xs text-slate-600 hover:underline" onClick={()=>rename(k)}>Переименовать</button>
+              <button className="text-xs text-red-600 hover:underline" onClick={()=>revoke(k.id)}>Отозвать</button>
+            </div>
+          </div>
+        ))}
+        {error && <div className="text-xs rounded-md bg-red-50 border border-red-200 px-3 py-2 text-red-600">{error}</div>}
+      </div>
+    </div>
+  );
+};
+
+// Статус подтверждения email и повторная отправка письма
+const EmailStatus: React.FC<{ verified: boolean }> = ({ verified }) => {
+  const [note,setNote] = React.useState('');
+  if(verified) return <span className="text-[11px] text-green-700">подтверждён</span>;
+  const resend = async () => {
+    const res = await fetch('/api/auth/verify/resend',{method:'POST',headers:{'Authorization':`Bearer ${getToken()}`}});
+    if(res.ok){ setNote('Письмо отправлено'); return; }
+    let msg = 'Не удалось отправить письмо';
+    try { const j = await res.json(); if(j?.message) msg = j.message; } catch {}
+    setNote(msg);
+  };
+  return (
+    <span className="text-[11px] text-amber-700">
+      не подтверждён · {note || <button type="button" onClick={resend} className="text-brand-600 hover:underline">отправить письмо</button>}
+    </span>
+  );
+};
+
+type Session = { id: string; user_agent: string; ip: string; created_at: string; last_used_at: string; mfa: boolean; current?: boolean };
+
+const errorMessage = async (res: Response, fallback: string) => {
+  try { const j = await res.json(); if(j?.message) return j.message as string; } catch {}
+  return fallback;
+};
+
+// Смена пароля: остальные сессии завершаются, текущая получает новые токены
+const ChangePassword: React.FC<{ hasPassword: boolean }> = ({ hasPassword }) => {
+  const [error,setError] = React.useState('');
+  const [done,setDone] = React.useState(false);
+  const policy = usePasswordPolicy();
+  const submit = async (e: React.FormEvent) => {
+    e.preventDefault(); setError(''); setDone(false);
+    const f = e.target as HTMLFormElement;
+    const fd = new FormData(f);
+    if(fd.get('new_password') !== fd.get('confirm')){ setError('Пароли не совпадают'); return; }
+    const res = await fetch('/api/me/password',{method:'PUT',headers:{'Authorization':`Bearer ${getToken()}`,'Content-Type':'application/json'},
+      body:JSON.stringify({current_password:fd.get('current_password'),new_password:fd.get('new_password')})});
+    if(!res.ok){ setError(await errorMessage(res,'Не удалось сменить пароль')); return; }
+    const data = await res.json();
+    saveAuth(data.token, data.user, data.refresh_token);
+    f.reset(); setDone(true);
+  };
+  return (
+    <div className="card">
+      <div className="card-header"><span className="font-medium">Пароль</span></div>
+      <div className="card-body text-sm space-y-3">
+        {hasPassword ? (
+          <form onSubmit={submit} className="space-y-3">
+            <input name="current_password" type="password" className="input" placeholder="Текущий пароль" required />
+            <div className="grid md:grid-cols-2 gap-3">
+              <input name="new_password" type="password" className="input" placeholder="Новый пароль" minLength={policy.min_length} maxLength={policy.max_length} required />
+              <input name="confirm" type="password" className="input" placeholder="Повторите пароль" minLength={policy.min_length} maxLength={policy.max_length} required />
+            </div>
+            <p className="text-xs text-slate-500">{describePasswordPolicy(policy)} Все остальные сессии будут завершены.</p>
+            <div className="flex justify-end"><button className="btn">Сменить пароль</button></div>
+          </form>
+        ) : <p className="text-slate-500">Вход выполняется через внешний провайдер (SSO или LDAP), пароль меняется там.</p>}
+        {done && <div className="text-xs rounded-md bg-green-50 border border-green-200 px-3 py-2 text-green-700">Пароль изменён</div>}
+        {error && <div className="text-xs rounded-md bg-red-50 border border-red-200 px-3 py-2 text-red-600">{error}</div>}
+      </div>
+    </div>
+  );
+};
+
+// Активные сессии: устройства, с которых выполнен вход
+const Sessions: React.FC = () => {
+  const [list,setList] = React.useState<Session[]>([]);
+  const headers = () => ({'Authorization':`Bearer ${getToken()}`});
+  const load = React.useCallback(async()=>{
+    const res = await fetch('/api/me/sessions',{headers:headers()});
+    if(res.ok) setList(await res.json());
+  },[]);
+  React.useEffect(()=>{ load(); },[load]);
+  const revoke = async (id: string) => {
+    await fetch(`/api/me/sessions/${encodeURIComponent(id)}`,{method:'DELETE',headers:headers()});
+    load();
+  };
+  return (
+    <div className="card">
+      <div className="card-header"><span className="font-medium">Сессии</span></div>
+      <div className="card-body text-sm space-y-2">
+        {list.map(s=>(
+          <div key={s.id} className="flex items-center justify-between gap-3">
+            <div className="min-w-0">
+              <div className="truncate">{s.user_agent || 'Неизвестный клиент'}{s.current && <span className="ml-2 text-[11px] text-green-700">текущая</span>}</div>
+              <div className="text-xs text-slate-500">{s.ip} · активность {new Date(s.last_used_at).toLocaleString()}{s.mfa ? ' · 2FA' : ''}</div>
+            </div>
+            {!s.current && <button className="btn-secondary" onClick={()=>revoke(s.id)}>Завершить</button>}
+          </div>
+        ))}
+        {list.length === 0 && <p className="text-slate-500">Нет активных сессий</p>}
+      </div>
+    </div>
+  );
+};
+
+// Удаление учётной записи (личные серверы удаляются только с явного согласия)
+const DeleteAccount: React.FC<{ username: string; hasPassword: boolean }> = ({ username, hasPassword }) => {
+  const nav = useNavigate();
+  const [open,setOpen] = React.useState(false);
+  const [error,setError] = React.useState('');
+  const submit = async (e: React.FormEvent) => {
+    e.preventDefault(); setError('');
+    const fd = new FormData(e.target as HTMLFormElement);
+    const res = await fetch('/api/me',{method:'DELETE',headers:{'Authorization':`Bearer ${getToken()}`,'Content-Type':'application/json'},
+      body:JSON.stringify({password:fd.get('password')||'',confirm:fd.get('confirm')||'',delete_servers:fd.get('delete_servers')==='on'})});
+    if(!res.ok){
+      const msg = await errorMessage(res,'Не удалось удалить учётную запись');
+      const hints: Record<string,string> = {
+        user_has_servers: 'У вас есть серверы — отметьте их удаление или передайте их в организацию',
+        sole_organization_owner: 'Вы единственный владелец организации с участниками — сначала передайте владение',
+        last_admin: 'Нельзя удалить последнего администратора панели',
+        invalid_current_password: 'Неверный пароль',
+        confirmation_mismatch: 'Логин введён неверно',
+      };
+      setError(hints[msg] || msg); return;
+    }
+    clearToken();
+    nav('/login');
+  };
+  return (
+    <div className="card">
+      <div className="card-header"><span className="font-medium text-red-600">Удаление аккаунта</span></div>
+      <div className="card-body text-sm space-y-3">
+        {!open ? (
+          <button className="btn-secondary" onClick={()=>setOpen(true)}>Удалить аккаунт…</button>
+        ) : (
+          <form onSubmit={submit} className="space-y-3">
+            <p className="text-slate-500">Сессии, API-ключи и доступы будут удалены. Серверы организаций перейдут другому владельцу.</p>
+            {hasPassword
+              ? <input name="password" type="password" className="input" placeholder="Пароль" required />
+              : <input name="confirm" className="input" placeholder={`Введите логин ${username}`} required />}
+            <label className="flex items-center gap-2 text-xs text-slate-600"><input name="delete_servers" type="checkbox" /> Удалить мои серверы</label>
+            <div className="flex justify-end gap-2">
+              <button type="button" className="btn-secondary" onClick={()=>setOpen(false)}>Отмена</button>
+              <button className="btn bg-red-600 hover:bg-red-700">Удалить навсегда</button>
+            </div>
+          </form>
+        )}
+        {error && <div className="text-xs rounded-md bg-red-50 border border-red-200 px-3 py-2 text-red-600">{error}</div>}
+      </div>
+    </div>
+  );
+};
 
 const ProfilePage: React.FC = () => {
-  const user = getUser();
+  const [user,setUser] = React.useState<any>(getUser());
   const [editing,setEditing] = React.useState(false);
-  const [form,setForm] = React.useState({username:user?.username||'', email:user?.email||''});
+  const [form,setForm] = React.useState({username:user?.username||'', email:user?.email||'', password:''});
+  const [error,setError] = React.useState('');
+  const [notice,setNotice] = React.useState('');
+  const headers = () => ({'Authorization':`Bearer ${getToken()}`,'Content-Type':'application/json'});
 
-  const save = (e:React.FormEvent) => {
-    e.preventDefault();
-    // TODO: endpoint for update profile (заглушка)
+  // Актуальный профиль с сервера (в localStorage — данные на момент входа)
+  const store = (p: any) => { setUser(p); localStorage.setItem(USER_KEY, JSON.stringify(p)); };
+  React.useEffect(()=>{
+    fetch('/api/me',{headers:headers()}).then(r=>r.ok ? r.json() : null).then(p=>{ if(p) store(p); }).catch(()=>{});
+  },[]);
+
+  const save = async (e:React.FormEvent) => {
+    e.preventDefault(); setError(''); setNotice('');
+    if(form.username !== user.username){
+      const res = await fetch('/api/me',{method:'PATCH',headers:headers(),body:JSON.stringify({username:form.username})});
+      if(!res.ok){ setError(await errorMessage(res,'Не удалось сохранить профиль')); return; }
+      store(await res.json());
+    }
+    if(form.email !== user.email){
+      const res = await fetch('/api/me/email',{method:'POST',headers:headers(),body:JSON.stringify({email:form.email,password:form.password})});
+      if(!res.ok){ setError(await errorMessage(res,'Не удалось сменить email')); return; }
+      setNotice('На новый адрес отправлено письмо: email изменится после перехода по ссылке');
+    }
+    setForm(f=>({...f,password:''}));
     setEditing(false);
   };
 
@@ -16,11 +312,11 @@ const ProfilePage: React.FC = () => {
     <div className="space-y-6 max-w-xl">
       <div className="flex items-center justify-between">
         <h1 className="text-xl font-semibold text-slate-800">Профиль</h1>
-        {!editing && <button className="btn-secondary" onClick={()=>setEditing(true)}>Редактировать</button>}
+        {!editing && <button className="btn-secondary" onClick={()=>{setForm({username:user.username,email:user.email,password:''});setEditing(true);}}>Редактировать</button>}
       </div>
       <div className="card">
         <div className="card-header"><span className="font-medium">Аккаунт</span></div>
-        <div className="card-body">
+        <div className="card-body space-y-3">
           {user ? (
             editing ? (
               <form onSubmit={save} className="space-y-4">
@@ -30,10 +326,16 @@ const ProfilePage: React.FC = () => {
                 </div>
                 <div>
                   <label className="block text-xs font-medium text-slate-600 mb-1">Email</label>
-                  <input className="input" value={form.email} onChange={e=>setForm({...form,email:e.target.value})} />
+                  <input className="input" type="email" value={form.email} disabled={user.has_password === false} onChange={e=>setForm({...form,email:e.target.value})} />
                 </div>
+                {form.email !== user.email && (
+                  <div>
+                    <label className="block text-xs font-medium text-slate-600 mb-1">Текущий пароль</label>
+                    <input className="input" type="password" value={form.password} onChange={e=>setForm({...form,password:e.target.value})} required />
+                  </div>
+                )}
                 <div className="flex justify-end gap-2 pt-2">
-                  <button type="button" className="btn-secondary" onClick={()=>{setForm({username:user.username,email:user.email});setEditing(false);}}>Отмена</button>
+                  <button type="button" className="btn-secondary" onClick={()=>{setError('');setEditing(false);}}>Отмена</button>
                   <button className="btn">Сохранить</button>
                 </div>
               </form>
@@ -41,22 +343,19 @@ const ProfilePage: React.FC = () => {
               <div className="text-sm space-y-2">
                 <div className="flex items-center justify-between"><span className="text-slate-500">ID</span><span className="font-mono">{user.id}</span></div>
                 <div className="flex items-center justify-between"><span className="text-slate-500">Логин</span><span>{user.username}</span></div>
-                <div className="flex items-center justify-between"><span className="text-slate-500">Email</span><span className="break-all">{user.email}</span></div>
+                <div className="flex items-center justify-between"><span className="text-slate-500">Email</span><span className="break-all text-right">{user.email}<br /><EmailStatus verified={!!user.email_verified} /></span></div>
               </div>
             )
           ) : <div className="text-sm text-slate-500">Нет данных пользователя</div>}
+          {notice && <div className="text-xs rounded-md bg-green-50 border border-green-200 px-3 py-2 text-green-700">{notice}</div>}
+          {error && <div className="text-xs rounded-md bg-red-50 border border-red-200 px-3 py-2 text-red-600">{error}</div>}
         </div>
       </div>
-      <div className="card">
-        <div className="card-header"><span className="font-medium">Безопасность</span></div>
-        <div className="card-body text-sm text-slate-500 space-y-2">
-          <p>Смена пароля пока не реализована.</p>
-          <ul className="list-disc pl-5 space-y-1">
-            <li>Минимум 8 символов</li>
-            <li>Рекомендуется использовать менеджер паролей</li>
-          </ul>
-        </div>
-      </div>
+      {user && <ChangePassword hasPassword={user.has_password !== false} />}
+      <Sessions />
+      <SecurityKeys />
+      <ApiKeys />
+      {user && <DeleteAccount username={user.username} hasPassword={user.has_password !== false} />}
     </div>
   );
 };
@@ -1,6 +1,7 @@
 import React from 'react';
 import { useNavigate, Link } from 'react-router-dom';
 import { saveAuth, getToken } from '../lib/auth';
+import { usePasswordPolicy, describePasswordPolicy } from '../lib/passwordPolicy';
 
 const API_BASE = (import.meta as any).env.VITE_API_URL || '';
 
@@ -9,6 +10,7 @@ const RegisterPage: React.FC = () => {
   const [loading,setLoading] = React.useState(false);
   const [error,setError] = React.useState('');
   const [showPassword,setShowPassword] = React.useState(false);
+  const policy = usePasswordPolicy();
 
   React.useEffect(()=>{ if(getToken()) nav('/'); },[nav]);
 
@@ -23,7 +25,7 @@ const RegisterPage: React.FC = () => {
         throw new Error(msg);
       }
       const data = await res.json();
-      saveAuth(data.token, data.user);
+      saveAuth(data.token, data.user, data.refresh_token);
       nav('/');
     } catch(err:any){ setError(err.message); } finally { setLoading(false); }
   };
@@ -54,7 +56,8 @@ const RegisterPage: React.FC = () => {
                   {showPassword? 'Скрыть':'Показать'}
                 </button>
               </label>
-              <input name="password" type={showPassword? 'text':'password'} className="input" required />
+              <input name="password" type={showPassword? 'text':'password'} className="input" minLength={policy.min_length} maxLength={policy.max_length} required />
+              <p className="text-[11px] text-slate-500 mt-1">{describePasswordPolicy(policy)}</p>
             </div>
             {error && <div className="text-xs rounded-md bg-red-50 border border-red-200 px-3 py-2 text-red-600">{error}</div>}
             <button disabled={loading} className="btn w-full justify-center">{loading? '...' : 'Создать'}</button>
@@ -0,0 +1,42 @@
+import React from 'react';
+import { Link } from 'react-router-dom';
+import { getToken } from '../lib/auth';
+
+const API_BASE = (import.meta as any).env.VITE_API_URL || '';
+
+// /verify?token=... — ссылка из письма подтверждения email
+const VerifyEmailPage: React.FC = () => {
+  const [state,setState] = React.useState<'pending'|'ok'|'error'>('pending');
+  const [error,setError] = React.useState('');
+  const sent = React.useRef(false);
+
+  React.useEffect(()=>{
+    if(sent.current) return; // токен одноразовый — не отправлять повторно при двойном рендере
+    sent.current = true;
+    const token = new URLSearchParams(window.location.search).get('token') || '';
+    fetch(API_BASE + '/api/auth/verify',{method:'POST',headers:{'Content-Type':'application/json'},body: JSON.stringify({token})})
+      .then(async res => {
+        if(res.ok){ setState('ok'); return; }
+        let msg = 'Не удалось подтвердить адрес';
+        try { const j = await res.json(); if(j?.message) msg = j.message; } catch {}
+        setError(msg); setState('error');
+      })
+      .catch(()=>{ setError('Сервер недоступен'); setState('error'); });
+  },[]);
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gradient-to-br from-slate-100 to-slate-200 p-4">
+      <div className="w-full max-w-md card">
+        <div className="card-body space-y-4 text-center">
+          <h1 className="text-xl font-semibold text-slate-800">Подтверждение email</h1>
+          {state==='pending' && <p className="text-sm text-slate-500">Проверяем ссылку...</p>}
+          {state==='ok' && <p className="text-sm text-green-700">Адрес подтверждён.</p>}
+          {state==='error' && <p className="text-sm text-red-600">{error}. Новое письмо можно запросить в профиле.</p>}
+          <Link to={getToken()? '/profile' : '/login'} className="btn w-full justify-center">{getToken()? 'Перейти в профиль' : 'Войти'}</Link>
+        </div>
+      </div>
+    </div>
+  );
+};
+
+export default VerifyEmailPage;